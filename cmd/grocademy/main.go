@@ -3,10 +3,15 @@ package main
 import (
 	"grocademy/internal/api"
 	"grocademy/internal/api/handlers"
+	"grocademy/internal/auth"
 	"grocademy/internal/db"
+	"grocademy/internal/email"
+	"grocademy/internal/events"
+	"grocademy/internal/jobs"
 	"grocademy/internal/services"
 	"grocademy/internal/storage"
 	"log"
+	"time"
 )
 
 // @title           Grocademy API
@@ -34,6 +39,12 @@ import (
 
 func main() {
 
+	// Load the keys that sign session tokens
+	if err := auth.Init(); err != nil {
+		log.Fatal(err)
+		return
+	}
+
 	// Initialize database
 	db.Init()
 	gormDB := db.GetDB()
@@ -43,24 +54,82 @@ func main() {
 		log.Fatal(err)
 		return
 	}
+	// Fan events out to every replica through Postgres
+	eventBus := events.NewBus()
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	if _, err := events.NewPGRelay(sqlDB, db.DSN(), eventBus); err != nil {
+		log.Printf("WARNING: %v, events will only reach clients of this instance", err)
+	}
+
 	// Initialize services
-	userService := services.NewUserService(gormDB)
-	authService := services.NewAuthService(gormDB)
-	courseService := services.NewCourseService(gormDB, cloudStorage)
-	moduleService := services.NewModuleService(gormDB, cloudStorage)
+	notificationService := services.NewNotificationService(gormDB, eventBus)
+	emailService := services.NewEmailService(gormDB)
+	auditService := services.NewAuditService(gormDB)
+	userService := services.NewUserService(gormDB, notificationService, auditService)
+	mfaService := services.NewMFAService(gormDB)
+	authService := services.NewAuthService(gormDB, emailService, mfaService)
+	oidcService := services.NewOIDCService(authService)
+	revisionService := services.NewRevisionService(gormDB)
+	courseService := services.NewCourseService(gormDB, cloudStorage, revisionService, eventBus, notificationService, emailService, auditService)
+	moduleService := services.NewModuleService(gormDB, cloudStorage, revisionService, eventBus, notificationService, emailService)
+	instructorService := services.NewInstructorService(gormDB)
+	payoutService := services.NewPayoutService(gormDB)
+	searchService := services.NewSearchService(gormDB)
+	reviewService := services.NewReviewService(gormDB)
+	commentService := services.NewCommentService(gormDB, notificationService)
+	eventService := services.NewEventService(gormDB, eventBus)
+	profileService := services.NewProfileService(gormDB, cloudStorage, emailService)
+	tokenService := services.NewPersonalAccessTokenService(gormDB)
 
 	// Initialize handlers
-	userHandler := handlers.NewUserHandler(userService)
+	userHandler := handlers.NewUserHandler(userService, auditService)
 	authHandler := handlers.NewAuthHandler(authService)
-	courseHandler := handlers.NewCourseHandler(courseService)
-	moduleHandler := handlers.NewModuleHandler(moduleService)
+	courseHandler := handlers.NewCourseHandler(courseService, auditService)
+	moduleHandler := handlers.NewModuleHandler(moduleService, auditService)
+	instructorHandler := handlers.NewInstructorHandler(instructorService, auditService)
+	payoutHandler := handlers.NewPayoutHandler(payoutService, auditService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	reviewHandler := handlers.NewReviewHandler(reviewService, auditService)
+	commentHandler := handlers.NewCommentHandler(commentService)
+	eventHandler := handlers.NewEventHandler(eventService)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+	profileHandler := handlers.NewProfileHandler(profileService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
+	tokenHandler := handlers.NewTokenHandler(tokenService)
+	jwksHandler := handlers.NewJWKSHandler(auth.Keys())
+	auditHandler := handlers.NewAuditHandler(auditService)
 
 	router := api.NewRouter(
 		userHandler,
 		authHandler,
 		courseHandler,
 		moduleHandler,
+		instructorHandler,
+		payoutHandler,
+		searchHandler,
+		reviewHandler,
+		commentHandler,
+		eventHandler,
+		notificationHandler,
+		profileHandler,
+		mfaHandler,
+		oidcHandler,
+		tokenHandler,
+		jwksHandler,
+		auditHandler,
 	)
+
+	// Start background jobs
+	jobs.Start(jobs.NewModuleReleaseJob(gormDB, notificationService, eventBus), 5*time.Minute, "MODULE_RELEASE_JOB_INTERVAL")
+	jobs.Start(jobs.NewCoursePublishJob(gormDB, eventBus), time.Minute, "COURSE_PUBLISH_JOB_INTERVAL")
+	jobs.Start(jobs.NewEmailOutboxJob(gormDB, email.NewSenderFromEnv()), 30*time.Second, "EMAIL_OUTBOX_JOB_INTERVAL")
+	jobs.Start(jobs.NewLoginThrottleCleanupJob(gormDB), time.Hour, "LOGIN_THROTTLE_CLEANUP_JOB_INTERVAL")
+
 	router.Start()
 
 }
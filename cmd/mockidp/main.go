@@ -0,0 +1,43 @@
+// Command mockidp is an OpenID Connect provider for trying out and testing "sign in with" locally.
+// It signs in whoever fills in its form, so it must never be exposed publicly.
+//
+// Configure grocademy with:
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER_URL=http://localhost:9000
+//	OIDC_MOCK_CLIENT_ID=grocademy
+//	OIDC_MOCK_CLIENT_SECRET=secret
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"grocademy/internal/sso/ssotest"
+)
+
+func main() {
+	port := getenv("MOCKIDP_PORT", "9000")
+
+	provider, err := ssotest.NewProvider(
+		getenv("MOCKIDP_ISSUER", "http://localhost:"+port),
+		getenv("MOCKIDP_CLIENT_ID", "grocademy"),
+		getenv("MOCKIDP_CLIENT_SECRET", "secret"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Mock IdP starting on :%s with issuer %s", port, provider.Issuer)
+	if err := http.ListenAndServe(":"+port, provider); err != nil {
+		log.Fatalf("Mock IdP failed to start: %v", err)
+	}
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/pagination"
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLogsRequest defines the filters and paging of the audit log.
+type GetAuditLogsRequest struct {
+	ActorID    *uint      `form:"actor_id"`
+	Action     string     `form:"action"`      // For example course.update, see models.AuditLog
+	TargetType string     `form:"target_type"` // user, course, module, review or payout
+	TargetID   string     `form:"target_id"`
+	RequestID  string     `form:"request_id"`
+	From       *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor     string     `form:"cursor"`
+	Limit      int64      `form:"limit,default=15"`
+	Count      bool       `form:"count"`
+	Format     string     `form:"format,default=json" binding:"oneof=json csv"`
+}
+
+type AuditHandler struct {
+	AuditService services.AuditServicer
+}
+
+func NewAuditHandler(auditService services.AuditServicer) *AuditHandler {
+	return &AuditHandler{AuditService: auditService}
+}
+
+// GetAuditLogs godoc
+// @Summary Get the audit log
+// @Description List who created, changed or deleted users, courses, modules, reviews and payouts, and who bought courses, newest first with keyset pagination.
+// @Description With format=csv every matching entry is exported instead, oldest first.
+// @Tags audit
+// @Produce  json
+// @Produce  text/csv
+// @Param actor_id query int false "User who performed the action"
+// @Param action query string false "Action, e.g. course.update or user.balance"
+// @Param target_type query string false "user, course, module, review or payout"
+// @Param target_id query string false "ID of the target"
+// @Param request_id query string false "X-Request-ID of the request that performed the action"
+// @Param from query string false "Only entries at or after this time (RFC 3339)"
+// @Param to query string false "Only entries before this time (RFC 3339)"
+// @Param cursor query string false "Keyset cursor from a previous page's next_cursor or prev_cursor"
+// @Param limit query int false "Items per page (default 15)"
+// @Param count query bool false "Also count the matching entries (default false)"
+// @Param format query string false "json (default) or csv"
+// @Success 200 {object} []models.AuditLog
+// @Failure 400 {object} map[string]string "Invalid filter or cursor"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /audit-logs [get]
+func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
+	var req GetAuditLogsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	filter := services.AuditLogFilter{
+		ActorID:    req.ActorID,
+		Action:     req.Action,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		RequestID:  req.RequestID,
+		From:       req.From,
+		To:         req.To,
+	}
+
+	if req.Format == "csv" {
+		h.exportAuditLogsCSV(c, filter)
+		return
+	}
+
+	limit := min(max(req.Limit, 1), 50)
+	logs, cursors, err := h.AuditService.GetAuditLogs(filter, req.Cursor, limit, req.Count)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	setCursorLinks(c, &cursors)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       logs,
+		"pagination": cursors,
+	})
+}
+
+// exportAuditLogsCSV streams the matching audit log. Errors after the first batch can only cut the file short.
+func (h *AuditHandler) exportAuditLogsCSV(c *gin.Context, filter services.AuditLogFilter) {
+	w := csv.NewWriter(c.Writer)
+	started := false
+
+	err := h.AuditService.ExportAuditLogs(filter, func(logs []models.AuditLog) error {
+		if !started {
+			startAuditLogCSV(c, w)
+			started = true
+		}
+		for _, log := range logs {
+			actorID := ""
+			if log.ActorID != nil {
+				actorID = strconv.FormatUint(uint64(*log.ActorID), 10)
+			}
+			w.Write([]string{
+				log.CreatedAt.Format(time.RFC3339),
+				log.Action,
+				actorID,
+				csvText(log.ActorUsername),
+				log.ActorRole,
+				log.TargetType,
+				log.TargetID,
+				csvText(auditSnapshotJSON(log.Before)),
+				csvText(auditSnapshotJSON(log.After)),
+				log.IPAddress,
+				log.RequestID,
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+	if err != nil && !started {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if !started {
+		// Nothing matched, still send the header row
+		startAuditLogCSV(c, w)
+		w.Flush()
+	}
+}
+
+func startAuditLogCSV(c *gin.Context, w *csv.Writer) {
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audit-log-%s.csv", time.Now().Format("20060102-150405")))
+	c.Status(http.StatusOK)
+	w.Write([]string{"date", "action", "actor_id", "actor_username", "actor_role", "target_type", "target_id", "before", "after", "ip_address", "request_id"})
+}
+
+func auditSnapshotJSON(snapshot map[string]interface{}) string {
+	if len(snapshot) == 0 {
+		return ""
+	}
+	bytes, _ := json.Marshal(snapshot)
+	return string(bytes)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"grocademy/internal/api/middlewares"
+	"grocademy/internal/auth"
 	_ "grocademy/internal/db/models"
 	"grocademy/internal/services" // Assuming services package contains AuthServicer
 
@@ -18,6 +21,7 @@ type RegisterRequest struct {
 	Password  string `json:"password" binding:"required,min=8"`
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name" binding:"required"`
+	Locale    string `json:"locale" binding:"omitempty,oneof=id en"` // Language of the emails sent to the user, defaults to id
 }
 
 type LoginRequest struct {
@@ -25,9 +29,35 @@ type LoginRequest struct {
 	Password   string `json:"password" binding:"required"`
 }
 
+type ForgotPasswordRequest struct {
+	Identifier string `json:"identifier" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
 type LoginData struct {
-	Username string `json:"username"`
-	Token    string `json:"token"`
+	Username         string   `json:"username"`
+	Token            string   `json:"token,omitempty"`
+	MFARequired      bool     `json:"mfa_required,omitempty"`       // The login has to be completed with a code at /auth/login/2fa
+	MFASetupRequired bool     `json:"mfa_setup_required,omitempty"` // The user's role requires 2FA, set it up with /auth/login/2fa/setup first
+	ChallengeToken   string   `json:"challenge_token,omitempty"`
+	RecoveryCodes    []string `json:"recovery_codes,omitempty"` // Shown once, when 2FA was set up during login
+}
+
+type LoginChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+type CompleteLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // Authenticator code or recovery code
 }
 
 type RegisterData struct {
@@ -64,7 +94,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.AuthService.RegisterUser(req.Username, req.Email, req.Password, req.FirstName, req.LastName)
+	user, err := h.AuthService.RegisterUser(req.Username, req.Email, req.Password, req.FirstName, req.LastName, req.Locale)
 	if err != nil {
 		if err.Error() == "username already taken" || err.Error() == "email already registered" {
 			c.AbortWithError(http.StatusBadRequest, err)
@@ -92,7 +122,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 // Login godoc
 // @Summary Log in a user
-// @Description Authenticate a user with email and password, and return a JWT token in an HttpOnly cookie
+// @Description Authenticate a user with email and password, and return a JWT token in an HttpOnly cookie.
+// @Description Users with two-factor authentication, or whose role requires it, get a challenge token to complete the login at /auth/login/2fa instead.
 // @Tags auth
 // @Accept  json
 // @Produce  json
@@ -100,6 +131,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} map[string]string "message: Login successful"
 // @Failure 400 {object} map[string]string "error: Invalid input"
 // @Failure 401 {object} map[string]string "error: Invalid credentials"
+// @Failure 429 {object} map[string]string "error: Too many failed logins, retry after the Retry-After header"
 // @Failure 500 {object} map[string]string "error: Internal server error"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -110,8 +142,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	site := c.DefaultQuery("site", "admin")
-	username, token, err := h.AuthService.LoginUser(req.Identifier, req.Password, site)
+	result, err := h.AuthService.LoginUser(req.Identifier, req.Password, site, c.ClientIP())
 	if err != nil {
+		if abortRateLimitError(c, err) {
+			return
+		}
 		if err.Error() == "invalid credentials" {
 			c.AbortWithError(http.StatusUnauthorized, err)
 			return
@@ -120,20 +155,25 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Set the JWT token as an HttpOnly cookie
-	c.SetCookie(
-		"jwt_token",              // Cookie name
-		token,                    // Cookie value (the JWT token)
-		int(time.Hour.Seconds()), // Max-Age: 1 hour (in seconds)
-		"/",                      // Path: Available across the entire domain
-		"",                       // Domain: Empty means current domain
-		false,                    // Secure: Set to true for HTTPS only in production
-		true,                     // HttpOnly: Prevent JavaScript access
-	)
+	if result.ChallengeToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "success",
+			"message": "Two-factor authentication required",
+			"data": LoginData{
+				Username:         result.Username,
+				MFARequired:      true,
+				MFASetupRequired: result.ChallengePurpose == auth.ChallengeMFASetup,
+				ChallengeToken:   result.ChallengeToken,
+			},
+		})
+		return
+	}
+
+	setSessionCookie(c, result.Token)
 
 	data := LoginData{
-		Username: username,
-		Token:    token,
+		Username: result.Username,
+		Token:    result.Token,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -144,6 +184,260 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 }
 
+// BeginLoginTOTPSetup godoc
+// @Summary Set up 2FA during login
+// @Description For users whose role requires two-factor authentication but who have not set it up, generate an authenticator secret and QR code. Confirm it with a code at /auth/login/2fa.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body LoginChallengeRequest true "Challenge token from /auth/login"
+// @Success 200 {object} services.TOTPSetup
+// @Failure 400 {object} map[string]string "error: Invalid input or 2FA already set up"
+// @Failure 401 {object} map[string]string "error: Invalid or expired challenge token"
+// @Failure 500 {object} map[string]string "error: Internal server error"
+// @Router /auth/login/2fa/setup [post]
+func (h *AuthHandler) BeginLoginTOTPSetup(c *gin.Context) {
+	var req LoginChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	setup, err := h.AuthService.BeginLoginTOTPSetup(req.ChallengeToken)
+	if err != nil {
+		abortMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Scan the QR code with your authenticator app",
+		"data":    setup,
+	})
+}
+
+// CompleteLogin godoc
+// @Summary Complete a login with 2FA
+// @Description Complete a login with an authenticator code or a recovery code, and return a JWT token in an HttpOnly cookie.
+// @Description When 2FA was set up during this login, the response holds the user's recovery codes.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body CompleteLoginRequest true "Challenge token from /auth/login and authentication code"
+// @Success 200 {object} LoginData
+// @Failure 400 {object} map[string]string "error: Invalid input"
+// @Failure 401 {object} map[string]string "error: Invalid code or invalid or expired challenge token"
+// @Failure 429 {object} map[string]string "error: Too many attempts"
+// @Failure 500 {object} map[string]string "error: Internal server error"
+// @Router /auth/login/2fa [post]
+func (h *AuthHandler) CompleteLogin(c *gin.Context) {
+	var req CompleteLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.AuthService.CompleteLogin(req.ChallengeToken, req.Code)
+	if err != nil {
+		abortMFAError(c, err)
+		return
+	}
+
+	setSessionCookie(c, result.Token)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Login successful",
+		"data": LoginData{
+			Username:      result.Username,
+			Token:         result.Token,
+			RecoveryCodes: result.RecoveryCodes,
+		},
+	})
+}
+
+// setSessionCookie sets the JWT token as an HttpOnly cookie
+func setSessionCookie(c *gin.Context, token string) {
+	middlewares.SetCookie(c, "jwt_token", token, int(time.Hour.Seconds()), "/", true)
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset link to the account with the given email or username. The response is the same whether or not the account exists.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body ForgotPasswordRequest true "Email or username of the account"
+// @Success 202 {object} map[string]string "message: Reset link sent if the account exists"
+// @Failure 400 {object} map[string]string "error: Invalid input"
+// @Failure 429 {object} map[string]string "error: Too many requests"
+// @Failure 500 {object} map[string]string "error: Internal server error"
+// @Router /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.AuthService.RequestPasswordReset(req.Identifier); err != nil {
+		if abortRateLimitError(c, err) {
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "If an account with that email or username exists, a reset link has been sent to it",
+		"data":    nil,
+	})
+}
+
+// ResetPassword godoc
+// @Summary Reset a password
+// @Description Set a new password with an emailed reset token. The token can be used once, every existing session of the user is signed out and their personal access tokens are revoked.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string "message: Password reset"
+// @Failure 400 {object} map[string]string "error: Invalid input, weak password, or invalid or expired token"
+// @Failure 500 {object} map[string]string "error: Internal server error"
+// @Router /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.AuthService.ResetPassword(req.Token, req.Password); err != nil {
+		if errors.Is(err, services.ErrInvalidUserToken) || err.Error() == "password is weak" {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Password reset, please log in again",
+		"data":    nil,
+	})
+}
+
+// VerifyEmail godoc
+// @Summary Verify an email address
+// @Description Verify the email address of an account with the token from the emailed verification link
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body VerifyEmailRequest true "Verification token"
+// @Success 200 {object} map[string]string "message: Email verified"
+// @Failure 400 {object} map[string]string "error: Invalid input or invalid or expired token"
+// @Failure 409 {object} map[string]string "error: Email already verified"
+// @Failure 500 {object} map[string]string "error: Internal server error"
+// @Router /auth/email/verify [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.AuthService.VerifyEmail(req.Token); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUserToken):
+			c.AbortWithError(http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			c.AbortWithError(http.StatusConflict, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Email verified",
+		"data":    nil,
+	})
+}
+
+// ConfirmEmailChange godoc
+// @Summary Confirm a new email address
+// @Description Replace the email address of an account with the one it is being changed to, with the token from the confirmation link emailed to the new address
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body VerifyEmailRequest true "Confirmation token"
+// @Success 200 {object} map[string]string "message: Email changed"
+// @Failure 400 {object} map[string]string "error: Invalid input or invalid or expired token"
+// @Failure 409 {object} map[string]string "error: Email already registered"
+// @Failure 500 {object} map[string]string "error: Internal server error"
+// @Router /auth/email/change/confirm [post]
+func (h *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.AuthService.ConfirmEmailChange(req.Token); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUserToken):
+			c.AbortWithError(http.StatusBadRequest, err)
+		case err.Error() == "email already registered":
+			c.AbortWithError(http.StatusConflict, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Email changed",
+		"data":    nil,
+	})
+}
+
+// ResendVerificationEmail godoc
+// @Summary Resend the verification email
+// @Description Email the authenticated user a new verification link. The previous link stops working.
+// @Tags auth
+// @Produce  json
+// @Success 202 {object} map[string]string "message: Verification email sent"
+// @Failure 409 {object} map[string]string "error: Email already verified"
+// @Failure 429 {object} map[string]string "error: Too many requests"
+// @Failure 500 {object} map[string]string "error: Internal server error"
+// @Security Bearer
+// @Router /auth/email/resend [post]
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	userID, _ := currentUser(c)
+
+	if err := h.AuthService.ResendVerificationEmail(userID); err != nil {
+		if abortRateLimitError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrEmailAlreadyVerified) {
+			c.AbortWithError(http.StatusConflict, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "Verification email sent",
+		"data":    nil,
+	})
+}
+
 // Self godoc
 // @Summary Get current user
 // @Description Get currently logged-in user, based on token
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateThreadRequest defines the request body for starting a thread in a module's discussion.
+type CreateThreadRequest struct {
+	Kind string `json:"kind" binding:"required,oneof=question discussion"`
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+// CommentBodyRequest defines the request body for replying to or editing a comment.
+type CommentBodyRequest struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+// AcceptAnswerRequest defines the request body for marking an answer as accepted.
+type AcceptAnswerRequest struct {
+	Accepted *bool `json:"accepted" binding:"required"`
+}
+
+// PinThreadRequest defines the request body for pinning a thread.
+type PinThreadRequest struct {
+	Pinned *bool `json:"pinned" binding:"required"`
+}
+
+type CommentHandler struct {
+	CommentService services.CommentServicer
+}
+
+func NewCommentHandler(commentService services.CommentServicer) *CommentHandler {
+	return &CommentHandler{CommentService: commentService}
+}
+
+// GetThreads godoc
+// @Summary Get a module's discussion threads
+// @Description Retrieve the questions and discussions of a module, pinned ones first and then the most recently active
+// @Tags comments
+// @Produce  json
+// @Param id path int true "Module ID"
+// @Param type query string false "question or discussion"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Param q query string false "Search query"
+// @Success 200 {object} []services.CommentView
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not enrolled in the course"
+// @Failure 404 {object} map[string]string "Module not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /modules/{id}/comments [get]
+func (h *CommentHandler) GetThreads(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid module ID"))
+		return
+	}
+
+	kind := c.DefaultQuery("type", "")
+	if kind != "" && kind != "question" && kind != "discussion" {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid thread type"))
+		return
+	}
+
+	page, limit, ok := commentPage(c)
+	if !ok {
+		return
+	}
+	query := c.DefaultQuery("q", "")
+
+	userID, role := currentUser(c)
+
+	threads, pagination, err := h.CommentService.GetThreads(userID, role, uint(id), kind, page, limit, query)
+	if abortCommentError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       threads,
+		"pagination": pagination,
+	})
+}
+
+// CreateThread godoc
+// @Summary Start a discussion thread
+// @Description Ask a question or start a discussion on a module. Mentioned @usernames are notified.
+// @Tags comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Module ID"
+// @Param thread body CreateThreadRequest true "Thread kind and text"
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not enrolled in the course"
+// @Failure 404 {object} map[string]string "Module not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /modules/{id}/comments [post]
+func (h *CommentHandler) CreateThread(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid module ID"))
+		return
+	}
+
+	var req CreateThreadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, role := currentUser(c)
+
+	comment, err := h.CommentService.CreateThread(userID, role, uint(id), req.Kind, req.Body)
+	if abortCommentError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "thread created",
+		"data":    comment,
+	})
+}
+
+// GetThread godoc
+// @Summary Get a discussion thread
+// @Description Retrieve a thread with a page of its replies, the accepted answer first and then oldest first
+// @Tags comments
+// @Produce  json
+// @Param id path int true "Thread ID"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Success 200 {object} services.CommentThread
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not enrolled in the course"
+// @Failure 404 {object} map[string]string "Thread not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /comments/{id} [get]
+func (h *CommentHandler) GetThread(c *gin.Context) {
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+	page, limit, ok := commentPage(c)
+	if !ok {
+		return
+	}
+
+	userID, role := currentUser(c)
+
+	thread, pagination, err := h.CommentService.GetThread(userID, role, id, page, limit)
+	if abortCommentError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       thread,
+		"pagination": pagination,
+	})
+}
+
+// CreateReply godoc
+// @Summary Reply to a comment
+// @Description Reply to a thread or to another reply. Direct replies to a question are answers. Mentioned @usernames are notified.
+// @Tags comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Comment ID"
+// @Param reply body CommentBodyRequest true "Reply text"
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not enrolled in the course"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /comments/{id}/replies [post]
+func (h *CommentHandler) CreateReply(c *gin.Context) {
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	var req CommentBodyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, role := currentUser(c)
+
+	comment, err := h.CommentService.CreateReply(userID, role, id, req.Body)
+	if abortCommentError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "reply created",
+		"data":    comment,
+	})
+}
+
+// UpdateComment godoc
+// @Summary Edit a comment
+// @Description Edit the text of one of your comments. Newly mentioned @usernames are notified.
+// @Tags comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Comment ID"
+// @Param comment body CommentBodyRequest true "New text"
+// @Success 200 {object} models.Comment
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not the author"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /comments/{id} [put]
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	var req CommentBodyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, role := currentUser(c)
+
+	comment, err := h.CommentService.UpdateComment(userID, role, id, req.Body)
+	if abortCommentError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "comment updated",
+		"data":    comment,
+	})
+}
+
+// DeleteComment godoc
+// @Summary Delete a comment
+// @Description Delete one of your comments, or any comment as an instructor of the course. Deleting a thread deletes its replies.
+// @Tags comments
+// @Produce  json
+// @Param id path int true "Comment ID"
+// @Success 204 "Comment deleted"
+// @Failure 400 {object} map[string]string "Invalid comment ID"
+// @Failure 403 {object} map[string]string "Not the author or a moderator"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /comments/{id} [delete]
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	userID, role := currentUser(c)
+
+	if abortCommentError(c, h.CommentService.DeleteComment(userID, role, id)) {
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// VoteComment godoc
+// @Summary Upvote a comment
+// @Description Upvote a comment, or withdraw the upvote with DELETE
+// @Tags comments
+// @Produce  json
+// @Param id path int true "Comment ID"
+// @Success 200 {object} models.Comment
+// @Failure 400 {object} map[string]string "Invalid comment ID"
+// @Failure 403 {object} map[string]string "Not enrolled in the course"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /comments/{id}/vote [put]
+// @Router /comments/{id}/vote [delete]
+func (h *CommentHandler) VoteComment(c *gin.Context) {
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	userID, role := currentUser(c)
+
+	comment, err := h.CommentService.VoteComment(userID, role, id, c.Request.Method != http.MethodDelete)
+	if abortCommentError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "vote saved",
+		"data":    comment,
+	})
+}
+
+// AcceptAnswer godoc
+// @Summary Accept an answer
+// @Description Mark an answer as the accepted answer of its question, or unmark it. Only the course's instructors and admins can do this.
+// @Tags comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Answer ID"
+// @Param accept body AcceptAnswerRequest true "Whether the answer is accepted"
+// @Success 200 {object} models.Comment "The question"
+// @Failure 400 {object} map[string]string "Invalid input or not an answer"
+// @Failure 403 {object} map[string]string "Not an instructor of the course"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /comments/{id}/accept [patch]
+func (h *CommentHandler) AcceptAnswer(c *gin.Context) {
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	var req AcceptAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, role := currentUser(c)
+
+	question, err := h.CommentService.AcceptAnswer(userID, role, id, *req.Accepted)
+	if abortCommentError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "answer updated",
+		"data":    question,
+	})
+}
+
+// PinThread godoc
+// @Summary Pin a thread
+// @Description Pin a thread to the top of the module's discussion, or unpin it. Only the course's instructors and admins can do this.
+// @Tags comments
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Thread ID"
+// @Param pin body PinThreadRequest true "Whether the thread is pinned"
+// @Success 200 {object} models.Comment
+// @Failure 400 {object} map[string]string "Invalid input or not a thread"
+// @Failure 403 {object} map[string]string "Not an instructor of the course"
+// @Failure 404 {object} map[string]string "Comment not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /comments/{id}/pin [patch]
+func (h *CommentHandler) PinThread(c *gin.Context) {
+	id, ok := commentID(c)
+	if !ok {
+		return
+	}
+
+	var req PinThreadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, role := currentUser(c)
+
+	thread, err := h.CommentService.PinThread(userID, role, id, *req.Pinned)
+	if abortCommentError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "thread updated",
+		"data":    thread,
+	})
+}
+
+func commentID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid comment ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func commentPage(c *gin.Context) (int64, int64, bool) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return 0, 0, false
+	}
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "15"), 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return 0, 0, false
+	}
+	return page, min(limit, 50), true
+}
+
+// abortCommentError aborts the request with the status matching a discussion error. It reports whether there was one.
+func abortCommentError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, services.ErrNotInDiscussion) || errors.Is(err, services.ErrNotDiscussionModerator) {
+		c.AbortWithError(http.StatusForbidden, err)
+		return true
+	}
+	switch err.Error() {
+	case "module not found", "comment not found":
+		c.AbortWithError(http.StatusNotFound, err)
+	case "only the author can edit a comment":
+		c.AbortWithError(http.StatusForbidden, err)
+	case "invalid thread kind", "only answers to a question can be accepted", "only threads can be pinned":
+		c.AbortWithError(http.StatusBadRequest, err)
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+	return true
+}
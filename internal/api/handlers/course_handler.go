@@ -6,6 +6,8 @@ import (
 	"mime/multipart"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"grocademy/internal/db/models"
 	"grocademy/internal/pkg/string_array"
@@ -16,35 +18,71 @@ import (
 
 type CourseResponse struct {
 	models.Course
-	TotalModules int64 `json:"total_modules"`
-	Purchased    bool  `json:"purchased"`
+	TotalModules  int64                               `json:"total_modules"`
+	Purchased     bool                                `json:"purchased"`
+	Prerequisites []services.CoursePrerequisiteStatus `json:"prerequisites"`
+}
+
+// ChangeCourseStatusRequest defines the request body for moving a course through its publication workflow.
+type ChangeCourseStatusRequest struct {
+	Status    string     `json:"status" binding:"required,oneof=draft in_review published archived"`
+	PublishAt *time.Time `json:"publish_at"` // Only used when publishing, schedules the publication
+}
+
+// SetCoursePrerequisitesRequest defines the request body for replacing a course's prerequisites.
+type SetCoursePrerequisitesRequest struct {
+	PrerequisiteIDs []uint `json:"prerequisite_ids" binding:"required"`
 }
 
 type CreateCourseRequest struct {
-	Title          string                `form:"title" binding:"required"`
-	Description    string                `form:"description" binding:"required"`
-	Instructor     string                `form:"instructor" binding:"required"`
-	Topics         []string              `form:"topics" binding:"required"` // Bind as a single string, then split
-	Price          float64               `form:"price" binding:"required"`
-	ThumbnailImage *multipart.FileHeader `form:"thumbnail_image"` // The binary image file
+	Title             string                `form:"title" binding:"required"`
+	Description       string                `form:"description" binding:"required"`
+	Instructor        string                `form:"instructor" binding:"required"`
+	Topics            []string              `form:"topics" binding:"required"` // Bind as a single string, then split
+	Price             float64               `form:"price" binding:"required"`
+	ThumbnailImage    *multipart.FileHeader `form:"thumbnail_image"` // The binary image file
+	SequentialModules bool                  `form:"sequential_modules"`
+	OwnerID           uint                  `form:"owner_id"` // Instructor account owning the course, ignored when an instructor creates it
 }
 
 // For partial updates, fields are optional.
 type UpdateCourseRequest struct {
-	Title          string                `form:"title,omitempty"`
-	Description    string                `form:"description,omitempty"`
-	Instructor     string                `form:"instructor,omitempty"`
-	Topics         []string              `form:"topics,omitempty"`
-	Price          *float64              `form:"price,omitempty"`           // Use pointer for float64 to distinguish 0 from unset
-	ThumbnailImage *multipart.FileHeader `form:"thumbnail_image,omitempty"` // Optional file upload
+	Title             string                `form:"title,omitempty"`
+	Description       string                `form:"description,omitempty"`
+	Instructor        string                `form:"instructor,omitempty"`
+	Topics            []string              `form:"topics,omitempty"`
+	Price             *float64              `form:"price,omitempty"`           // Use pointer for float64 to distinguish 0 from unset
+	ThumbnailImage    *multipart.FileHeader `form:"thumbnail_image,omitempty"` // Optional file upload
+	SequentialModules *bool                 `form:"sequential_modules,omitempty"`
+	RevenueShare      *float64              `form:"revenue_share,omitempty" binding:"omitempty,min=0,max=100"` // Admin only
+	ClearRevenueShare bool                  `form:"clear_revenue_share,omitempty"`                             // Fall back to the instructor's share
+}
+
+// CourseCatalogQuery defines the query parameters for browsing the course catalog.
+type CourseCatalogQuery struct {
+	Page         int64    `form:"page,default=1"`
+	Limit        int64    `form:"limit,default=15"`
+	Query        string   `form:"q"`
+	Topics       string   `form:"topics"` // Comma-separated
+	TopicsMatch  string   `form:"topics_match" binding:"omitempty,oneof=any all"`
+	MinPrice     *float64 `form:"min_price" binding:"omitempty,gte=0"`
+	MaxPrice     *float64 `form:"max_price" binding:"omitempty,gte=0"`
+	Free         bool     `form:"free"`
+	Instructor   string   `form:"instructor"`
+	InstructorID uint     `form:"instructor_id"`
+	HasVideo     *bool    `form:"has_video"`
+	MinRating    *float64 `form:"min_rating" binding:"omitempty,gte=0,lte=5"`
+	Enrolled     *bool    `form:"enrolled"`
+	Sort         string   `form:"sort" binding:"omitempty,oneof=relevance newest price_asc price_desc popularity rating title"`
 }
 
 type CourseHandler struct {
 	CourseService services.CourseServicer
+	AuditService  services.AuditServicer
 }
 
-func NewCourseHandler(courseService services.CourseServicer) *CourseHandler {
-	return &CourseHandler{CourseService: courseService}
+func NewCourseHandler(courseService services.CourseServicer, auditService services.AuditServicer) *CourseHandler {
+	return &CourseHandler{CourseService: courseService, AuditService: auditService}
 }
 
 // CreateCourse handles the POST request to create a new course.
@@ -58,7 +96,9 @@ func NewCourseHandler(courseService services.CourseServicer) *CourseHandler {
 // @Param instructor formData string true "Course instructor"
 // @Param topics formData string true "List of topics"
 // @Param price formData number true "Course price"
+// @Param sequential_modules formData boolean false "Unlock modules one by one in order"
 // @Param thumbnail_image formData file false "Thumbnail image file"
+// @Param owner_id formData int false "Owning instructor's user ID (admin only)"
 // @Success 201 {object} models.Course
 // @Failure 400 {object} map[string]string "Invalid input"
 // @Failure 500 {object} map[string]string "Internal server error"
@@ -71,18 +111,34 @@ func (h *CourseHandler) CreateCourse(c *gin.Context) {
 		return
 	}
 
+	userID, role := currentUser(c)
+
+	// Instructors always own the courses they create
+	ownerID := req.OwnerID
+	if role == models.UserRoleInstructor {
+		ownerID = userID
+	}
+
 	newCourse, err := h.CourseService.CreateCourse(
+		userID,
+		ownerID,
 		req.Title,
 		req.Description,
 		req.Instructor,
 		req.Topics,
 		req.Price,
+		req.SequentialModules,
 		req.ThumbnailImage,
 	)
 	if err != nil {
+		if err.Error() == "user not found" || err.Error() == "user is not an instructor" {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to create course: %v", err))
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditCourseCreate, models.AuditTargetCourse, newCourse.ID, nil, newCourse)
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -111,9 +167,12 @@ func (h *CourseHandler) GetCourseByID(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("id")
+	userID, role := currentUser(c)
 
-	course, totalModules, purchased, err := h.CourseService.GetCourseByID(userID.(uint), uint(id))
+	// Admins and the course's own instructors can see it before it is published
+	includeUnpublished := isAdmin(c) || (role == models.UserRoleInstructor && h.CourseService.AuthorizeCourse(userID, role, uint(id)) == nil)
+
+	course, totalModules, purchased, err := h.CourseService.GetCourseByID(userID, uint(id), includeUnpublished)
 	if err != nil {
 		if err.Error() == "course not found" {
 			c.AbortWithError(http.StatusNotFound, err)
@@ -123,10 +182,17 @@ func (h *CourseHandler) GetCourseByID(c *gin.Context) {
 		return
 	}
 
+	prerequisites, err := h.CourseService.GetCoursePrerequisites(userID, uint(id))
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
 	response := CourseResponse{
-		Course:       *course,
-		TotalModules: totalModules,
-		Purchased:    purchased,
+		Course:        *course,
+		TotalModules:  totalModules,
+		Purchased:     purchased,
+		Prerequisites: prerequisites,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -137,35 +203,58 @@ func (h *CourseHandler) GetCourseByID(c *gin.Context) {
 }
 
 // GetAllCourses godoc
-// @Summary Get all courses with pagination and search
-// @Description Retrieve a list of all courses with optional pagination and search parameters
+// @Summary Get all courses with pagination, search, filters and sorting
+// @Description Retrieve a list of all courses with optional pagination, search, filter and sort parameters, along with topic and price facet counts
 // @Tags courses
 // @Produce  json
 // @Param page query int false "Page number (default 1)"
 // @Param limit query int false "Items per page (default 15)"
 // @Param q query string false "Search query"
+// @Param topics query string false "Comma-separated topics"
+// @Param topics_match query string false "any (default) or all of the topics"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param free query bool false "Only free courses"
+// @Param instructor query string false "Part of the instructor's name"
+// @Param instructor_id query int false "Instructor account ID"
+// @Param has_video query bool false "Whether the course has video modules"
+// @Param min_rating query number false "Minimum average rating"
+// @Param enrolled query bool false "Whether I bought the course"
+// @Param sort query string false "relevance (default when searching), newest (default), price_asc, price_desc, popularity, rating or title"
 // @Success 200 {object} []models.Course
+// @Failure 400 {object} map[string]string "Invalid input"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Security Bearer
 // @Router /courses [get]
 func (h *CourseHandler) GetAllCourses(c *gin.Context) {
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "15")
-	query := c.DefaultQuery("q", "")
-
-	page, err := strconv.ParseInt(pageStr, 10, 64)
-	if err != nil {
-		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+	var req CourseCatalogQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
-	limit, err := strconv.ParseInt(limitStr, 10, 64)
-	if err != nil {
-		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
-		return
+	limit := min(req.Limit, 50)
+
+	filter := services.CourseFilter{
+		TopicsMatch:  req.TopicsMatch,
+		MinPrice:     req.MinPrice,
+		MaxPrice:     req.MaxPrice,
+		FreeOnly:     req.Free,
+		Instructor:   req.Instructor,
+		InstructorID: req.InstructorID,
+		HasVideo:     req.HasVideo,
+		MinRating:    req.MinRating,
+		Enrolled:     req.Enrolled,
+		Sort:         req.Sort,
+	}
+	for _, topic := range strings.Split(req.Topics, ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			filter.Topics = append(filter.Topics, topic)
+		}
 	}
-	limit = min(limit, 50)
 
-	paginatedCourses, pagination, err := h.CourseService.GetAllCoursesPaginated(int64(page), int64(limit), query)
+	userID, _ := currentUser(c)
+
+	paginatedCourses, pagination, facets, err := h.CourseService.GetAllCoursesPaginated(userID, req.Page, limit, req.Query, filter, isAdmin(c))
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -176,6 +265,7 @@ func (h *CourseHandler) GetAllCourses(c *gin.Context) {
 		"message":    "Query success",
 		"data":       paginatedCourses,
 		"pagination": pagination,
+		"facets":     facets,
 	})
 }
 
@@ -245,8 +335,13 @@ func (h *CourseHandler) BuyCourse(c *gin.Context) {
 	}
 	userID, _ := c.Get("id")
 
-	balance, transactionID, err := h.CourseService.BuyCourse(userID.(uint), uint(id))
+	balance, transactionID, err := h.CourseService.BuyCourse(userID.(uint), uint(id), auditActor(c))
 	if err != nil {
+		if errors.Is(err, services.ErrUnmetPrerequisites) || errors.Is(err, services.ErrEmailNotVerified) ||
+			err.Error() == "course is not available for purchase" {
+			c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
@@ -274,7 +369,10 @@ func (h *CourseHandler) BuyCourse(c *gin.Context) {
 // @Param instructor formData string false "Course instructor"
 // @Param topics formData string false "Comma-separated list of topics"
 // @Param price formData number false "Course price"
+// @Param sequential_modules formData boolean false "Unlock modules one by one in order"
 // @Param thumbnail_image formData file false "New thumbnail image file"
+// @Param revenue_share formData number false "Instructor's percentage of each sale (admin only)"
+// @Param clear_revenue_share formData boolean false "Set to true to use the instructor's share again (admin only)"
 // @Success 200 {object} models.Course "Updated course object"
 // @Failure 400 {object} map[string]string "Invalid input or no fields to update"
 // @Failure 404 {object} map[string]string "Course not found"
@@ -289,6 +387,11 @@ func (h *CourseHandler) UpdateCourse(c *gin.Context) {
 		return
 	}
 
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.CourseService.AuthorizeCourse(userID, role, uint(id))) {
+		return
+	}
+
 	var req UpdateCourseRequest
 	// Use c.ShouldBind to handle multipart/form-data
 	if err := c.ShouldBind(&req); err != nil {
@@ -309,17 +412,33 @@ func (h *CourseHandler) UpdateCourse(c *gin.Context) {
 	if req.Price != nil {
 		updates["Price"] = *req.Price
 	}
+	if req.SequentialModules != nil {
+		updates["SequentialModules"] = *req.SequentialModules
+	}
 
 	if req.Topics != nil {
 		updates["Topics"] = string_array.StringArray(req.Topics)
 	}
 
+	if req.RevenueShare != nil || req.ClearRevenueShare {
+		if role != models.UserRoleAdmin {
+			c.AbortWithError(http.StatusForbidden, errors.New("only admins can change the revenue share"))
+			return
+		}
+		if req.ClearRevenueShare {
+			updates["RevenueShare"] = nil
+		} else {
+			updates["RevenueShare"] = *req.RevenueShare
+		}
+	}
+
 	if len(updates) == 0 && req.ThumbnailImage == nil {
 		c.AbortWithError(http.StatusBadRequest, errors.New("no fields to update provided"))
 		return
 	}
 
-	updatedCourse, err := h.CourseService.UpdateCourse(uint(id), updates, req.ThumbnailImage)
+	before := h.AuditService.Snapshot(models.AuditTargetCourse, uint(id))
+	updatedCourse, err := h.CourseService.UpdateCourse(uint(id), userID, updates, req.ThumbnailImage)
 	if err != nil {
 		if err.Error() == "course not found" {
 			c.AbortWithError(http.StatusNotFound, err)
@@ -328,6 +447,7 @@ func (h *CourseHandler) UpdateCourse(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditCourseUpdate, models.AuditTargetCourse, id, before, updatedCourse)
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -356,6 +476,7 @@ func (h *CourseHandler) DeleteCourse(c *gin.Context) {
 		return
 	}
 
+	before := h.AuditService.Snapshot(models.AuditTargetCourse, uint(id))
 	if err := h.CourseService.DeleteCourse(uint(id)); err != nil {
 		if err.Error() == "course not found" {
 			c.AbortWithError(http.StatusNotFound, err)
@@ -364,6 +485,283 @@ func (h *CourseHandler) DeleteCourse(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditCourseDelete, models.AuditTargetCourse, id, before, nil)
 
 	c.Status(http.StatusNoContent)
 }
+
+// SetCoursePrerequisites godoc
+// @Summary Set a course's prerequisites
+// @Description Replace the courses that must be finished before this course can be bought
+// @Tags courses
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param prerequisites body SetCoursePrerequisitesRequest true "Prerequisite course IDs"
+// @Success 200 {object} []uint
+// @Failure 400 {object} map[string]string "Invalid input or cycle"
+// @Failure 404 {object} map[string]string "Course not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/prerequisites [put]
+func (h *CourseHandler) SetCoursePrerequisites(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.CourseService.AuthorizeCourse(userID, role, uint(id))) {
+		return
+	}
+
+	var req SetCoursePrerequisitesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.CourseService.SetCoursePrerequisites(uint(id), req.PrerequisiteIDs); err != nil {
+		switch err.Error() {
+		case "course not found":
+			c.AbortWithError(http.StatusNotFound, err)
+		case "course cannot be its own prerequisite",
+			"some prerequisite course IDs are invalid",
+			"prerequisites would create a cycle":
+			c.AbortWithError(http.StatusBadRequest, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditCoursePrerequisites, models.AuditTargetCourse, id, nil, req)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "prerequisites updated",
+		"data": gin.H{
+			"course_id":        id,
+			"prerequisite_ids": req.PrerequisiteIDs,
+		},
+	})
+}
+
+// ChangeCourseStatus godoc
+// @Summary Change a course's publication status
+// @Description Move a course between draft, in_review, published and archived. Publishing with a future publish_at schedules it.
+// @Tags courses
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param status body ChangeCourseStatusRequest true "New status and optional publication schedule"
+// @Success 200 {object} models.Course
+// @Failure 400 {object} map[string]string "Invalid status or transition"
+// @Failure 404 {object} map[string]string "Course not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/status [patch]
+func (h *CourseHandler) ChangeCourseStatus(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.CourseService.AuthorizeCourse(userID, role, uint(id))) {
+		return
+	}
+
+	var req ChangeCourseStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	// Instructors can only move their course between draft and review, publishing is up to admins
+	if role != models.UserRoleAdmin && req.Status != models.CourseStatusDraft && req.Status != models.CourseStatusInReview {
+		c.AbortWithError(http.StatusForbidden, errors.New("only admins can publish or archive courses"))
+		return
+	}
+
+	before := h.AuditService.Snapshot(models.AuditTargetCourse, uint(id))
+	course, err := h.CourseService.ChangeCourseStatus(uint(id), req.Status, req.PublishAt)
+	if err != nil {
+		if err.Error() == "course not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidStatusTransition) || err.Error() == "invalid course status" {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditCourseStatus, models.AuditTargetCourse, id, before, course)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "course status updated",
+		"data":    course,
+	})
+}
+
+// GetCourseRevisions godoc
+// @Summary List a course's revisions
+// @Description Retrieve the revision history of a course, newest first
+// @Tags courses
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Success 200 {object} []models.Revision
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/revisions [get]
+func (h *CourseHandler) GetCourseRevisions(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.CourseService.AuthorizeCourse(userID, role, uint(id))) {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "15")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return
+	}
+	limit = min(limit, 50)
+
+	revisions, pagination, err := h.CourseService.GetCourseRevisions(uint(id), page, limit)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       revisions,
+		"pagination": pagination,
+	})
+}
+
+// GetCourseRevision godoc
+// @Summary Get one revision of a course
+// @Description Retrieve a course revision with its snapshot, diff and retained storage objects
+// @Tags courses
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param version path int true "Revision version"
+// @Success 200 {object} models.Revision
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Revision not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/revisions/{version} [get]
+func (h *CourseHandler) GetCourseRevision(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.CourseService.AuthorizeCourse(userID, role, uint(id))) {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid revision version"))
+		return
+	}
+
+	revision, err := h.CourseService.GetCourseRevision(uint(id), version)
+	if err != nil {
+		if err.Error() == "revision not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    revision,
+	})
+}
+
+// RestoreCourseRevision godoc
+// @Summary Restore a course revision
+// @Description Put the content of an earlier revision back on the course. The restore is recorded as a new revision.
+// @Tags courses
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param version path int true "Revision version"
+// @Success 200 {object} models.Course
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Course or revision not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/revisions/{version}/restore [post]
+func (h *CourseHandler) RestoreCourseRevision(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.CourseService.AuthorizeCourse(userID, role, uint(id))) {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid revision version"))
+		return
+	}
+
+	before := h.AuditService.Snapshot(models.AuditTargetCourse, uint(id))
+	course, revision, err := h.CourseService.RestoreCourseRevision(uint(id), userID, version)
+	if err != nil {
+		if err.Error() == "course not found" || err.Error() == "revision not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditCourseRestore, models.AuditTargetCourse, id, before, course)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "course restored",
+		"data": gin.H{
+			"course":   course,
+			"revision": revision,
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment, so that proxies keep it open.
+const eventKeepAlive = 25 * time.Second
+
+type EventHandler struct {
+	EventService services.EventServicer
+}
+
+func NewEventHandler(eventService services.EventServicer) *EventHandler {
+	return &EventHandler{EventService: eventService}
+}
+
+// StreamEvents godoc
+// @Summary Stream real-time updates
+// @Description Stream Server-Sent Events about courses: course.created, course.updated, course.deleted, course.purchased, module.added and progress.changed.
+// @Description Users receive changes to the public catalog, their own progress, and events of the courses they are enrolled in or teach.
+// @Tags events
+// @Produce  text/event-stream
+// @Success 200 {object} events.Event
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /events [get]
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	userID, role := currentUser(c)
+
+	stream, unsubscribe, err := h.EventService.Subscribe(userID, role)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	// Tell the client it is connected, so that it can refresh whatever it missed while disconnected
+	c.SSEvent("ready", gin.H{"time": time.Now()})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-stream:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		}
+		return true
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/pagination"
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isAdmin reports whether the authenticated user of the request is an admin.
+func isAdmin(c *gin.Context) bool {
+	role, _ := c.Get("role")
+	return role == models.UserRoleAdmin
+}
+
+// currentUser returns the ID and role of the authenticated user of the request.
+func currentUser(c *gin.Context) (uint, string) {
+	userID, _ := c.Get("id")
+	return userID.(uint), c.GetString("role")
+}
+
+// abortAccessError responds to a failed course access check and reports whether the request was aborted.
+func abortAccessError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	switch {
+	case errors.Is(err, services.ErrNotCourseInstructor):
+		c.AbortWithError(http.StatusForbidden, err)
+	case err.Error() == "course not found" || err.Error() == "module not found":
+		c.AbortWithError(http.StatusNotFound, err)
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+	return true
+}
+
+// abortRateLimitError responds 429 with a Retry-After header to a rate limited request and reports
+// whether the request was aborted.
+func abortRateLimitError(c *gin.Context, err error) bool {
+	var rateLimitErr *services.RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))))
+	c.AbortWithError(http.StatusTooManyRequests, err)
+	return true
+}
+
+// auditActor is the audit log entry of an action of the authenticated user, with the client IP and request
+// ID filled in. Services that must not act unrecorded fill in the rest within their transaction.
+func auditActor(c *gin.Context) services.AuditEntry {
+	actorID, actorRole := currentUser(c)
+	return services.AuditEntry{
+		ActorID:       actorID,
+		ActorUsername: c.GetString("username"),
+		ActorRole:     actorRole,
+		IPAddress:     c.ClientIP(),
+		RequestID:     c.GetString("request_id"),
+	}
+}
+
+// recordAudit records an action of the authenticated user in the audit log, along with the client IP and
+// request ID. The action already happened, so a failure to record it is only logged.
+func recordAudit(c *gin.Context, audit services.AuditServicer, action, targetType string, targetID any, before, after any) {
+	entry := auditActor(c)
+	entry.Action = action
+	entry.TargetType = targetType
+	entry.TargetID = fmt.Sprint(targetID)
+	entry.Before = before
+	entry.After = after
+	if err := audit.Record(entry); err != nil {
+		log.Printf("failed to record %s of %s %v: %v", action, targetType, targetID, err)
+	}
+}
+
+// setCursorLinks fills in the next and prev links of a keyset page from its cursors,
+// keeping the other query parameters of the request.
+func setCursorLinks(c *gin.Context, cursors *pagination.CursorPagination) {
+	link := func(cursor string) string {
+		u := *c.Request.URL
+		params := u.Query()
+		params.Set("cursor", cursor)
+		u.RawQuery = params.Encode()
+		return u.RequestURI()
+	}
+
+	if cursors.NextCursor != "" {
+		cursors.Next = link(cursors.NextCursor)
+	}
+	if cursors.PrevCursor != "" {
+		cursors.Prev = link(cursors.PrevCursor)
+	}
+}
+
+// csvText keeps spreadsheets from running user supplied text as a formula.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
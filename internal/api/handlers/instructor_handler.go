@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddCourseInstructorRequest defines the request body for linking an instructor to a course.
+type AddCourseInstructorRequest struct {
+	UserID uint   `json:"user_id" binding:"required"`
+	Role   string `json:"role" binding:"required,oneof=owner co_instructor"`
+}
+
+type InstructorHandler struct {
+	InstructorService services.InstructorServicer
+	AuditService      services.AuditServicer
+}
+
+func NewInstructorHandler(instructorService services.InstructorServicer, auditService services.AuditServicer) *InstructorHandler {
+	return &InstructorHandler{InstructorService: instructorService, AuditService: auditService}
+}
+
+// GetCourseInstructors godoc
+// @Summary List a course's instructors
+// @Description Retrieve the instructors linked to a course and their roles
+// @Tags instructors
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Success 200 {object} []services.CourseInstructorInfo
+// @Failure 400 {object} map[string]string "Invalid course ID"
+// @Failure 403 {object} map[string]string "Not an instructor of this course"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/instructors [get]
+func (h *InstructorHandler) GetCourseInstructors(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.InstructorService.AuthorizeCourse(userID, role, uint(id), false)) {
+		return
+	}
+
+	instructors, err := h.InstructorService.GetCourseInstructors(uint(id))
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    instructors,
+	})
+}
+
+// AddCourseInstructor godoc
+// @Summary Add an instructor to a course
+// @Description Link an instructor account to a course as owner or co-instructor, or change their role. Only the owner or an admin can do this.
+// @Tags instructors
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param instructor body AddCourseInstructorRequest true "Instructor and role"
+// @Success 200 {object} []services.CourseInstructorInfo
+// @Failure 400 {object} map[string]string "Invalid input or user is not an instructor"
+// @Failure 403 {object} map[string]string "Not the owner of this course"
+// @Failure 404 {object} map[string]string "Course not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/instructors [post]
+func (h *InstructorHandler) AddCourseInstructor(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.InstructorService.AuthorizeCourse(userID, role, uint(id), true)) {
+		return
+	}
+
+	var req AddCourseInstructorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.InstructorService.AddCourseInstructor(uint(id), req.UserID, req.Role); err != nil {
+		switch err.Error() {
+		case "course not found":
+			c.AbortWithError(http.StatusNotFound, err)
+		case "user not found", "user is not an instructor", "course owner cannot be demoted, transfer ownership instead":
+			c.AbortWithError(http.StatusBadRequest, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditCourseInstructorAdd, models.AuditTargetCourse, id, nil, req)
+
+	instructors, err := h.InstructorService.GetCourseInstructors(uint(id))
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "instructor added",
+		"data":    instructors,
+	})
+}
+
+// RemoveCourseInstructor godoc
+// @Summary Remove an instructor from a course
+// @Description Unlink a co-instructor from a course. Only the owner or an admin can do this.
+// @Tags instructors
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param userId path int true "Instructor's user ID"
+// @Success 204 "Instructor removed"
+// @Failure 400 {object} map[string]string "Invalid input or instructor is the owner"
+// @Failure 403 {object} map[string]string "Not the owner of this course"
+// @Failure 404 {object} map[string]string "Instructor not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/instructors/{userId} [delete]
+func (h *InstructorHandler) RemoveCourseInstructor(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+	instructorID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid user ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.InstructorService.AuthorizeCourse(userID, role, uint(id), true)) {
+		return
+	}
+
+	if err := h.InstructorService.RemoveCourseInstructor(uint(id), uint(instructorID)); err != nil {
+		switch err.Error() {
+		case "instructor not found":
+			c.AbortWithError(http.StatusNotFound, err)
+		case "course owner cannot be removed, transfer ownership first":
+			c.AbortWithError(http.StatusBadRequest, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditCourseInstructorRemove, models.AuditTargetCourse, id, gin.H{"user_id": instructorID}, nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetInstructorCourses godoc
+// @Summary Get the courses I teach
+// @Description Retrieve the authenticated instructor's courses in every status, with enrollment counts and average progress
+// @Tags instructors
+// @Produce  json
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Param q query string false "Search query"
+// @Success 200 {object} []services.InstructorCourse
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /instructor/courses [get]
+func (h *InstructorHandler) GetInstructorCourses(c *gin.Context) {
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "15")
+	query := c.DefaultQuery("q", "")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return
+	}
+	limit = min(limit, 50)
+
+	userID, _ := currentUser(c)
+
+	courses, pagination, err := h.InstructorService.GetInstructorCourses(userID, page, limit, query)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       courses,
+		"pagination": pagination,
+	})
+}
+
+// GetCourseEnrollments godoc
+// @Summary Get a course's enrollments
+// @Description Retrieve the students enrolled in one of the instructor's courses and their progress
+// @Tags instructors
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Param q query string false "Search query"
+// @Success 200 {object} []services.CourseEnrollmentProgress
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not an instructor of this course"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /instructor/courses/{id}/enrollments [get]
+func (h *InstructorHandler) GetCourseEnrollments(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.InstructorService.AuthorizeCourse(userID, role, uint(id), false)) {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "15")
+	query := c.DefaultQuery("q", "")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return
+	}
+	limit = min(limit, 50)
+
+	enrollments, pagination, err := h.InstructorService.GetCourseEnrollments(uint(id), page, limit, query)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       enrollments,
+		"pagination": pagination,
+	})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"grocademy/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	Keys *auth.KeySet
+}
+
+func NewJWKSHandler(keys *auth.KeySet) *JWKSHandler {
+	return &JWKSHandler{Keys: keys}
+}
+
+// GetJWKS godoc
+// @Summary Get the token verification keys
+// @Description The public keys that session tokens are signed with, as a JSON Web Key Set. Tokens name their key in the kid header. During a key rotation the set holds both the old and the new key.
+// @Tags auth
+// @Produce  json
+// @Success 200 {object} auth.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	// Verifiers refetch the set when they meet an unknown kid, so it can be cached for a while
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.Keys.JWKS())
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFACodeRequest defines a request body holding an authentication code.
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"` // Code from the authenticator app
+}
+
+// DisableMFARequest defines the request body for turning off two-factor authentication.
+type DisableMFARequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // Authenticator code or recovery code
+}
+
+type MFAHandler struct {
+	MFAService services.MFAServicer
+}
+
+func NewMFAHandler(mfaService services.MFAServicer) *MFAHandler {
+	return &MFAHandler{MFAService: mfaService}
+}
+
+// abortMFAError responds to a failed two-factor authentication operation.
+func abortMFAError(c *gin.Context, err error) {
+	if abortRateLimitError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode), err.Error() == "invalid or expired challenge token":
+		c.AbortWithError(http.StatusUnauthorized, err)
+	case err.Error() == "current password is incorrect", errors.Is(err, services.ErrMFARequired):
+		c.AbortWithError(http.StatusForbidden, err)
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnabled),
+		errors.Is(err, services.ErrMFASetupNotStarted):
+		c.AbortWithError(http.StatusBadRequest, err)
+	case err.Error() == "user not found":
+		c.AbortWithError(http.StatusNotFound, err)
+	default:
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// BeginSetup godoc
+// @Summary Start setting up 2FA
+// @Description Generate a new authenticator secret and QR code for the authenticated user. Two-factor authentication is turned on once confirmed with a code at /me/2fa/enable.
+// @Tags me
+// @Produce  json
+// @Success 200 {object} services.TOTPSetup
+// @Failure 400 {object} map[string]string "2FA already enabled"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/2fa/setup [post]
+func (h *MFAHandler) BeginSetup(c *gin.Context) {
+	userID, _ := currentUser(c)
+
+	setup, err := h.MFAService.BeginTOTPSetup(userID)
+	if err != nil {
+		abortMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Scan the QR code with your authenticator app",
+		"data":    setup,
+	})
+}
+
+// Enable godoc
+// @Summary Turn on 2FA
+// @Description Confirm the authenticator set up with /me/2fa/setup and turn on two-factor authentication. The response holds the recovery codes, which are shown only once.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param request body MFACodeRequest true "Code from the authenticator app"
+// @Success 200 {object} []string "Recovery codes"
+// @Failure 400 {object} map[string]string "Invalid input, setup not started or 2FA already enabled"
+// @Failure 401 {object} map[string]string "Invalid code"
+// @Failure 429 {object} map[string]string "Too many attempts"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/2fa/enable [post]
+func (h *MFAHandler) Enable(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	codes, err := h.MFAService.EnableTOTP(userID, req.Code)
+	if err != nil {
+		abortMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "two-factor authentication enabled",
+		"data":    gin.H{"recovery_codes": codes},
+	})
+}
+
+// Disable godoc
+// @Summary Turn off 2FA
+// @Description Turn off two-factor authentication with the password and an authenticator or recovery code. Not allowed for roles that require 2FA.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param request body DisableMFARequest true "Password and authentication code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string "Invalid input or 2FA not enabled"
+// @Failure 401 {object} map[string]string "Invalid code"
+// @Failure 403 {object} map[string]string "Incorrect password or 2FA required for the role"
+// @Failure 429 {object} map[string]string "Too many attempts"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/2fa/disable [post]
+func (h *MFAHandler) Disable(c *gin.Context) {
+	var req DisableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	if err := h.MFAService.DisableTOTP(userID, req.Password, req.Code); err != nil {
+		abortMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "two-factor authentication disabled",
+		"data":    nil,
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate 2FA recovery codes
+// @Description Replace the recovery codes of the authenticated user. The previous codes stop working.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param request body MFACodeRequest true "Code from the authenticator app"
+// @Success 200 {object} []string "Recovery codes"
+// @Failure 400 {object} map[string]string "Invalid input or 2FA not enabled"
+// @Failure 401 {object} map[string]string "Invalid code"
+// @Failure 429 {object} map[string]string "Too many attempts"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/2fa/recovery-codes [post]
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	codes, err := h.MFAService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		abortMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "recovery codes regenerated",
+		"data":    gin.H{"recovery_codes": codes},
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"strconv"
+	"time"
 
 	"grocademy/internal/db/models"
 	"grocademy/internal/services"
@@ -15,10 +16,13 @@ import (
 
 // CreateModuleRequest defines the form data for creating a module.
 type CreateModuleRequest struct {
-	Title        string                `form:"title" binding:"required"`
-	Description  string                `form:"description" binding:"required"`
-	PDFContent   *multipart.FileHeader `form:"pdf_content"`
-	VideoContent *multipart.FileHeader `form:"video_content"`
+	Title             string                `form:"title" binding:"required"`
+	Description       string                `form:"description" binding:"required"`
+	PDFContent        *multipart.FileHeader `form:"pdf_content"`
+	VideoContent      *multipart.FileHeader `form:"video_content"`
+	ReleaseAt         *time.Time            `form:"release_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	ReleaseAfterDays  *int                  `form:"release_after_days" binding:"omitempty,min=0"`
+	HideUntilReleased bool                  `form:"hide_until_released"`
 }
 
 // UpdateModuleRequest defines the form data for updating a module.
@@ -30,6 +34,11 @@ type UpdateModuleRequest struct {
 	// Consider adding fields to explicitly clear PDF/Video content if needed
 	ClearPDF   bool `form:"clear_pdf,omitempty"`   // Example for clearing content
 	ClearVideo bool `form:"clear_video,omitempty"` // Example for clearing content
+	// Drip release schedule
+	ReleaseAt         *time.Time `form:"release_at,omitempty" time_format:"2006-01-02T15:04:05Z07:00"`
+	ReleaseAfterDays  *int       `form:"release_after_days,omitempty" binding:"omitempty,min=0"`
+	HideUntilReleased *bool      `form:"hide_until_released,omitempty"`
+	ClearRelease      bool       `form:"clear_release,omitempty"` // Make the module available immediately
 }
 
 // ReorderModulesRequest defines the request body for reordering modules.
@@ -44,14 +53,20 @@ type CompleteModuleRequest struct {
 	IsCompleted *bool `json:"is_completed" binding:"required"`
 }
 
+// SetModulePrerequisitesRequest defines the request body for replacing a module's prerequisites.
+type SetModulePrerequisitesRequest struct {
+	PrerequisiteIDs []uint `json:"prerequisite_ids" binding:"required"`
+}
+
 // ModuleHandler handles module-related API requests.
 type ModuleHandler struct {
 	ModuleService services.ModuleServicer
+	AuditService  services.AuditServicer
 }
 
 // NewModuleHandler creates a new ModuleHandler.
-func NewModuleHandler(moduleService services.ModuleServicer) *ModuleHandler {
-	return &ModuleHandler{ModuleService: moduleService}
+func NewModuleHandler(moduleService services.ModuleServicer, auditService services.AuditServicer) *ModuleHandler {
+	return &ModuleHandler{ModuleService: moduleService, AuditService: auditService}
 }
 
 // CreateModule godoc
@@ -66,6 +81,9 @@ func NewModuleHandler(moduleService services.ModuleServicer) *ModuleHandler {
 // @Param order formData int true "Module order within the course"
 // @Param pdf_content formData file false "PDF file for module content"
 // @Param video_content formData file false "Video file for module content"
+// @Param release_at formData string false "Release date (RFC 3339)"
+// @Param release_after_days formData int false "Release N days after the user's purchase"
+// @Param hide_until_released formData boolean false "Hide the module until it is released"
 // @Success 201 {object} models.Module
 // @Failure 400 {object} map[string]string "Invalid input"
 // @Failure 404 {object} map[string]string "Course not found"
@@ -80,6 +98,11 @@ func (h *ModuleHandler) CreateModule(c *gin.Context) {
 		return
 	}
 
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.ModuleService.AuthorizeCourse(userID, role, uint(courseID))) {
+		return
+	}
+
 	var req CreateModuleRequest
 	if err := c.ShouldBind(&req); err != nil {
 		c.AbortWithError(http.StatusBadRequest, err)
@@ -88,8 +111,14 @@ func (h *ModuleHandler) CreateModule(c *gin.Context) {
 
 	newModule, err := h.ModuleService.CreateModule(
 		uint(courseID),
+		userID,
 		req.Title,
 		req.Description,
+		services.ModuleReleaseRule{
+			ReleaseAt:         req.ReleaseAt,
+			ReleaseAfterDays:  req.ReleaseAfterDays,
+			HideUntilReleased: req.HideUntilReleased,
+		},
 		req.PDFContent,
 		req.VideoContent,
 	)
@@ -101,6 +130,7 @@ func (h *ModuleHandler) CreateModule(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to create module: %v", err))
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditModuleCreate, models.AuditTargetModule, newModule.ID, nil, newModule)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"status":  "success",
@@ -120,6 +150,7 @@ func (h *ModuleHandler) CreateModule(c *gin.Context) {
 // @Param q query string false "Search query"
 // @Success 200 {object} []models.Module
 // @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Course not found"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Security Bearer
 // @Router /courses/{courseId}/modules [get]
@@ -146,16 +177,29 @@ func (h *ModuleHandler) GetAllModulesByCourseID(c *gin.Context) {
 	}
 	limit = min(limit, 50)
 
-	userID, _ := c.Get("id")
+	userID, role := currentUser(c)
 
-	paginatedModules, progressMap, pagination, err := h.ModuleService.GetAllModulesByCourseID(uint(courseID), userID.(uint), int64(page), int64(limit))
+	// Admins and the course's own instructors preview every module without locks
+	canManage := h.ModuleService.AuthorizeCourse(userID, role, uint(courseID)) == nil
+
+	paginatedModules, progressMap, lockMap, pagination, err := h.ModuleService.GetAllModulesByCourseID(uint(courseID), userID, canManage, int64(page), int64(limit))
 	if err != nil {
+		if err.Error() == "course not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to retrieve modules: %v", err))
 		return
 	}
 
 	var enrichedModules []map[string]interface{}
 	for _, module := range *paginatedModules {
+		lock := (*lockMap)[module.ID]
+		// don't hand out content links for modules the user hasn't unlocked yet
+		if lock.Locked {
+			module.PDFPath = ""
+			module.VideoPath = ""
+		}
 		enrichedModule := map[string]interface{}{
 			"id":            module.ID,
 			"course_id":     module.CourseID,
@@ -167,6 +211,9 @@ func (h *ModuleHandler) GetAllModulesByCourseID(c *gin.Context) {
 			"created_at":    module.CreatedAt,
 			"updated_at":    module.UpdatedAt,
 			"is_completed":  (*progressMap)[module.ID],
+			"locked":        lock.Locked,
+			"unlock_reason": lock.UnlockReason,
+			"available_at":  lock.AvailableAt,
 		}
 		enrichedModules = append(enrichedModules, enrichedModule)
 	}
@@ -187,6 +234,7 @@ func (h *ModuleHandler) GetAllModulesByCourseID(c *gin.Context) {
 // @Param id path int true "Module ID"
 // @Success 200 {object} models.Module
 // @Failure 400 {object} map[string]string "Invalid module ID"
+// @Failure 403 {object} map[string]string "Module is locked"
 // @Failure 404 {object} map[string]string "Module not found"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Security Bearer
@@ -199,14 +247,21 @@ func (h *ModuleHandler) GetModuleByID(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("id")
+	userID, role := currentUser(c)
 
-	module, completion, err := h.ModuleService.GetModuleByID(uint(id), userID.(uint))
+	// Admins and the course's own instructors preview every module without locks
+	canManage := h.ModuleService.AuthorizeModule(userID, role, uint(id)) == nil
+
+	module, completion, err := h.ModuleService.GetModuleByID(uint(id), userID, canManage)
 	if err != nil {
 		if err.Error() == "module not found" {
 			c.AbortWithError(http.StatusNotFound, err)
 			return
 		}
+		if errors.Is(err, services.ErrModuleLocked) {
+			c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to retrieve module: %v", err))
 		return
 	}
@@ -222,6 +277,9 @@ func (h *ModuleHandler) GetModuleByID(c *gin.Context) {
 		"created_at":    module.CreatedAt,
 		"updated_at":    module.UpdatedAt,
 		"is_completed":  completion,
+		"locked":        false,
+		"unlock_reason": "",
+		"available_at":  nil,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -245,6 +303,10 @@ func (h *ModuleHandler) GetModuleByID(c *gin.Context) {
 // @Param video_content formData file false "New Video file for module content"
 // @Param clear_pdf formData boolean false "Set to true to clear existing PDF content"
 // @Param clear_video formData boolean false "Set to true to clear existing Video content"
+// @Param release_at formData string false "Release date (RFC 3339)"
+// @Param release_after_days formData int false "Release N days after the user's purchase"
+// @Param hide_until_released formData boolean false "Hide the module until it is released"
+// @Param clear_release formData boolean false "Set to true to remove the release schedule"
 // @Success 200 {object} models.Module "Updated module object"
 // @Failure 400 {object} map[string]string "Invalid input or no fields to update"
 // @Failure 404 {object} map[string]string "Module not found"
@@ -259,6 +321,11 @@ func (h *ModuleHandler) UpdateModule(c *gin.Context) {
 		return
 	}
 
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.ModuleService.AuthorizeModule(userID, role, uint(id))) {
+		return
+	}
+
 	var req UpdateModuleRequest
 	if err := c.ShouldBind(&req); err != nil {
 		c.AbortWithError(http.StatusBadRequest, err)
@@ -281,12 +348,28 @@ func (h *ModuleHandler) UpdateModule(c *gin.Context) {
 		updates["video_content"] = nil
 	}
 
+	if req.ClearRelease {
+		updates["ReleaseAt"] = nil
+		updates["ReleaseAfterDays"] = nil
+	} else {
+		if req.ReleaseAt != nil {
+			updates["ReleaseAt"] = *req.ReleaseAt
+		}
+		if req.ReleaseAfterDays != nil {
+			updates["ReleaseAfterDays"] = *req.ReleaseAfterDays
+		}
+	}
+	if req.HideUntilReleased != nil {
+		updates["HideUntilReleased"] = *req.HideUntilReleased
+	}
+
 	if len(updates) == 0 && req.PDFContent == nil && req.VideoContent == nil && !req.ClearPDF && !req.ClearVideo {
 		c.AbortWithError(http.StatusBadRequest, errors.New("no fields to update provided"))
 		return
 	}
 
-	updatedModule, err := h.ModuleService.UpdateModule(uint(id), updates, req.PDFContent, req.VideoContent)
+	before := h.AuditService.Snapshot(models.AuditTargetModule, uint(id))
+	updatedModule, err := h.ModuleService.UpdateModule(uint(id), userID, updates, req.PDFContent, req.VideoContent)
 	if err != nil {
 		if err.Error() == "module not found" {
 			c.AbortWithError(http.StatusNotFound, err)
@@ -295,6 +378,7 @@ func (h *ModuleHandler) UpdateModule(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to update module: %v", err))
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditModuleUpdate, models.AuditTargetModule, id, before, updatedModule)
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -323,6 +407,12 @@ func (h *ModuleHandler) DeleteModule(c *gin.Context) {
 		return
 	}
 
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.ModuleService.AuthorizeModule(userID, role, uint(id))) {
+		return
+	}
+
+	before := h.AuditService.Snapshot(models.AuditTargetModule, uint(id))
 	if err := h.ModuleService.DeleteModule(uint(id)); err != nil {
 		if err.Error() == "module not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid Module ID"})
@@ -331,6 +421,7 @@ func (h *ModuleHandler) DeleteModule(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to delete module: %v", err))
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditModuleDelete, models.AuditTargetModule, id, before, nil)
 
 	c.Status(http.StatusNoContent)
 }
@@ -356,6 +447,11 @@ func (h *ModuleHandler) ReorderModules(c *gin.Context) {
 		return
 	}
 
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.ModuleService.AuthorizeCourse(userID, role, uint(courseID))) {
+		return
+	}
+
 	var req ReorderModulesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.AbortWithError(http.StatusBadRequest, err)
@@ -375,6 +471,7 @@ func (h *ModuleHandler) ReorderModules(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to reorder modules: %v", err))
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditModuleReorder, models.AuditTargetCourse, courseID, nil, req)
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -391,6 +488,7 @@ func (h *ModuleHandler) ReorderModules(c *gin.Context) {
 // @Param id path int true "Module ID"
 // @Success 200 {object} models.Module
 // @Failure 400 {object} map[string]string "Invalid module ID"
+// @Failure 403 {object} map[string]string "Module is locked or course not purchased"
 // @Failure 404 {object} map[string]string "Module not found"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Security Bearer
@@ -408,14 +506,19 @@ func (h *ModuleHandler) CompleteModuleByID(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("id")
+	userID, role := currentUser(c)
+	canManage := h.ModuleService.AuthorizeModule(userID, role, uint(id)) == nil
 
-	total, completed, percentage, latestCompletion, err := h.ModuleService.CompleteModuleByID(uint(id), userID.(uint), *req.IsCompleted)
+	total, completed, percentage, latestCompletion, err := h.ModuleService.CompleteModuleByID(uint(id), userID, canManage, *req.IsCompleted)
 	if err != nil {
 		if err.Error() == "module not found" {
 			c.AbortWithError(http.StatusNotFound, err)
 			return
 		}
+		if errors.Is(err, services.ErrModuleLocked) || errors.Is(err, services.ErrNotEnrolled) {
+			c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
 		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to retrieve module: %v", err))
 		return
 	}
@@ -435,3 +538,260 @@ func (h *ModuleHandler) CompleteModuleByID(c *gin.Context) {
 		},
 	})
 }
+
+// GetModulePrerequisites godoc
+// @Summary Get a module's prerequisites
+// @Description Retrieve the IDs of modules that must be completed before this module unlocks
+// @Tags modules
+// @Produce  json
+// @Param id path int true "Module ID"
+// @Success 200 {object} []uint
+// @Failure 400 {object} map[string]string "Invalid module ID"
+// @Failure 404 {object} map[string]string "Module not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /modules/{id}/prerequisites [get]
+func (h *ModuleHandler) GetModulePrerequisites(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid module ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	canManage := h.ModuleService.AuthorizeModule(userID, role, uint(id)) == nil
+
+	prerequisiteIDs, err := h.ModuleService.GetModulePrerequisites(uint(id), userID, canManage)
+	if err != nil {
+		if err.Error() == "module not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "prerequisites queried",
+		"data": gin.H{
+			"module_id":        id,
+			"prerequisite_ids": prerequisiteIDs,
+		},
+	})
+}
+
+// SetModulePrerequisites godoc
+// @Summary Set a module's prerequisites
+// @Description Replace the modules that must be completed before this module unlocks
+// @Tags modules
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Module ID"
+// @Param prerequisites body SetModulePrerequisitesRequest true "Prerequisite module IDs from the same course"
+// @Success 200 {object} []uint
+// @Failure 400 {object} map[string]string "Invalid input, foreign module or cycle"
+// @Failure 404 {object} map[string]string "Module not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /modules/{id}/prerequisites [put]
+func (h *ModuleHandler) SetModulePrerequisites(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid module ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.ModuleService.AuthorizeModule(userID, role, uint(id))) {
+		return
+	}
+
+	var req SetModulePrerequisitesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.ModuleService.SetModulePrerequisites(uint(id), req.PrerequisiteIDs); err != nil {
+		switch err.Error() {
+		case "module not found":
+			c.AbortWithError(http.StatusNotFound, err)
+		case "module cannot be its own prerequisite",
+			"some prerequisite IDs do not belong to the same course or are invalid",
+			"prerequisites would create a cycle":
+			c.AbortWithError(http.StatusBadRequest, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditModulePrerequisites, models.AuditTargetModule, id, nil, req)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "prerequisites updated",
+		"data": gin.H{
+			"module_id":        id,
+			"prerequisite_ids": req.PrerequisiteIDs,
+		},
+	})
+}
+
+// GetModuleRevisions godoc
+// @Summary List a module's revisions
+// @Description Retrieve the revision history of a module, newest first
+// @Tags modules
+// @Produce  json
+// @Param id path int true "Module ID"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Success 200 {object} []models.Revision
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /modules/{id}/revisions [get]
+func (h *ModuleHandler) GetModuleRevisions(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid module ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.ModuleService.AuthorizeModule(userID, role, uint(id))) {
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "15")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return
+	}
+	limit = min(limit, 50)
+
+	revisions, pagination, err := h.ModuleService.GetModuleRevisions(uint(id), page, limit)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "revisions queried",
+		"data":       revisions,
+		"pagination": pagination,
+	})
+}
+
+// GetModuleRevision godoc
+// @Summary Get one revision of a module
+// @Description Retrieve a module revision with its snapshot, diff and retained storage objects
+// @Tags modules
+// @Produce  json
+// @Param id path int true "Module ID"
+// @Param version path int true "Revision version"
+// @Success 200 {object} models.Revision
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Revision not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /modules/{id}/revisions/{version} [get]
+func (h *ModuleHandler) GetModuleRevision(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid module ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.ModuleService.AuthorizeModule(userID, role, uint(id))) {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid revision version"))
+		return
+	}
+
+	revision, err := h.ModuleService.GetModuleRevision(uint(id), version)
+	if err != nil {
+		if err.Error() == "revision not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "revision found",
+		"data":    revision,
+	})
+}
+
+// RestoreModuleRevision godoc
+// @Summary Restore a module revision
+// @Description Put the content of an earlier revision back on the module. The restore is recorded as a new revision.
+// @Tags modules
+// @Produce  json
+// @Param id path int true "Module ID"
+// @Param version path int true "Revision version"
+// @Success 200 {object} models.Module
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Module or revision not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /modules/{id}/revisions/{version}/restore [post]
+func (h *ModuleHandler) RestoreModuleRevision(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid module ID"))
+		return
+	}
+
+	userID, role := currentUser(c)
+	if abortAccessError(c, h.ModuleService.AuthorizeModule(userID, role, uint(id))) {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid revision version"))
+		return
+	}
+
+	before := h.AuditService.Snapshot(models.AuditTargetModule, uint(id))
+	module, revision, err := h.ModuleService.RestoreModuleRevision(uint(id), userID, version)
+	if err != nil {
+		if err.Error() == "module not found" || err.Error() == "revision not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, fmt.Errorf("failed to restore module: %v", err))
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditModuleRestore, models.AuditTargetModule, id, before, module)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "module restored",
+		"data": gin.H{
+			"module":   module,
+			"revision": revision,
+		},
+	})
+}
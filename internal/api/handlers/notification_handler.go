@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateNotificationPreferencesRequest defines the request body for turning notification kinds on or off.
+type UpdateNotificationPreferencesRequest struct {
+	Preferences map[string]bool `json:"preferences" binding:"required"` // Notification kind to whether it is received
+}
+
+type NotificationHandler struct {
+	NotificationService services.NotificationServicer
+}
+
+func NewNotificationHandler(notificationService services.NotificationServicer) *NotificationHandler {
+	return &NotificationHandler{NotificationService: notificationService}
+}
+
+// GetNotifications godoc
+// @Summary Get my notifications
+// @Description Retrieve the authenticated user's notifications, newest first, with their unread count
+// @Tags notifications
+// @Produce  json
+// @Param unread query bool false "Only unread notifications"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Success 200 {object} []models.Notification
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /notifications [get]
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "15")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return
+	}
+	limit = min(limit, 50)
+
+	unreadOnly, err := strconv.ParseBool(c.DefaultQuery("unread", "false"))
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid unread flag"))
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	notifications, pagination, err := h.NotificationService.GetNotifications(userID, unreadOnly, page, limit)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	unread, err := h.NotificationService.CountUnread(userID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "success",
+		"message":      "Query success",
+		"data":         notifications,
+		"pagination":   pagination,
+		"unread_count": unread,
+	})
+}
+
+// GetUnreadCount godoc
+// @Summary Count my unread notifications
+// @Description Retrieve the number of unread notifications of the authenticated user
+// @Tags notifications
+// @Produce  json
+// @Success 200 {object} map[string]int64
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /notifications/unread-count [get]
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	userID, _ := currentUser(c)
+
+	unread, err := h.NotificationService.CountUnread(userID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    gin.H{"unread_count": unread},
+	})
+}
+
+// MarkRead godoc
+// @Summary Mark a notification as read
+// @Description Mark one of the authenticated user's notifications as read
+// @Tags notifications
+// @Produce  json
+// @Param id path int true "Notification ID"
+// @Success 200 {object} models.Notification
+// @Failure 400 {object} map[string]string "Invalid notification ID"
+// @Failure 404 {object} map[string]string "Notification not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /notifications/{id}/read [patch]
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid notification ID"))
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	notification, err := h.NotificationService.MarkRead(userID, uint(id))
+	if err != nil {
+		if err.Error() == "notification not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "notification read",
+		"data":    notification,
+	})
+}
+
+// MarkAllRead godoc
+// @Summary Mark all notifications as read
+// @Description Mark every unread notification of the authenticated user as read
+// @Tags notifications
+// @Produce  json
+// @Success 200 {object} map[string]int64
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /notifications/read-all [post]
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, _ := currentUser(c)
+
+	marked, err := h.NotificationService.MarkAllRead(userID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "notifications read",
+		"data":    gin.H{"marked": marked},
+	})
+}
+
+// GetPreferences godoc
+// @Summary Get my notification preferences
+// @Description Retrieve for every notification kind whether the authenticated user receives it
+// @Tags notifications
+// @Produce  json
+// @Success 200 {object} map[string]bool
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /notifications/preferences [get]
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID, _ := currentUser(c)
+
+	preferences, err := h.NotificationService.GetPreferences(userID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    preferences,
+	})
+}
+
+// UpdatePreferences godoc
+// @Summary Update my notification preferences
+// @Description Turn notification kinds on or off. Kinds left out keep their current setting.
+// @Tags notifications
+// @Accept  json
+// @Produce  json
+// @Param preferences body UpdateNotificationPreferencesRequest true "Notification kinds to turn on or off"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string "Invalid input or unknown notification kind"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /notifications/preferences [put]
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	var req UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	preferences, err := h.NotificationService.UpdatePreferences(userID, req.Preferences)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownNotificationKind) {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "preferences updated",
+		"data":    preferences,
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"grocademy/internal/api/middlewares"
+	"grocademy/internal/auth"
+	"grocademy/internal/services"
+	"grocademy/internal/sso"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookie keeps the state of a sign in with an external provider until the provider redirects back.
+const oidcStateCookie = "oidc_state"
+
+type OIDCHandler struct {
+	OIDCService services.OIDCServicer
+}
+
+func NewOIDCHandler(oidcService services.OIDCServicer) *OIDCHandler {
+	return &OIDCHandler{OIDCService: oidcService}
+}
+
+// GetProviders godoc
+// @Summary List sign in providers
+// @Description List the external providers users can sign in with
+// @Tags auth
+// @Produce  json
+// @Success 200 {object} []sso.ProviderInfo
+// @Router /auth/oidc/providers [get]
+func (h *OIDCHandler) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    h.OIDCService.GetProviders(),
+	})
+}
+
+// Login godoc
+// @Summary Sign in with an external provider
+// @Description Redirect the browser to the sign in page of an external provider, using the authorization code flow with PKCE
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} map[string]string "Unknown provider"
+// @Router /auth/oidc/{provider}/login [get]
+func (h *OIDCHandler) Login(c *gin.Context) {
+	login, err := h.OIDCService.BeginLogin(c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, sso.ErrUnknownProvider) {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		log.Printf("failed to start sign in with %s: %v", c.Param("provider"), err)
+		redirectLoginError(c, "sign in provider is unavailable, try again later")
+		return
+	}
+
+	middlewares.SetCookie(c, oidcStateCookie, login.StateToken, 600, "/api/auth/oidc", true)
+	c.Redirect(http.StatusFound, login.AuthURL)
+}
+
+// Callback godoc
+// @Summary Complete a sign in with an external provider
+// @Description Callback the provider redirects to after the user signed in. The identity is linked to the account with the same verified email address, or to a new account.
+// @Description On success, the JWT token is set in an HttpOnly cookie and the browser is redirected to the dashboard. Users with two-factor authentication are redirected to the login page to enter a code.
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 302 "Redirect to the dashboard or the login page"
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	stateToken, _ := c.Cookie(oidcStateCookie)
+	middlewares.SetCookie(c, oidcStateCookie, "", -1, "/api/auth/oidc", true)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		message := c.Query("error_description")
+		if message == "" {
+			message = "sign in was cancelled"
+		}
+		redirectLoginError(c, message)
+		return
+	}
+
+	result, err := h.OIDCService.CompleteLogin(c.Param("provider"), c.Query("code"), c.Query("state"), stateToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnverifiedIdentityEmail), errors.Is(err, sso.ErrUnknownProvider),
+			err.Error() == "invalid or expired sign in state", err.Error() == "invalid credentials":
+			redirectLoginError(c, err.Error())
+		default:
+			log.Printf("failed to complete sign in with %s: %v", c.Param("provider"), err)
+			redirectLoginError(c, "sign in failed, try again later")
+		}
+		return
+	}
+
+	if result.ChallengeToken != "" {
+		fragment := url.Values{"challenge_token": {result.ChallengeToken}}
+		if result.ChallengePurpose == auth.ChallengeMFASetup {
+			fragment.Set("mfa_setup", "1")
+		}
+		c.Redirect(http.StatusFound, "/login#"+fragment.Encode())
+		return
+	}
+
+	setSessionCookie(c, result.Token)
+	c.Redirect(http.StatusFound, "/dashboard")
+}
+
+// redirectLoginError sends the browser back to the login page, which shows the message.
+func redirectLoginError(c *gin.Context, message string) {
+	c.Redirect(http.StatusFound, "/login#"+url.Values{"error": {message}}.Encode())
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"grocademy/internal/auth"
+	"grocademy/internal/db/dbtest"
+	"grocademy/internal/db/models"
+	"grocademy/internal/services"
+	"grocademy/internal/sso"
+	"grocademy/internal/sso/ssotest"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// newOIDCTestRouter serves the sign in routes, signing users in with a mock IdP named "mock" served by an
+// httptest server.
+func newOIDCTestRouter(t *testing.T) (*gin.Engine, *ssotest.Provider, *gorm.DB) {
+	t.Helper()
+
+	if err := auth.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(nil)
+	idp, err := ssotest.NewProvider("http://"+server.Listener.Addr().String(), "grocademy", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Config.Handler = idp
+	server.Start()
+	t.Cleanup(server.Close)
+
+	t.Setenv("OIDC_PROVIDERS", "mock")
+	t.Setenv("OIDC_MOCK_ISSUER_URL", idp.Issuer)
+	t.Setenv("OIDC_MOCK_CLIENT_ID", idp.ClientID)
+	t.Setenv("OIDC_MOCK_CLIENT_SECRET", idp.ClientSecret)
+	t.Setenv("MFA_REQUIRED_ROLES", "none")
+
+	db := dbtest.Open(t, &models.User{}, &models.ExternalIdentity{}, &models.RecoveryCode{}, &models.OutboxEmail{})
+	authService := services.NewAuthService(db, services.NewEmailService(db), services.NewMFAService(db))
+	oidcService := &services.OIDCService{Auth: authService, Providers: sso.LoadProvidersFromEnv("http://grocademy.test")}
+	handler := NewOIDCHandler(oidcService)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/auth/oidc/:provider/login", handler.Login)
+	r.GET("/api/auth/oidc/:provider/callback", handler.Callback)
+	return r, idp, db
+}
+
+// beginOIDCLogin starts a sign in like a browser following the login link, returning the IdP's sign in
+// page and the state cookie.
+func beginOIDCLogin(t *testing.T, r *gin.Engine) (string, *http.Cookie) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/mock/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login responded %d, want a redirect to the IdP", rec.Code)
+	}
+	state := responseCookie(rec, oidcStateCookie)
+	if state == nil || state.Value == "" {
+		t.Fatal("login did not set the state cookie")
+	}
+	return rec.Header().Get("Location"), state
+}
+
+// followOIDCCallback requests the callback the IdP redirected to, with the given cookies.
+func followOIDCCallback(r *gin.Engine, callback *url.URL, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func responseCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestOIDCCallbackSignsIn(t *testing.T) {
+	r, idp, db := newOIDCTestRouter(t)
+
+	authURL, state := beginOIDCLogin(t, r)
+	callback, err := idp.SignIn(authURL, "ada@example.com", "Ada Lovelace", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := followOIDCCallback(r, callback, state)
+
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("callback responded %d to %q, want a redirect to /dashboard", rec.Code, rec.Header().Get("Location"))
+	}
+	session := responseCookie(rec, "jwt_token")
+	if session == nil {
+		t.Fatal("callback did not set the session cookie")
+	}
+	claims, err := auth.ValidateJWT(session.Value)
+	if err != nil {
+		t.Fatalf("session cookie holds an invalid token: %v", err)
+	}
+	var user models.User
+	if err := db.Where("email = ?", "ada@example.com").First(&user).Error; err != nil || claims.ID != user.ID {
+		t.Errorf("session is not of the account created for the identity")
+	}
+	if cleared := responseCookie(rec, oidcStateCookie); cleared == nil || cleared.MaxAge >= 0 {
+		t.Error("callback did not clear the state cookie")
+	}
+}
+
+func TestOIDCCallbackRejectsSignIn(t *testing.T) {
+	r, idp, db := newOIDCTestRouter(t)
+
+	tests := []struct {
+		name          string
+		emailVerified bool
+		callback      func(callback *url.URL, state *http.Cookie) (*url.URL, []*http.Cookie)
+		wantError     string
+	}{
+		{
+			name:          "missing state cookie",
+			emailVerified: true,
+			callback: func(callback *url.URL, state *http.Cookie) (*url.URL, []*http.Cookie) {
+				return callback, nil
+			},
+			wantError: "invalid or expired sign in state",
+		},
+		{
+			name:          "mismatched state",
+			emailVerified: true,
+			callback: func(callback *url.URL, state *http.Cookie) (*url.URL, []*http.Cookie) {
+				query := callback.Query()
+				query.Set("state", "forged")
+				callback.RawQuery = query.Encode()
+				return callback, []*http.Cookie{state}
+			},
+			wantError: "invalid or expired sign in state",
+		},
+		{
+			name:          "sign in cancelled at the IdP",
+			emailVerified: true,
+			callback: func(callback *url.URL, state *http.Cookie) (*url.URL, []*http.Cookie) {
+				callback.RawQuery = url.Values{"error": {"access_denied"}, "state": {callback.Query().Get("state")}}.Encode()
+				return callback, []*http.Cookie{state}
+			},
+			wantError: "sign in was cancelled",
+		},
+		{
+			name:          "unverified email",
+			emailVerified: false,
+			callback: func(callback *url.URL, state *http.Cookie) (*url.URL, []*http.Cookie) {
+				return callback, []*http.Cookie{state}
+			},
+			wantError: services.ErrUnverifiedIdentityEmail.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authURL, state := beginOIDCLogin(t, r)
+			callback, err := idp.SignIn(authURL, "ada@example.com", "Ada Lovelace", tt.emailVerified)
+			if err != nil {
+				t.Fatal(err)
+			}
+			callback, cookies := tt.callback(callback, state)
+			rec := followOIDCCallback(r, callback, cookies...)
+
+			want := "/login#" + url.Values{"error": {tt.wantError}}.Encode()
+			if rec.Code != http.StatusFound || rec.Header().Get("Location") != want {
+				t.Errorf("callback responded %d to %q, want a redirect to %q", rec.Code, rec.Header().Get("Location"), want)
+			}
+			if responseCookie(rec, "jwt_token") != nil {
+				t.Error("callback set a session cookie")
+			}
+		})
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d users were created, want none", count)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettlePayoutsRequest defines the request body for marking a monthly statement as paid out.
+type SettlePayoutsRequest struct {
+	Reference string `json:"reference" binding:"required"` // Bank transfer or other payment reference
+}
+
+type PayoutHandler struct {
+	PayoutService services.PayoutServicer
+	AuditService  services.AuditServicer
+}
+
+func NewPayoutHandler(payoutService services.PayoutServicer, auditService services.AuditServicer) *PayoutHandler {
+	return &PayoutHandler{PayoutService: payoutService, AuditService: auditService}
+}
+
+// GetStatementSummaries godoc
+// @Summary List monthly payout statements
+// @Description Retrieve the earnings of an instructor per month. Instructors get their own, admins pass the instructor's ID.
+// @Tags payouts
+// @Produce  json
+// @Param instructorId path int false "Instructor's user ID (admin route only)"
+// @Success 200 {object} []services.PayoutStatementSummary
+// @Failure 400 {object} map[string]string "Invalid instructor ID"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /instructor/statements [get]
+// @Router /payouts/{instructorId}/statements [get]
+func (h *PayoutHandler) GetStatementSummaries(c *gin.Context) {
+	instructorID, ok := statementInstructorID(c)
+	if !ok {
+		return
+	}
+
+	summaries, err := h.PayoutService.GetStatementSummaries(instructorID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    summaries,
+	})
+}
+
+// GetStatement godoc
+// @Summary Get a monthly payout statement
+// @Description Retrieve every sale an instructor earned from in a month, as JSON or as CSV with format=csv
+// @Tags payouts
+// @Produce  json
+// @Produce  text/csv
+// @Param instructorId path int false "Instructor's user ID (admin route only)"
+// @Param period path string true "Month, YYYY-MM"
+// @Param format query string false "json (default) or csv"
+// @Success 200 {object} services.PayoutStatement
+// @Failure 400 {object} map[string]string "Invalid period"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /instructor/statements/{period} [get]
+// @Router /payouts/{instructorId}/statements/{period} [get]
+func (h *PayoutHandler) GetStatement(c *gin.Context) {
+	instructorID, ok := statementInstructorID(c)
+	if !ok {
+		return
+	}
+
+	statement, err := h.PayoutService.GetStatement(instructorID, c.Param("period"))
+	if err != nil {
+		if err.Error() == "invalid period, expected YYYY-MM" {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if c.DefaultQuery("format", "json") == "csv" {
+		writeStatementCSV(c, statement)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    statement,
+	})
+}
+
+// SettlePayouts godoc
+// @Summary Settle a monthly payout statement
+// @Description Mark every unsettled sale of an instructor in a month as paid out
+// @Tags payouts
+// @Accept  json
+// @Produce  json
+// @Param instructorId path int true "Instructor's user ID"
+// @Param period path string true "Month, YYYY-MM"
+// @Param settlement body SettlePayoutsRequest true "Payment reference"
+// @Success 200 {object} services.PayoutStatementSummary
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Nothing to settle"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /payouts/{instructorId}/statements/{period}/settle [post]
+func (h *PayoutHandler) SettlePayouts(c *gin.Context) {
+	instructorID, ok := statementInstructorID(c)
+	if !ok {
+		return
+	}
+
+	var req SettlePayoutsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	summary, err := h.PayoutService.SettlePayouts(instructorID, c.Param("period"), req.Reference)
+	if err != nil {
+		switch err.Error() {
+		case "invalid period, expected YYYY-MM":
+			c.AbortWithError(http.StatusBadRequest, err)
+		case "no unsettled payouts for this period":
+			c.AbortWithError(http.StatusNotFound, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditPayoutSettle, models.AuditTargetPayout, fmt.Sprintf("%d/%s", instructorID, c.Param("period")), nil, summary)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "payouts settled",
+		"data":    summary,
+	})
+}
+
+// statementInstructorID returns the instructor whose statements are requested: the one in the
+// route on admin routes, the authenticated user otherwise.
+func statementInstructorID(c *gin.Context) (uint, bool) {
+	idStr := c.Param("instructorId")
+	if idStr == "" {
+		userID, _ := currentUser(c)
+		return userID, true
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid instructor ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeStatementCSV(c *gin.Context, statement *services.PayoutStatement) {
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=statement-%d-%s.csv", statement.InstructorID, statement.Period))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"date", "transaction_id", "course_id", "course_title", "sale_amount", "share_percentage", "amount", "settled_at", "settlement_ref"})
+	for _, entry := range statement.Entries {
+		settledAt := ""
+		if entry.SettledAt != nil {
+			settledAt = entry.SettledAt.Format(time.RFC3339)
+		}
+		w.Write([]string{
+			entry.CreatedAt.Format(time.RFC3339),
+			strconv.FormatUint(uint64(entry.TransactionID), 10),
+			strconv.FormatUint(uint64(entry.CourseID), 10),
+			csvText(entry.CourseTitle),
+			strconv.FormatFloat(entry.SaleAmount, 'f', 2, 64),
+			strconv.FormatFloat(entry.SharePercentage, 'f', 2, 64),
+			strconv.FormatFloat(entry.Amount, 'f', 2, 64),
+			settledAt,
+			csvText(entry.SettlementRef),
+		})
+	}
+	w.Write([]string{"total", "", "", "", strconv.FormatFloat(statement.SalesAmount, 'f', 2, 64), "", strconv.FormatFloat(statement.Earnings, 'f', 2, 64), "", ""})
+	w.Flush()
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateProfileRequest defines the request body for updating one's own profile. Omitted fields are left as they are.
+type UpdateProfileRequest struct {
+	Username  *string `json:"username" binding:"omitempty,min=3,max=50"`
+	Email     *string `json:"email" binding:"omitempty,email"` // A new email address is used once confirmed
+	FirstName *string `json:"first_name" binding:"omitempty,min=1"`
+	LastName  *string `json:"last_name" binding:"omitempty,min=1"`
+	Locale    *string `json:"locale" binding:"omitempty,oneof=id en"` // Language of the emails sent to the user
+}
+
+// ChangePasswordRequest defines the request body for changing one's own password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type ProfileHandler struct {
+	ProfileService services.ProfileServicer
+}
+
+func NewProfileHandler(profileService services.ProfileServicer) *ProfileHandler {
+	return &ProfileHandler{ProfileService: profileService}
+}
+
+// GetProfile godoc
+// @Summary Get my profile
+// @Description Retrieve the profile of the authenticated user
+// @Tags me
+// @Produce  json
+// @Success 200 {object} models.User
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me [get]
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	userID, _ := currentUser(c)
+
+	user, err := h.ProfileService.GetProfile(userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    user,
+	})
+}
+
+// UpdateProfile godoc
+// @Summary Update my profile
+// @Description Update the name, username, email address or email language of the authenticated user. Changing the email address sends a confirmation link to the new address, which replaces the current one (kept until then in pending_email) once confirmed, and a notice to the current address.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param profile body UpdateProfileRequest true "Fields to update"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string "Invalid input, or username or email already taken"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me [patch]
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	user, err := h.ProfileService.UpdateProfile(userID, services.ProfileUpdate{
+		Username:  req.Username,
+		Email:     req.Email,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Locale:    req.Locale,
+	})
+	if err != nil {
+		switch err.Error() {
+		case "username already taken", "email already registered", "username is reserved", "email is reserved":
+			c.AbortWithError(http.StatusBadRequest, err)
+		case "user not found":
+			c.AbortWithError(http.StatusNotFound, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "profile updated",
+		"data":    user,
+	})
+}
+
+// ChangePassword godoc
+// @Summary Change my password
+// @Description Change the password of the authenticated user. Every other session is signed out and a new token is returned for this one.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param passwords body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} LoginData
+// @Failure 400 {object} map[string]string "Invalid input or weak password"
+// @Failure 403 {object} map[string]string "Current password is incorrect"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/password [put]
+func (h *ProfileHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	token, err := h.ProfileService.ChangePassword(userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		switch err.Error() {
+		case "current password is incorrect":
+			c.AbortWithError(http.StatusForbidden, err)
+		case "password is weak":
+			c.AbortWithError(http.StatusBadRequest, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	setSessionCookie(c, token)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "password changed",
+		"data":    LoginData{Username: c.GetString("username"), Token: token},
+	})
+}
+
+// UpdateAvatar godoc
+// @Summary Upload my avatar
+// @Description Upload a new avatar image for the authenticated user, replacing the previous one
+// @Tags me
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param avatar formData file true "Avatar image, at most 2 MB"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string "Missing or invalid image"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/avatar [put]
+func (h *ProfileHandler) UpdateAvatar(c *gin.Context) {
+	avatar, err := c.FormFile("avatar")
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("avatar image is required"))
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	user, err := h.ProfileService.UpdateAvatar(userID, avatar)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAvatar) {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "avatar updated",
+		"data":    user,
+	})
+}
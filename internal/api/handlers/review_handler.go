@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SaveReviewRequest defines the request body for writing or editing a course review.
+type SaveReviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Body   string `json:"body" binding:"max=5000"`
+}
+
+// HideReviewRequest defines the request body for hiding an abusive review.
+type HideReviewRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ReviewHandler struct {
+	ReviewService services.ReviewServicer
+	AuditService  services.AuditServicer
+}
+
+func NewReviewHandler(reviewService services.ReviewServicer, auditService services.AuditServicer) *ReviewHandler {
+	return &ReviewHandler{ReviewService: reviewService, AuditService: auditService}
+}
+
+// GetCourseReviews godoc
+// @Summary Get a course's reviews
+// @Description Retrieve the visible reviews of a course, newest first
+// @Tags reviews
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Success 200 {object} []services.CourseReview
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/reviews [get]
+func (h *ReviewHandler) GetCourseReviews(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "15")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return
+	}
+	limit = min(limit, 50)
+
+	reviews, pagination, err := h.ReviewService.GetCourseReviews(uint(id), page, limit)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       reviews,
+		"pagination": pagination,
+	})
+}
+
+// GetMyReview godoc
+// @Summary Get my review of a course
+// @Description Retrieve the authenticated user's review of a course, including whether it was hidden and why
+// @Tags reviews
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Success 200 {object} models.Review
+// @Failure 400 {object} map[string]string "Invalid course ID"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/reviews/me [get]
+func (h *ReviewHandler) GetMyReview(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	review, err := h.ReviewService.GetMyReview(userID, uint(id))
+	if err != nil {
+		if err.Error() == "review not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    review,
+	})
+}
+
+// SaveReview godoc
+// @Summary Review a course
+// @Description Rate a course from 1 to 5 stars with an optional text, or edit the existing review. Only enrolled users who completed enough of the course can review it.
+// @Tags reviews
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Param review body SaveReviewRequest true "Rating and text"
+// @Success 200 {object} models.Review
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 403 {object} map[string]string "Not enrolled or not far enough in the course"
+// @Failure 404 {object} map[string]string "Course not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/reviews/me [put]
+func (h *ReviewHandler) SaveReview(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	var req SaveReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	review, err := h.ReviewService.SaveReview(userID, uint(id), req.Rating, req.Body)
+	if err != nil {
+		if errors.Is(err, services.ErrReviewNotAllowed) {
+			c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+		switch err.Error() {
+		case "course not found":
+			c.AbortWithError(http.StatusNotFound, err)
+		case "rating must be between 1 and 5":
+			c.AbortWithError(http.StatusBadRequest, err)
+		default:
+			c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "review saved",
+		"data":    review,
+	})
+}
+
+// DeleteReview godoc
+// @Summary Delete my review of a course
+// @Description Delete the authenticated user's review of a course
+// @Tags reviews
+// @Produce  json
+// @Param id path int true "Course ID"
+// @Success 204 "Review deleted"
+// @Failure 400 {object} map[string]string "Invalid course ID"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /courses/{id}/reviews/me [delete]
+func (h *ReviewHandler) DeleteReview(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid course ID"))
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	if err := h.ReviewService.DeleteReview(userID, uint(id)); err != nil {
+		if err.Error() == "review not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetReviews godoc
+// @Summary List reviews for moderation
+// @Description Retrieve reviews across all courses, newest first, optionally only hidden or visible ones
+// @Tags reviews
+// @Produce  json
+// @Param hidden query bool false "Only hidden (true) or visible (false) reviews"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Param q query string false "Search query"
+// @Success 200 {object} []services.CourseReview
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /reviews [get]
+func (h *ReviewHandler) GetReviews(c *gin.Context) {
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "15")
+	query := c.DefaultQuery("q", "")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return
+	}
+	limit = min(limit, 50)
+
+	var hidden *bool
+	if hiddenStr, ok := c.GetQuery("hidden"); ok {
+		value, err := strconv.ParseBool(hiddenStr)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, errors.New("invalid hidden flag"))
+			return
+		}
+		hidden = &value
+	}
+
+	reviews, pagination, err := h.ReviewService.GetReviews(hidden, page, limit, query)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       reviews,
+		"pagination": pagination,
+	})
+}
+
+// HideReview godoc
+// @Summary Hide a review
+// @Description Hide an abusive review from the course's reviews and rating
+// @Tags reviews
+// @Accept  json
+// @Produce  json
+// @Param id path int true "Review ID"
+// @Param moderation body HideReviewRequest true "Reason shown to the author"
+// @Success 200 {object} models.Review
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /reviews/{id}/hide [patch]
+func (h *ReviewHandler) HideReview(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid review ID"))
+		return
+	}
+
+	var req HideReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	adminID, _ := currentUser(c)
+
+	before := h.AuditService.Snapshot(models.AuditTargetReview, uint(id))
+	review, err := h.ReviewService.HideReview(uint(id), adminID, req.Reason)
+	if err != nil {
+		if err.Error() == "review not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditReviewHide, models.AuditTargetReview, id, before, review)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "review hidden",
+		"data":    review,
+	})
+}
+
+// UnhideReview godoc
+// @Summary Unhide a review
+// @Description Restore a hidden review to the course's reviews and rating
+// @Tags reviews
+// @Produce  json
+// @Param id path int true "Review ID"
+// @Success 200 {object} models.Review
+// @Failure 400 {object} map[string]string "Invalid review ID"
+// @Failure 404 {object} map[string]string "Review not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /reviews/{id}/unhide [patch]
+func (h *ReviewHandler) UnhideReview(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid review ID"))
+		return
+	}
+
+	before := h.AuditService.Snapshot(models.AuditTargetReview, uint(id))
+	review, err := h.ReviewService.UnhideReview(uint(id))
+	if err != nil {
+		if err.Error() == "review not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditReviewUnhide, models.AuditTargetReview, id, before, review)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "review restored",
+		"data":    review,
+	})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	SearchService services.SearchServicer
+}
+
+func NewSearchHandler(searchService services.SearchServicer) *SearchHandler {
+	return &SearchHandler{SearchService: searchService}
+}
+
+// Search godoc
+// @Summary Search courses and modules
+// @Description Ranked full-text search across courses and modules, with highlighted snippets. Snippets are HTML-escaped with matches wrapped in <mark>.
+// @Tags search
+// @Produce  json
+// @Param q query string true "Search query, supports quoted phrases, OR and -exclusions"
+// @Param type query string false "Only return course or module results"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Items per page (default 15)"
+// @Success 200 {object} []services.SearchResult
+// @Failure 400 {object} map[string]string "Invalid input"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.AbortWithError(http.StatusBadRequest, errors.New("search query is required"))
+		return
+	}
+
+	kind := c.DefaultQuery("type", "")
+	if kind != "" && kind != services.SearchKindCourse && kind != services.SearchKindModule {
+		c.AbortWithError(http.StatusBadRequest, errors.New("type must be course or module"))
+		return
+	}
+
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "15")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid page number"))
+		return
+	}
+	limit, err := strconv.ParseInt(limitStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid limit number"))
+		return
+	}
+	limit = min(limit, 50)
+
+	userID, _ := currentUser(c)
+
+	results, pagination, err := h.SearchService.Search(userID, query, kind, page, limit, isAdmin(c))
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"message":    "Query success",
+		"data":       results,
+		"pagination": pagination,
+	})
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateTokenRequest defines the request body for creating a personal access token.
+type CreateTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"` // For example courses:read, courses:write, users:read or users:write
+	ExpiresAt *time.Time `json:"expires_at"`                      // Optional, the token stays valid until revoked without it
+}
+
+// CreatedTokenData is a new personal access token. Token is only returned once.
+type CreatedTokenData struct {
+	models.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+type TokenHandler struct {
+	TokenService services.PersonalAccessTokenServicer
+}
+
+func NewTokenHandler(tokenService services.PersonalAccessTokenServicer) *TokenHandler {
+	return &TokenHandler{TokenService: tokenService}
+}
+
+// CreateToken godoc
+// @Summary Create a personal access token
+// @Description Create a token for scripts to call the API as the authenticated user, sent as "Authorization: Bearer gat_...". The token can only use the routes of its scopes, and is only shown in this response.
+// @Tags me
+// @Accept  json
+// @Produce  json
+// @Param token body CreateTokenRequest true "Token name, scopes and optional expiry"
+// @Success 201 {object} CreatedTokenData
+// @Failure 400 {object} map[string]string "Invalid input, invalid scope or too many tokens"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/tokens [post]
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	accessToken, token, err := h.TokenService.CreateToken(userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) || errors.Is(err, services.ErrTooManyTokens) ||
+			err.Error() == "expiry must be in the future" {
+			c.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "token created, copy it now as it will not be shown again",
+		"data":    CreatedTokenData{PersonalAccessToken: *accessToken, Token: token},
+	})
+}
+
+// GetTokens godoc
+// @Summary List my personal access tokens
+// @Description List the personal access tokens of the authenticated user that were not revoked
+// @Tags me
+// @Produce  json
+// @Success 200 {object} []models.PersonalAccessToken
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/tokens [get]
+func (h *TokenHandler) GetTokens(c *gin.Context) {
+	userID, _ := currentUser(c)
+
+	tokens, err := h.TokenService.GetTokens(userID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Query success",
+		"data":    tokens,
+	})
+}
+
+// RevokeToken godoc
+// @Summary Revoke a personal access token
+// @Description Revoke one of the authenticated user's personal access tokens. It stops working immediately.
+// @Tags me
+// @Produce  json
+// @Param id path int true "Token ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string "Invalid token ID"
+// @Failure 404 {object} map[string]string "Token not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /me/tokens/{id} [delete]
+func (h *TokenHandler) RevokeToken(c *gin.Context) {
+	tokenID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid token ID"))
+		return
+	}
+
+	userID, _ := currentUser(c)
+
+	if err := h.TokenService.RevokeToken(userID, uint(tokenID)); err != nil {
+		if err.Error() == "token not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "token revoked",
+		"data":    nil,
+	})
+}
@@ -6,29 +6,34 @@ import (
 	"strconv"
 
 	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/pagination"
 	"grocademy/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UpdateUserRequest struct {
-	Email     string `json:"email,omitempty" binding:"omitempty,email"`
-	Username  string `json:"username,omitempty" binding:"omitempty"`
-	FirstName string `json:"first_name,omitempty" binding:"omitempty"`
-	LastName  string `json:"last_name,omitempty" binding:"omitempty"`
-	Password  string `json:"password,omitempty" binding:"omitempty"`
+	Email             string   `json:"email,omitempty" binding:"omitempty,email"`
+	Username          string   `json:"username,omitempty" binding:"omitempty"`
+	FirstName         string   `json:"first_name,omitempty" binding:"omitempty"`
+	LastName          string   `json:"last_name,omitempty" binding:"omitempty"`
+	Password          string   `json:"password,omitempty" binding:"omitempty"`
+	Role              string   `json:"role,omitempty" binding:"omitempty,oneof=student instructor"`
+	RevenueShare      *float64 `json:"revenue_share,omitempty" binding:"omitempty,min=0,max=100"` // Instructor's percentage of each sale
+	ClearRevenueShare bool     `json:"clear_revenue_share,omitempty"`                             // Fall back to the default share
 }
 
 type UserHandler struct {
-	UserService services.UserServicer
+	UserService  services.UserServicer
+	AuditService services.AuditServicer
 }
 
 type IncrementRequest struct {
 	Increment float64 `json:"increment" binding:"required"`
 }
 
-func NewUserHandler(userService services.UserServicer) *UserHandler {
-	return &UserHandler{UserService: userService}
+func NewUserHandler(userService services.UserServicer, auditService services.AuditServicer) *UserHandler {
+	return &UserHandler{UserService: userService, AuditService: auditService}
 }
 
 // CreateUser godoc
@@ -54,6 +59,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditUserCreate, models.AuditTargetUser, user.ID, nil, user)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"status":  "success",
@@ -101,13 +107,18 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 
 // GetAllUsers godoc
 // @Summary Get all users with pagination and search
-// @Description Retrieve a list of all users with optional pagination and search parameters
+// @Description Retrieve a list of all users with optional pagination and search parameters.
+// @Description Passing cursor (empty for the first page) switches to keyset pagination, newest users first, with next and prev links.
 // @Tags users
 // @Produce  json
 // @Param page query int false "Page number (default 1)"
 // @Param limit query int false "Items per page (default 15)"
 // @Param q query string false "Search query"
+// @Param cursor query string false "Keyset cursor from a previous page's next_cursor or prev_cursor"
+// @Param count query bool false "Also count the users in keyset mode (default false)"
+// @Param verified query bool false "Only users whose email address is verified (true) or unverified (false)"
 // @Success 200 {object} []models.User
+// @Failure 400 {object} map[string]string "Invalid input"
 // @Failure 500 {object} map[string]string
 // @Security Bearer
 // @Router /users [get]
@@ -127,10 +138,47 @@ func (h *UserHandler) GetAllUsers(c *gin.Context) {
 		return
 	}
 
-	page = max(page, 0)
-	limit = max(50, min(limit, 0))
+	page = max(page, 1)
+	limit = min(max(limit, 1), 50)
 
-	paginatedUsers, pagination, err := h.UserService.GetAllUsersPaginated(int64(page), int64(limit), query)
+	var verified *bool
+	if value, ok := c.GetQuery("verified"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, errors.New("invalid verified flag"))
+			return
+		}
+		verified = &parsed
+	}
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		withCount, err := strconv.ParseBool(c.DefaultQuery("count", "false"))
+		if err != nil {
+			c.AbortWithError(http.StatusBadRequest, errors.New("invalid count flag"))
+			return
+		}
+
+		users, cursors, err := h.UserService.GetUsersByCursor(cursor, limit, query, verified, withCount)
+		if err != nil {
+			if errors.Is(err, pagination.ErrInvalidCursor) {
+				c.AbortWithError(http.StatusBadRequest, err)
+				return
+			}
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		setCursorLinks(c, &cursors)
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":     "success",
+			"message":    "Query success",
+			"data":       users,
+			"pagination": cursors,
+		})
+		return
+	}
+
+	paginatedUsers, pagination, err := h.UserService.GetAllUsersPaginated(int64(page), int64(limit), query, verified)
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -171,7 +219,7 @@ func (h *UserHandler) IncrementBalance(c *gin.Context) {
 		return
 	}
 
-	updatedUser, err := h.UserService.IncrementUserBalance(uint(id), req.Increment)
+	updatedUser, err := h.UserService.IncrementUserBalance(uint(id), req.Increment, auditActor(c))
 	if err != nil {
 		if err.Error() == "user not found" {
 			c.AbortWithError(http.StatusNotFound, err)
@@ -188,6 +236,82 @@ func (h *UserHandler) IncrementBalance(c *gin.Context) {
 	})
 }
 
+// VerifyEmail godoc
+// @Summary Verify a user's email address
+// @Description Mark a user's email address as verified without the emailed verification link
+// @Tags users
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User "Verified user"
+// @Failure 400 {object} map[string]string "Invalid user ID"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /users/{id}/verify-email [patch]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid user ID"))
+		return
+	}
+
+	before := h.AuditService.Snapshot(models.AuditTargetUser, uint(id))
+	user, err := h.UserService.VerifyUserEmail(uint(id))
+	if err != nil {
+		if err.Error() == "user not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditUserVerifyEmail, models.AuditTargetUser, id, before, user)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "email verified",
+		"data":    user,
+	})
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user's account
+// @Description Forget the failed logins of a user, so an account locked out after too many wrong passwords can sign in again right away
+// @Tags users
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string "Invalid user ID"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Security Bearer
+// @Router /users/{id}/unlock [post]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, errors.New("invalid user ID"))
+		return
+	}
+
+	if err := h.UserService.UnlockUser(uint(id)); err != nil {
+		if err.Error() == "user not found" {
+			c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	recordAudit(c, h.AuditService, models.AuditUserUnlock, models.AuditTargetUser, id, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "user unlocked",
+		"data":    nil,
+	})
+}
+
 // UpdateUser godoc
 // @Summary Update a user's data
 // @Description Update specified fields of a user by ID
@@ -232,12 +356,21 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	if req.Password != "" {
 		updates["Password"] = req.Password
 	}
+	if req.Role != "" {
+		updates["Role"] = req.Role
+	}
+	if req.ClearRevenueShare {
+		updates["RevenueShare"] = nil
+	} else if req.RevenueShare != nil {
+		updates["RevenueShare"] = *req.RevenueShare
+	}
 
 	if len(updates) == 0 {
 		c.AbortWithError(http.StatusBadRequest, errors.New("no fields to update"))
 		return
 	}
 
+	before := h.AuditService.Snapshot(models.AuditTargetUser, uint(id))
 	updatedUser, err := h.UserService.UpdateUser(uint(id), updates)
 	if err != nil {
 		if err.Error() == "user not found" {
@@ -247,6 +380,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditUserUpdate, models.AuditTargetUser, id, before, updatedUser)
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -275,6 +409,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	before := h.AuditService.Snapshot(models.AuditTargetUser, uint(id))
 	err = h.UserService.DeleteUser(uint(id))
 
 	if err != nil {
@@ -285,6 +420,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
+	recordAudit(c, h.AuditService, models.AuditUserDelete, models.AuditTargetUser, id, before, nil)
 
 	c.Status(http.StatusNoContent)
 }
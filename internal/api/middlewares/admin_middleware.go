@@ -15,8 +15,8 @@ func NewAdminMiddleware() *AdminMiddleware {
 
 func (am AdminMiddleware) GetHandlerFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		username, _ := c.Get("username")
-		if username != "admin" {
+		role, _ := c.Get("role")
+		if role != "admin" {
 			c.AbortWithError(http.StatusUnauthorized, errors.New("route only for admin"))
 			return
 		}
@@ -2,16 +2,30 @@ package middlewares
 
 import (
 	"grocademy/internal/auth"
+	"grocademy/internal/db/models"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-type AuthAPIMiddleware struct{}
+// SessionValidator rejects tokens of sessions that were revoked after the token was issued.
+type SessionValidator interface {
+	ValidateSession(userID uint, sessionVersion int) error
+}
+
+// TokenAuthenticator finds the user and scopes of a personal access token.
+type TokenAuthenticator interface {
+	AuthenticateToken(token string) (*models.User, []string, error)
+}
 
-func NewAuthAPIMiddleware() *AuthAPIMiddleware {
-	return &AuthAPIMiddleware{}
+type AuthAPIMiddleware struct {
+	Sessions SessionValidator
+	Tokens   TokenAuthenticator
+}
+
+func NewAuthAPIMiddleware(sessions SessionValidator, tokens TokenAuthenticator) *AuthAPIMiddleware {
+	return &AuthAPIMiddleware{Sessions: sessions, Tokens: tokens}
 }
 
 func (am AuthAPIMiddleware) GetHandlerFunc() gin.HandlerFunc {
@@ -36,6 +50,23 @@ func (am AuthAPIMiddleware) GetHandlerFunc() gin.HandlerFunc {
 			tokenString = parts[1]
 		}
 
+		if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			user, scopes, err := am.Tokens.AuthenticateToken(tokenString)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token: " + err.Error()})
+				return
+			}
+
+			// Only requests authenticated with a personal access token have scopes, see ScopeMiddleware
+			c.Set("username", user.Username)
+			c.Set("email", user.Email)
+			c.Set("id", user.ID)
+			c.Set("role", user.Role)
+			c.Set("scopes", scopes)
+			c.Next()
+			return
+		}
+
 		claims, err := auth.ValidateJWT(tokenString)
 		if err != nil {
 			status := http.StatusUnauthorized
@@ -45,11 +76,16 @@ func (am AuthAPIMiddleware) GetHandlerFunc() gin.HandlerFunc {
 			c.AbortWithStatusJSON(status, gin.H{"error": "Invalid or expired token: " + err.Error()})
 			return
 		}
+		if err := am.Sessions.ValidateSession(claims.ID, claims.SessionVersion); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token: " + err.Error()})
+			return
+		}
 
 		// Store user information in context for handlers
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
 		c.Set("id", claims.ID)
+		c.Set("role", claims.Role)
 		c.Next()
 	}
 }
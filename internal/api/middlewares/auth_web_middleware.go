@@ -8,10 +8,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type AuthWebMiddleware struct{}
+type AuthWebMiddleware struct {
+	Sessions SessionValidator
+}
 
-func NewAuthWebMiddleware() *AuthWebMiddleware {
-	return &AuthWebMiddleware{}
+func NewAuthWebMiddleware(sessions SessionValidator) *AuthWebMiddleware {
+	return &AuthWebMiddleware{Sessions: sessions}
 }
 
 func (am AuthWebMiddleware) GetHandlerFunc() gin.HandlerFunc {
@@ -24,7 +26,10 @@ func (am AuthWebMiddleware) GetHandlerFunc() gin.HandlerFunc {
 			return
 		}
 
-		_, err = auth.ValidateJWT(tokenString)
+		claims, err := auth.ValidateJWT(tokenString)
+		if err == nil {
+			err = am.Sessions.ValidateSession(claims.ID, claims.SessionVersion)
+		}
 		if err != nil {
 			// If token is invalid or expired, redirect to the login page
 			c.Redirect(http.StatusFound, "/login")
@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"grocademy/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSRFCookie holds the token that cookie authenticated requests have to repeat in CSRFHeader.
+	// Scripts can read it, other sites cannot.
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+	// sessionCookie is the cookie that authenticates browser requests, see AuthAPIMiddleware.
+	sessionCookie = "jwt_token"
+)
+
+// CSRFMiddleware protects cookie authenticated requests from being sent by other sites. Requests that
+// change state are rejected when their Origin (or Referer) is not allowed, and, when they carry the
+// session cookie, unless they repeat the CSRF cookie in the X-CSRF-Token header (double submit).
+// Requests authenticated with an Authorization header cannot be forged by another site, so scripts and
+// the admin FE are not affected as long as they do not send the session cookie.
+//
+// It also gives every client a CSRF cookie.
+type CSRFMiddleware struct {
+	AllowedOrigins []string // Besides the origin the request was sent to
+}
+
+func NewCSRFMiddleware(allowedOrigins []string) *CSRFMiddleware {
+	return &CSRFMiddleware{AllowedOrigins: allowedOrigins}
+}
+
+func (cm CSRFMiddleware) GetHandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		csrfToken, _ := c.Cookie(CSRFCookie)
+		if csrfToken == "" {
+			csrfToken = newCSRFToken()
+			SetCookie(c, CSRFCookie, csrfToken, 0, "/", false)
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if !cm.isAllowedSource(c.Request) {
+			c.AbortWithError(http.StatusForbidden, errors.New("cross-site request rejected"))
+			return
+		}
+		if _, err := c.Cookie(sessionCookie); err == nil {
+			header := c.GetHeader(CSRFHeader)
+			if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(csrfToken)) != 1 {
+				c.AbortWithError(http.StatusForbidden, errors.New("missing or invalid CSRF token"))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// IsAllowedOrigin reports whether requests from the origin, like https://example.com, are allowed.
+func (cm CSRFMiddleware) IsAllowedOrigin(origin string) bool {
+	return slices.Contains(cm.AllowedOrigins, strings.TrimRight(origin, "/"))
+}
+
+// isAllowedSource checks the site a request was sent from. Clients that are not browsers send neither
+// Origin nor Referer and are let through.
+func (cm CSRFMiddleware) isAllowedSource(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "null" {
+		// Sent by sandboxed documents and after cross-site redirects
+		return false
+	}
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		return true
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil || sourceURL.Host == "" {
+		return false
+	}
+	// Browsers do not let other sites set the Host header, so same-origin requests pass without configuration
+	if sourceURL.Host == r.Host {
+		return true
+	}
+	return cm.IsAllowedOrigin(sourceURL.Scheme + "://" + sourceURL.Host)
+}
+
+// SetCookie sets a cookie that is only sent by same-site requests and top-level navigations, and only
+// over HTTPS when APP_ENV is production. A maxAge of 0 makes a session cookie, a negative one deletes it.
+func SetCookie(c *gin.Context, name, value string, maxAge int, path string, httpOnly bool) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(name, value, maxAge, path, "", auth.IsProduction(), httpOnly)
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to generate CSRF token: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
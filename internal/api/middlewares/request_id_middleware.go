@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request, so its logs and audit entries can be found.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits the request IDs accepted from clients and proxies.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestIDMiddleware gives every request an ID, stored in the context as "request_id" and echoed in
+// the response. The X-Request-ID header is only kept when a trusted proxy already set a valid one, so
+// clients cannot pass their requests off as someone else's in the audit log.
+type RequestIDMiddleware struct {
+	trustedProxies []netip.Prefix
+}
+
+// NewRequestIDMiddleware takes the addresses and CIDR ranges of the trusted proxies. Invalid ones are ignored.
+func NewRequestIDMiddleware(trustedProxies []string) *RequestIDMiddleware {
+	var prefixes []netip.Prefix
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if addr, err := netip.ParseAddr(proxy); err == nil {
+				prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			}
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return &RequestIDMiddleware{trustedProxies: prefixes}
+}
+
+func (rm RequestIDMiddleware) GetHandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !rm.fromTrustedProxy(c) || !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// fromTrustedProxy reports whether the request was sent by one of the trusted proxies.
+func (rm RequestIDMiddleware) fromTrustedProxy(c *gin.Context) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range rm.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to generate request ID: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleMiddleware only lets through users whose role is one of Roles.
+type RoleMiddleware struct {
+	Roles []string
+}
+
+func NewRoleMiddleware(roles ...string) *RoleMiddleware {
+	return &RoleMiddleware{Roles: roles}
+}
+
+func (rm RoleMiddleware) GetHandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(rm.Roles, c.GetString("role")) {
+			c.AbortWithError(http.StatusForbidden, errors.New("route not available for your role"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grocademy/internal/db/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestScopeMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	courses := NewScopeMiddleware(models.ScopeCoursesRead, models.ScopeCoursesWrite)
+	writeOnly := NewScopeMiddleware("", models.ScopeUsersWrite)
+	sessionOnly := NewSessionOnlyMiddleware()
+
+	tests := []struct {
+		name       string
+		middleware *ScopeMiddleware
+		method     string
+		scopes     []string // nil for a session
+		wantStatus int
+	}{
+		{"session reads", courses, http.MethodGet, nil, http.StatusOK},
+		{"session writes", courses, http.MethodPost, nil, http.StatusOK},
+		{"read scope reads", courses, http.MethodGet, []string{models.ScopeCoursesRead}, http.StatusOK},
+		{"read scope reads headers", courses, http.MethodHead, []string{models.ScopeCoursesRead}, http.StatusOK},
+		{"read scope writes", courses, http.MethodPost, []string{models.ScopeCoursesRead}, http.StatusForbidden},
+		{"read scope deletes", courses, http.MethodDelete, []string{models.ScopeCoursesRead}, http.StatusForbidden},
+		{"write scope reads", courses, http.MethodGet, []string{models.ScopeCoursesWrite}, http.StatusOK},
+		{"write scope writes", courses, http.MethodPut, []string{models.ScopeCoursesWrite}, http.StatusOK},
+		{"other resource's scope reads", courses, http.MethodGet, []string{models.ScopeUsersRead, models.ScopeUsersWrite}, http.StatusForbidden},
+		{"token without scopes reads", courses, http.MethodGet, []string{}, http.StatusForbidden},
+		{"write-only route read with read scope", writeOnly, http.MethodGet, []string{models.ScopeUsersRead}, http.StatusForbidden},
+		{"write-only route read with write scope", writeOnly, http.MethodGet, []string{models.ScopeUsersWrite}, http.StatusOK},
+		{"session-only route with a session", sessionOnly, http.MethodPost, nil, http.StatusOK},
+		{"session-only route reads with a token", sessionOnly, http.MethodGet, []string{models.ScopeCoursesRead, models.ScopeCoursesWrite}, http.StatusForbidden},
+		{"session-only route writes with a token", sessionOnly, http.MethodPost, []string{models.ScopeUsersWrite}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.scopes != nil {
+					c.Set("scopes", tt.scopes)
+				}
+			})
+			r.Handle(tt.method, "/resource", tt.middleware.GetHandlerFunc(), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, "/resource", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s responded %d, want %d", tt.method, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// tokenAuthenticator authenticates one personal access token.
+type tokenAuthenticator struct {
+	token  string
+	user   models.User
+	scopes []string
+}
+
+func (a tokenAuthenticator) AuthenticateToken(token string) (*models.User, []string, error) {
+	if token != a.token {
+		return nil, nil, errors.New("invalid, revoked or expired access token")
+	}
+	return &a.user, a.scopes, nil
+}
+
+func TestAuthAPIMiddlewareScopesTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	valid := models.PersonalAccessTokenPrefix + "valid"
+	tokens := tokenAuthenticator{token: valid, user: models.User{ID: 7, Username: "ada"}, scopes: []string{models.ScopeCoursesRead}}
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		wantStatus int
+	}{
+		{"valid token reads", http.MethodGet, valid, http.StatusOK},
+		{"valid token writes without the scope", http.MethodPost, valid, http.StatusForbidden},
+		{"revoked or expired token", http.MethodGet, models.PersonalAccessTokenPrefix + "revoked", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Handle(tt.method, "/courses",
+				NewAuthAPIMiddleware(nil, tokens).GetHandlerFunc(),
+				NewScopeMiddleware(models.ScopeCoursesRead, models.ScopeCoursesWrite).GetHandlerFunc(),
+				func(c *gin.Context) { c.Status(http.StatusOK) },
+			)
+
+			req := httptest.NewRequest(tt.method, "/courses", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s responded %d, want %d", tt.method, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
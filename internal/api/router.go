@@ -3,11 +3,14 @@ package api
 import (
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"grocademy/internal/api/handlers"
 	"grocademy/internal/api/middlewares"
+	"grocademy/internal/db/models"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -31,17 +34,40 @@ func NewRouter(
 	authHandler *handlers.AuthHandler,
 	courseHandler *handlers.CourseHandler,
 	moduleHandler *handlers.ModuleHandler,
+	instructorHandler *handlers.InstructorHandler,
+	payoutHandler *handlers.PayoutHandler,
+	searchHandler *handlers.SearchHandler,
+	reviewHandler *handlers.ReviewHandler,
+	commentHandler *handlers.CommentHandler,
+	eventHandler *handlers.EventHandler,
+	notificationHandler *handlers.NotificationHandler,
+	profileHandler *handlers.ProfileHandler,
+	mfaHandler *handlers.MFAHandler,
+	oidcHandler *handlers.OIDCHandler,
+	tokenHandler *handlers.TokenHandler,
+	jwksHandler *handlers.JWKSHandler,
+	auditHandler *handlers.AuditHandler,
 ) GinRouterWrapper {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 	docs.SwaggerInfo.BasePath = "/api"
 
-	// CORS config
+	// Client IPs and request IDs are only taken from headers when the request comes through a trusted proxy
+	proxies := trustedProxies()
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Tag every request with an ID, so logs and audit log entries can be traced back to it
+	r.Use(middlewares.NewRequestIDMiddleware(proxies).GetHandlerFunc())
+
+	// CORS config, browsers only send cookies cross-site from the allowed origins
+	csrfMiddleware := middlewares.NewCSRFMiddleware(allowedOrigins())
 	r.Use(cors.New(cors.Config{
-		AllowAllOrigins:  true,
+		AllowOriginFunc:  csrfMiddleware.IsAllowedOrigin,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", middlewares.CSRFHeader},
+		ExposeHeaders:    []string{"Content-Length", middlewares.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -56,12 +82,19 @@ func NewRouter(
 		"web/templates/course_modules.html",
 		"web/templates/course.html",
 		"web/templates/register.html",
-		"web/templates/login.html")
+		"web/templates/login.html",
+		"web/templates/forgot_password.html",
+		"web/templates/reset_password.html",
+		"web/templates/verify_email.html")
 	r.Static("/static", "./web/static")
 
 	// use error (handler) middleware
 	var errorMiddleware middlewares.ErrorMiddleware
 	r.Use(errorMiddleware.GetHandlerFunc())
+	r.Use(csrfMiddleware.GetHandlerFunc())
+
+	// Keys for other services to verify session tokens with
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
 
 	// Public FE routes
 	r.GET("/register", func(c *gin.Context) {
@@ -70,9 +103,18 @@ func NewRouter(
 	r.GET("/login", func(c *gin.Context) {
 		c.HTML(http.StatusOK, "login.html", gin.H{})
 	})
+	r.GET("/forgot-password", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "forgot_password.html", gin.H{})
+	})
+	r.GET("/reset-password", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "reset_password.html", gin.H{})
+	})
+	r.GET("/verify-email", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "verify_email.html", gin.H{})
+	})
 
 	// Protected FE routes
-	authWebMiddleware := middlewares.NewAuthWebMiddleware()
+	authWebMiddleware := middlewares.NewAuthWebMiddleware(authHandler.AuthService)
 	authenticatedWeb := r.Group("")
 	authenticatedWeb.Use(authWebMiddleware.GetHandlerFunc())
 	{
@@ -105,24 +147,58 @@ func NewRouter(
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/login/2fa", authHandler.CompleteLogin)
+			auth.POST("/login/2fa/setup", authHandler.BeginLoginTOTPSetup)
+			auth.POST("/password/forgot", authHandler.ForgotPassword)
+			auth.POST("/password/reset", authHandler.ResetPassword)
+			auth.POST("/email/verify", authHandler.VerifyEmail)
+			auth.POST("/email/change/confirm", authHandler.ConfirmEmailChange)
+			auth.GET("/oidc/providers", oidcHandler.GetProviders)
+			auth.GET("/oidc/:provider/login", oidcHandler.Login)
+			auth.GET("/oidc/:provider/callback", oidcHandler.Callback)
 		}
 	}
 
 	// requrires auth (bearer token)
 	protectedAPI := r.Group("/api")
 
-	authAPIMiddleware := middlewares.NewAuthAPIMiddleware()
+	authAPIMiddleware := middlewares.NewAuthAPIMiddleware(authHandler.AuthService, tokenHandler.TokenService)
 	protectedAPI.Use(authAPIMiddleware.GetHandlerFunc())
 
+	// every protected group declares the personal access token scopes it needs
+	sessionOnlyMiddleware := middlewares.NewSessionOnlyMiddleware()
+	coursesScopeMiddleware := middlewares.NewScopeMiddleware(models.ScopeCoursesRead, models.ScopeCoursesWrite)
+	usersScopeMiddleware := middlewares.NewScopeMiddleware(models.ScopeUsersRead, models.ScopeUsersWrite)
+
 	adminMiddleware := middlewares.NewAdminMiddleware()
+	// course and module ownership is checked by the handlers
+	courseManagerMiddleware := middlewares.NewRoleMiddleware("admin", "instructor")
 	{
 		auth := protectedAPI.Group("/auth")
+		auth.Use(sessionOnlyMiddleware.GetHandlerFunc())
 		{
 			auth.GET("/self", authHandler.Self)
+			auth.POST("/email/resend", authHandler.ResendVerificationEmail)
+		}
+
+		me := protectedAPI.Group("/me")
+		me.Use(sessionOnlyMiddleware.GetHandlerFunc())
+		{
+			me.GET("", profileHandler.GetProfile)
+			me.PATCH("", profileHandler.UpdateProfile)
+			me.PUT("/password", profileHandler.ChangePassword)
+			me.PUT("/avatar", profileHandler.UpdateAvatar)
+			me.POST("/2fa/setup", mfaHandler.BeginSetup)
+			me.POST("/2fa/enable", mfaHandler.Enable)
+			me.POST("/2fa/disable", mfaHandler.Disable)
+			me.POST("/2fa/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+			me.GET("/tokens", tokenHandler.GetTokens)
+			me.POST("/tokens", tokenHandler.CreateToken)
+			me.DELETE("/tokens/:id", tokenHandler.RevokeToken)
 		}
 
 		users := protectedAPI.Group("/users")
-		users.Use(adminMiddleware.GetHandlerFunc())
+		users.Use(usersScopeMiddleware.GetHandlerFunc(), adminMiddleware.GetHandlerFunc())
 		{
 			users.GET("", userHandler.GetAllUsers)
 			users.POST("", userHandler.CreateUser)
@@ -133,29 +209,58 @@ func NewRouter(
 				user.PUT("", userHandler.UpdateUser)
 				user.DELETE("", userHandler.DeleteUser)
 				user.POST("/balance", userHandler.IncrementBalance)
+				user.PATCH("/verify-email", userHandler.VerifyEmail)
+				user.POST("/unlock", userHandler.UnlockUser)
 			}
 		}
 
+		protectedAPI.GET("/search", coursesScopeMiddleware.GetHandlerFunc(), searchHandler.Search)
+		protectedAPI.GET("/events", sessionOnlyMiddleware.GetHandlerFunc(), eventHandler.StreamEvents)
+
+		notifications := protectedAPI.Group("/notifications")
+		notifications.Use(sessionOnlyMiddleware.GetHandlerFunc())
+		{
+			notifications.GET("", notificationHandler.GetNotifications)
+			notifications.GET("/unread-count", notificationHandler.GetUnreadCount)
+			notifications.POST("/read-all", notificationHandler.MarkAllRead)
+			notifications.PATCH("/:id/read", notificationHandler.MarkRead)
+			notifications.GET("/preferences", notificationHandler.GetPreferences)
+			notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
+		}
+
 		courses := protectedAPI.Group("/courses")
+		courses.Use(coursesScopeMiddleware.GetHandlerFunc())
 		{
 			courses.GET("", courseHandler.GetAllCourses)
 			courses.GET("/my-courses", courseHandler.GetMyCourses)
 			courses.POST("/:id/buy", courseHandler.BuyCourse)
 			courses.GET("/:id", courseHandler.GetCourseByID)
+			courses.GET("/:id/reviews", reviewHandler.GetCourseReviews)
+			courses.GET("/:id/reviews/me", reviewHandler.GetMyReview)
+			courses.PUT("/:id/reviews/me", reviewHandler.SaveReview)
+			courses.DELETE("/:id/reviews/me", reviewHandler.DeleteReview)
 
 			protectedCourses := courses.Group("")
-			protectedCourses.Use(adminMiddleware.GetHandlerFunc())
+			protectedCourses.Use(courseManagerMiddleware.GetHandlerFunc())
 
 			protectedCourses.POST("", courseHandler.CreateCourse)
 			protectedCourses.PUT("/:id", courseHandler.UpdateCourse)
-			protectedCourses.DELETE("/:id", courseHandler.DeleteCourse)
+			protectedCourses.DELETE("/:id", adminMiddleware.GetHandlerFunc(), courseHandler.DeleteCourse)
+			protectedCourses.PUT("/:id/prerequisites", courseHandler.SetCoursePrerequisites)
+			protectedCourses.PATCH("/:id/status", courseHandler.ChangeCourseStatus)
+			protectedCourses.GET("/:id/revisions", courseHandler.GetCourseRevisions)
+			protectedCourses.GET("/:id/revisions/:version", courseHandler.GetCourseRevision)
+			protectedCourses.POST("/:id/revisions/:version/restore", courseHandler.RestoreCourseRevision)
+			protectedCourses.GET("/:id/instructors", instructorHandler.GetCourseInstructors)
+			protectedCourses.POST("/:id/instructors", instructorHandler.AddCourseInstructor)
+			protectedCourses.DELETE("/:id/instructors/:userId", instructorHandler.RemoveCourseInstructor)
 
 			modulesByCourse := courses.Group("/:id/modules")
 			{
 				modulesByCourse.GET("", moduleHandler.GetAllModulesByCourseID)
 
 				protectedModulesByCourse := modulesByCourse.Group("")
-				protectedModulesByCourse.Use(adminMiddleware.GetHandlerFunc())
+				protectedModulesByCourse.Use(courseManagerMiddleware.GetHandlerFunc())
 
 				protectedModulesByCourse.POST("", moduleHandler.CreateModule)
 				protectedModulesByCourse.PATCH("/reorder", moduleHandler.ReorderModules)
@@ -163,14 +268,66 @@ func NewRouter(
 		}
 
 		modules := protectedAPI.Group("/modules")
+		modules.Use(coursesScopeMiddleware.GetHandlerFunc())
 		{
 			modules.GET("/:id", moduleHandler.GetModuleByID)
 			modules.PATCH("/:id/complete", moduleHandler.CompleteModuleByID)
+			modules.GET("/:id/prerequisites", moduleHandler.GetModulePrerequisites)
+			modules.GET("/:id/comments", commentHandler.GetThreads)
+			modules.POST("/:id/comments", commentHandler.CreateThread)
 
 			protectedModules := modules.Group("")
-			protectedModules.Use(adminMiddleware.GetHandlerFunc())
+			protectedModules.Use(courseManagerMiddleware.GetHandlerFunc())
 			protectedModules.PUT("/:id", moduleHandler.UpdateModule)
 			protectedModules.DELETE("/:id", moduleHandler.DeleteModule)
+			protectedModules.PUT("/:id/prerequisites", moduleHandler.SetModulePrerequisites)
+			protectedModules.GET("/:id/revisions", moduleHandler.GetModuleRevisions)
+			protectedModules.GET("/:id/revisions/:version", moduleHandler.GetModuleRevision)
+			protectedModules.POST("/:id/revisions/:version/restore", moduleHandler.RestoreModuleRevision)
+		}
+
+		instructor := protectedAPI.Group("/instructor")
+		instructor.Use(coursesScopeMiddleware.GetHandlerFunc(), middlewares.NewRoleMiddleware("instructor").GetHandlerFunc())
+		{
+			instructor.GET("/courses", instructorHandler.GetInstructorCourses)
+			instructor.GET("/courses/:id/enrollments", instructorHandler.GetCourseEnrollments)
+			instructor.GET("/statements", payoutHandler.GetStatementSummaries)
+			instructor.GET("/statements/:period", payoutHandler.GetStatement)
+		}
+
+		comments := protectedAPI.Group("/comments/:id")
+		comments.Use(sessionOnlyMiddleware.GetHandlerFunc())
+		{
+			comments.GET("", commentHandler.GetThread)
+			comments.PUT("", commentHandler.UpdateComment)
+			comments.DELETE("", commentHandler.DeleteComment)
+			comments.POST("/replies", commentHandler.CreateReply)
+			comments.PUT("/vote", commentHandler.VoteComment)
+			comments.DELETE("/vote", commentHandler.VoteComment)
+			comments.PATCH("/accept", commentHandler.AcceptAnswer)
+			comments.PATCH("/pin", commentHandler.PinThread)
+		}
+
+		reviews := protectedAPI.Group("/reviews")
+		reviews.Use(sessionOnlyMiddleware.GetHandlerFunc(), adminMiddleware.GetHandlerFunc())
+		{
+			reviews.GET("", reviewHandler.GetReviews)
+			reviews.PATCH("/:id/hide", reviewHandler.HideReview)
+			reviews.PATCH("/:id/unhide", reviewHandler.UnhideReview)
+		}
+
+		payouts := protectedAPI.Group("/payouts/:instructorId")
+		payouts.Use(sessionOnlyMiddleware.GetHandlerFunc(), adminMiddleware.GetHandlerFunc())
+		{
+			payouts.GET("/statements", payoutHandler.GetStatementSummaries)
+			payouts.GET("/statements/:period", payoutHandler.GetStatement)
+			payouts.POST("/statements/:period/settle", payoutHandler.SettlePayouts)
+		}
+
+		auditLogs := protectedAPI.Group("/audit-logs")
+		auditLogs.Use(sessionOnlyMiddleware.GetHandlerFunc(), adminMiddleware.GetHandlerFunc())
+		{
+			auditLogs.GET("", auditHandler.GetAuditLogs)
 		}
 	}
 
@@ -181,6 +338,32 @@ func NewRouter(
 	return GinRouterWrapper{ginEngine: r}
 }
 
+// allowedOrigins are the origins other than the app itself that may call the API with cookies, from
+// the comma separated ALLOWED_ORIGINS, plus the origin of APP_BASE_URL.
+func allowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("ALLOWED_ORIGINS")+","+os.Getenv("APP_BASE_URL"), ",") {
+		u, err := url.Parse(strings.TrimSpace(origin))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			continue
+		}
+		origins = append(origins, u.Scheme+"://"+u.Host)
+	}
+	return origins
+}
+
+// trustedProxies are the addresses and CIDR ranges of the reverse proxies in front of the app, from the
+// comma separated TRUSTED_PROXIES. None are trusted by default.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func (g GinRouterWrapper) Start() {
 	port := os.Getenv("APP_PORT")
 	if port == "" {
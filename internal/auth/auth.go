@@ -3,7 +3,10 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 	"unicode"
 
@@ -15,18 +18,85 @@ type JWTClaims struct {
 	ID       uint   `json:"id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
+	Role     string `json:"role"`
+	// SessionVersion is the user's session version when the token was issued. Bumping the version
+	// revokes every token issued before.
+	SessionVersion int `json:"sv"`
 	jwt.RegisteredClaims
 }
 
-var jwtSecret []byte
+// ChallengeClaims identify the user of a login that still has to pass a second factor.
+type ChallengeClaims struct {
+	ID      uint   `json:"id"`
+	Purpose string `json:"purpose"`
+	// SessionVersion is the user's session version when the password was entered, so revoking the user's
+	// sessions also abandons their pending logins.
+	SessionVersion int `json:"sv"`
+	jwt.RegisteredClaims
+}
+
+// Challenge token purposes.
+const (
+	ChallengeMFAVerify = "mfa_verify" // The user has to enter a code from their authenticator
+	ChallengeMFASetup  = "mfa_setup"  // The user's role requires 2FA, which they have to set up first
+)
+
+// challengeTTL is how long a user has to pass the second factor after entering their password.
+const challengeTTL = 5 * time.Minute
+
+// OIDCStateClaims carry the state of a sign in with an external provider between the redirect to the
+// provider and the callback from it.
+type OIDCStateClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+	jwt.RegisteredClaims
+}
+
+// oidcStateTTL is how long a user has to sign in at the external provider.
+const oidcStateTTL = 10 * time.Minute
+
+// sessionTTL is how long a session token stays valid.
+const sessionTTL = time.Hour
 
-func init() {
-	secret := os.Getenv("JWT_SECRET_KEY")
-	if secret == "" {
-		secret = "default_supersecretjwtkey"
-		fmt.Println("WARNING: JWT_SECRET_KEY not set, using default. Set it in your .env file!")
+// keys signs and verifies session tokens. It is set by Init.
+var keys *KeySet
+
+// challengeSecret signs challenge tokens, so they are never accepted as session tokens.
+var challengeSecret []byte
+
+// oidcStateSecret signs the state of sign ins with external providers.
+var oidcStateSecret []byte
+
+// Init loads the keys that sign tokens, see LoadKeySetFromEnv. It must be called before issuing or validating tokens.
+func Init() error {
+	keySet, err := LoadKeySetFromEnv()
+	if err != nil {
+		return err
 	}
-	jwtSecret = []byte(secret)
+	SetKeySet(keySet)
+	log.Printf("Signing tokens with key %s", keySet.SigningKeyID())
+	return nil
+}
+
+// SetKeySet replaces the keys that sign and verify tokens.
+func SetKeySet(keySet *KeySet) {
+	keys = keySet
+	// Challenges and sign in states are only read by this service, so a secret derived from the
+	// signing key is enough for them
+	challengeSecret = keySet.derivedSecret("mfa-challenge")
+	oidcStateSecret = keySet.derivedSecret("oidc-state")
+}
+
+// Keys returns the keys that sign and verify session tokens, or nil before Init.
+func Keys() *KeySet {
+	return keys
+}
+
+// tokenIssuer is the iss claim of session tokens, so other services can tell Grocademy's tokens apart.
+func tokenIssuer() string {
+	return strings.TrimRight(os.Getenv("APP_BASE_URL"), "/")
 }
 
 func IsStrongPassword(password string) bool {
@@ -68,37 +138,49 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func GenerateJWT(id uint, username string, email string) (string, error) {
-	expirationTime := time.Now().Add(1 * time.Hour)
+// GenerateJWT issues a session token, signed with the current signing key of the key set.
+func GenerateJWT(id uint, username string, email string, role string, sessionVersion int) (string, error) {
+	if keys == nil {
+		return "", errors.New("token signing keys are not loaded")
+	}
 
+	now := time.Now()
 	claims := &JWTClaims{
 		ID:       id,
 		Username: username,
 		Email:    email,
+		Role:     role,
+
+		SessionVersion: sessionVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    tokenIssuer(),
+			Subject:   strconv.FormatUint(uint64(id), 10),
+			ExpiresAt: jwt.NewNumericDate(now.Add(sessionTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := keys.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 	return tokenString, nil
 }
 
+// ValidateJWT parses a session token signed by any key of the key set.
 func ValidateJWT(tokenString string) (*JWTClaims, error) {
+	if keys == nil {
+		return nil, errors.New("token signing keys are not loaded")
+	}
+
 	claims := &JWTClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
-	})
+	options := []jwt.ParserOption{}
+	if issuer := tokenIssuer(); issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.keyFunc, options...)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -110,9 +192,86 @@ func ValidateJWT(tokenString string) (*JWTClaims, error) {
 		return nil, fmt.Errorf("invalid token: %w", err)
 	}
 
-	if !token.Valid {
+	if !token.Valid || claims.IssuedAt == nil {
 		return nil, errors.New("invalid token")
 	}
 
 	return claims, nil
 }
+
+// GenerateChallengeJWT issues a short-lived token proving that the user entered their password.
+func GenerateChallengeJWT(id uint, sessionVersion int, purpose string) (string, error) {
+	now := time.Now()
+	claims := &ChallengeClaims{
+		ID:             id,
+		Purpose:        purpose,
+		SessionVersion: sessionVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(challengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(challengeSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ValidateChallengeJWT parses a challenge token issued by GenerateChallengeJWT.
+func ValidateChallengeJWT(tokenString string) (*ChallengeClaims, error) {
+	claims := &ChallengeClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return challengeSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired challenge token")
+	}
+	return claims, nil
+}
+
+// GenerateOIDCStateJWT signs the state of a sign in with an external provider, so it can be kept in a cookie.
+func GenerateOIDCStateJWT(provider, state, nonce, verifier string) (string, error) {
+	now := time.Now()
+	claims := &OIDCStateClaims{
+		Provider: provider,
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(oidcStateSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ValidateOIDCStateJWT parses a state token issued by GenerateOIDCStateJWT.
+func ValidateOIDCStateJWT(tokenString string) (*OIDCStateClaims, error) {
+	claims := &OIDCStateClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return oidcStateSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired sign in state")
+	}
+	return claims, nil
+}
@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for signing or verifying tokens.
+const minRSAKeyBits = 2048
+
+// verificationKey is a public key that session tokens may be signed with.
+type verificationKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	PublicKey crypto.PublicKey
+}
+
+// KeySet holds the private key that signs new session tokens and the public keys that verify them.
+// Keeping the previous signing key, or publishing the next one early, lets keys be rotated without
+// invalidating tokens that were issued or cached elsewhere.
+type KeySet struct {
+	signingKey crypto.Signer
+	signing    verificationKey
+	keys       []verificationKey
+}
+
+// JWK is a public key in the JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // Ed25519
+	X         string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+// JWKS is a JSON Web Key Set, as served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet creates a key set that signs with signingKey, an RSA or Ed25519 private key, and also accepts
+// tokens signed by the keys in verificationKeys.
+func NewKeySet(signingKey crypto.Signer, verificationKeys ...crypto.PublicKey) (*KeySet, error) {
+	signing, err := newVerificationKey(signingKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	set := &KeySet{signingKey: signingKey, signing: signing, keys: []verificationKey{signing}}
+	for _, publicKey := range verificationKeys {
+		key, err := newVerificationKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		if _, found := set.key(key.ID); !found {
+			set.keys = append(set.keys, key)
+		}
+	}
+	return set, nil
+}
+
+// LoadKeySetFromEnv reads the PEM encoded signing key from JWT_SIGNING_KEY, or the file at
+// JWT_SIGNING_KEY_FILE, and any extra public keys to accept from JWT_VERIFICATION_KEYS or the file at
+// JWT_VERIFICATION_KEYS_FILE. Without a signing key it generates a temporary one, unless APP_ENV is production.
+func LoadKeySetFromEnv() (*KeySet, error) {
+	signingPEM, err := readEnvPEM("JWT_SIGNING_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	var signingKey crypto.Signer
+	if len(signingPEM) == 0 {
+		if IsProduction() {
+			return nil, errors.New("JWT_SIGNING_KEY or JWT_SIGNING_KEY_FILE must be set when APP_ENV is production")
+		}
+		_, signingKey, err = ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		log.Println("WARNING: JWT_SIGNING_KEY not set, signing tokens with a temporary key. Sessions end when the server restarts!")
+	} else {
+		signingKey, err = parsePrivateKeyPEM(signingPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT signing key: %w", err)
+		}
+	}
+
+	verificationPEM, err := readEnvPEM("JWT_VERIFICATION_KEYS")
+	if err != nil {
+		return nil, err
+	}
+	verificationKeys, err := parsePublicKeysPEM(verificationPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT verification key: %w", err)
+	}
+
+	return NewKeySet(signingKey, verificationKeys...)
+}
+
+// IsProduction reports whether APP_ENV is production, where insecure development defaults are refused.
+func IsProduction() bool {
+	return strings.EqualFold(os.Getenv("APP_ENV"), "production")
+}
+
+// SigningKeyID is the kid of the key that signs new tokens.
+func (k *KeySet) SigningKeyID() string {
+	return k.signing.ID
+}
+
+// JWKS returns the public keys of the set, the signing key first.
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		jwks.Keys = append(jwks.Keys, key.jwk())
+	}
+	return jwks
+}
+
+// sign signs the claims with the signing key, naming it in the kid header.
+func (k *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signingKey)
+}
+
+// keyFunc finds the key a token was signed with by its kid header.
+func (k *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, found := k.key(kid)
+	if !found {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.PublicKey, nil
+}
+
+func (k *KeySet) key(kid string) (verificationKey, bool) {
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key, true
+		}
+	}
+	return verificationKey{}, false
+}
+
+// derivedSecret derives an HMAC secret for tokens that only this service reads from the signing key.
+func (k *KeySet) derivedSecret(purpose string) []byte {
+	der, _ := x509.MarshalPKCS8PrivateKey(k.signingKey)
+	sum := sha256.Sum256(append([]byte(purpose+":"), der...))
+	return sum[:]
+}
+
+// newVerificationKey picks the signing method of a public key, and names it by its RFC 7638 thumbprint so
+// the kid stays the same wherever the key is loaded.
+func newVerificationKey(publicKey crypto.PublicKey) (verificationKey, error) {
+	key := verificationKey{PublicKey: publicKey}
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < minRSAKeyBits {
+			return key, fmt.Errorf("RSA keys must have at least %d bits", minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return key, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", publicKey)
+	}
+
+	jwk := key.jwk()
+	var members map[string]string
+	if jwk.KeyType == "RSA" {
+		members = map[string]string{"e": jwk.E, "kty": jwk.KeyType, "n": jwk.N}
+	} else {
+		members = map[string]string{"crv": jwk.Curve, "kty": jwk.KeyType, "x": jwk.X}
+	}
+	// encoding/json sorts map keys, as the thumbprint requires
+	thumbprintInput, _ := json.Marshal(members)
+	sum := sha256.Sum256(thumbprintInput)
+	key.ID = base64.RawURLEncoding.EncodeToString(sum[:])
+	return key, nil
+}
+
+func (k verificationKey) jwk() JWK {
+	encode := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Use: "sig", Algorithm: k.Method.Alg(), KeyID: k.ID}
+	switch publicKey := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encode(publicKey.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encode(publicKey)
+	}
+	return jwk
+}
+
+// readEnvPEM reads PEM data from the named variable, or from the file named by the variable with a _FILE suffix.
+func readEnvPEM(name string) ([]byte, error) {
+	if value := os.Getenv(name); value != "" {
+		// Allow keys on one line, with escaped newlines
+		return []byte(strings.ReplaceAll(value, `\n`, "\n")), nil
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+	}
+	return data, nil
+}
+
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q, expected a private key", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", key)
+	}
+}
+
+// parsePublicKeysPEM reads every public key in the PEM data. Private keys are accepted too, only their
+// public halves are kept.
+func parsePublicKeysPEM(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return keys, nil
+		}
+
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		case "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		case "PRIVATE KEY", "RSA PRIVATE KEY":
+			key, err := parsePrivateKeyPEM(pem.EncodeToMemory(block))
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key.Public())
+		default:
+			return nil, fmt.Errorf("unexpected PEM block %q, expected a public key", block.Type)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useKeySet signs and verifies tokens with keySet until the test ends.
+func useKeySet(t *testing.T, keySet *KeySet) {
+	t.Helper()
+
+	previousKeys, previousChallenge, previousState := keys, challengeSecret, oidcStateSecret
+	t.Cleanup(func() {
+		keys, challengeSecret, oidcStateSecret = previousKeys, previousChallenge, previousState
+	})
+	SetKeySet(keySet)
+}
+
+func newTestKeySet(t *testing.T, verificationKeys ...ed25519.PublicKey) (*KeySet, ed25519.PrivateKey) {
+	t.Helper()
+
+	_, signingKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeys := make([]crypto.PublicKey, 0, len(verificationKeys))
+	for _, key := range verificationKeys {
+		publicKeys = append(publicKeys, key)
+	}
+	keySet, err := NewKeySet(signingKey, publicKeys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keySet, signingKey
+}
+
+func TestKeyIDIsThumbprint(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name      string
+		publicKey crypto.PublicKey
+		want      string
+	}{
+		{
+			// RFC 7638, section 3.1
+			name: "RSA",
+			publicKey: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(decode("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")),
+				E: 65537,
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037, appendix A.3
+			name:      "Ed25519",
+			publicKey: ed25519.PublicKey(decode("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")),
+			want:      "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := newVerificationKey(tt.publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.ID != tt.want {
+				t.Errorf("kid = %q, want %q", key.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJWTAcceptsRotatedKey(t *testing.T) {
+	oldKeys, oldKey := newTestKeySet(t)
+	useKeySet(t, oldKeys)
+	token, err := GenerateJWT(1, "ada", "ada@example.com", "student", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		verificationKeys []ed25519.PublicKey
+		wantErr          bool
+	}{
+		{name: "new key, still accepting the old one", verificationKeys: []ed25519.PublicKey{oldKey.Public().(ed25519.PublicKey)}},
+		{name: "new key only", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newKeys, _ := newTestKeySet(t, tt.verificationKeys...)
+			useKeySet(t, newKeys)
+			claims, err := ValidateJWT(token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateJWT error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && claims.ID != 1 {
+				t.Errorf("ValidateJWT = user %d, want 1", claims.ID)
+			}
+		})
+	}
+}
+
+func TestValidateJWTRejectsWrongKey(t *testing.T) {
+	keySet, _ := newTestKeySet(t)
+	useKeySet(t, keySet)
+	otherKeys, _ := newTestKeySet(t)
+
+	now := time.Now()
+	claims := &JWTClaims{ID: 1, RegisteredClaims: jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}}
+	signed := func(method jwt.SigningMethod, kid string, key any) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		tokenString, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokenString
+	}
+	signedBy := func(keySet *KeySet) string {
+		tokenString, err := keySet.sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokenString
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"unknown kid", signedBy(otherKeys)},
+		{"missing kid", signed(jwt.SigningMethodEdDSA, "", otherKeys.signingKey)},
+		{"kid of another key", signed(jwt.SigningMethodEdDSA, keySet.SigningKeyID(), otherKeys.signingKey)},
+		// HMAC keyed with the public key, as if it were a shared secret
+		{"HS256 with the public key", signed(jwt.SigningMethodHS256, keySet.SigningKeyID(), []byte(keySet.signing.PublicKey.(ed25519.PublicKey)))},
+		{"alg none", signed(jwt.SigningMethodNone, keySet.SigningKeyID(), jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateJWT(tt.token); err == nil {
+				t.Error("ValidateJWT accepted the token")
+			}
+		})
+	}
+
+	if _, err := ValidateJWT(signedBy(keySet)); err != nil {
+		t.Errorf("ValidateJWT of a token signed by the key set error = %v", err)
+	}
+}
+
+func TestLoadKeySetFromEnv(t *testing.T) {
+	_, signingKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	want, err := NewKeySet(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		appEnv     string
+		signingKey string
+		wantErr    bool
+	}{
+		{name: "development without a key", appEnv: "development"},
+		{name: "production without a key", appEnv: "production", wantErr: true},
+		{name: "production spelled differently without a key", appEnv: "Production", wantErr: true},
+		{name: "production with a key", appEnv: "production", signingKey: signingPEM},
+		{name: "key on one line", appEnv: "production", signingKey: strings.ReplaceAll(signingPEM, "\n", `\n`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.appEnv)
+			t.Setenv("JWT_SIGNING_KEY", tt.signingKey)
+			t.Setenv("JWT_SIGNING_KEY_FILE", "")
+			t.Setenv("JWT_VERIFICATION_KEYS", "")
+			t.Setenv("JWT_VERIFICATION_KEYS_FILE", "")
+
+			keySet, err := LoadKeySetFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadKeySetFromEnv error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.signingKey != "" && keySet.SigningKeyID() != want.SigningKeyID() {
+				t.Errorf("signing key = %q, want %q", keySet.SigningKeyID(), want.SigningKeyID())
+			}
+		})
+	}
+}
@@ -16,9 +16,9 @@ import (
 
 var DB *gorm.DB
 
-func Init() {
-
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Jakarta",
+// DSN returns the Postgres connection string configured by the DB_* environment variables.
+func DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Jakarta",
 		os.Getenv("DB_HOST"),
 		os.Getenv("DB_USER"),
 		os.Getenv("DB_PASSWORD"),
@@ -26,6 +26,10 @@ func Init() {
 		os.Getenv("DB_PORT"),
 		os.Getenv("DB_SSLMODE"),
 	)
+}
+
+func Init() {
+	dsn := DSN()
 
 	newLogger := logger.New(
 		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
@@ -52,11 +56,37 @@ func Init() {
 		&models.Module{},
 		&models.Enrollment{},
 		&models.ModuleProgress{},
+		&models.ModulePrerequisite{},
+		&models.CoursePrerequisite{},
+		&models.ModuleReleaseNotice{},
+		&models.Revision{},
+		&models.CourseInstructor{},
+		&models.PayoutEntry{},
+		&models.Review{},
+		&models.Comment{},
+		&models.CommentVote{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.OutboxEmail{},
+		&models.UserToken{},
+		&models.RecoveryCode{},
+		&models.ExternalIdentity{},
+		&models.PersonalAccessToken{},
+		&models.LoginThrottle{},
+		&models.AuditLog{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to auto migrate database: %v", err)
 	}
 
+	// An association on PayoutEntry once made GORM add the payout foreign key to enrollments as well as
+	// payout_entries (see migration 000012), so no enrollment could be created before its payout entry
+	if DB.Migrator().HasConstraint(&models.Enrollment{}, "fk_payout_entries_enrollment") {
+		if err := DB.Migrator().DropConstraint(&models.Enrollment{}, "fk_payout_entries_enrollment"); err != nil {
+			log.Fatalf("Failed to drop misplaced payout constraint: %v", err)
+		}
+	}
+
 	log.Println("Database connection established and migrations applied (if any).")
 
 	createDefaultAdmin(DB)
@@ -90,7 +120,10 @@ func createDefaultAdmin(db *gorm.DB) {
 			FirstName: adminFirstName,
 			LastName:  adminLastName,
 			Balance:   adminBalance,
+			Role:      models.UserRoleAdmin,
 		}
+		verifiedAt := time.Now()
+		newAdmin.EmailVerifiedAt = &verifiedAt
 
 		if createResult := db.Create(&newAdmin); createResult.Error != nil {
 			log.Fatalf("Failed to create default admin user: %v", createResult.Error)
@@ -0,0 +1,39 @@
+// Package dbtest provides databases for tests of code that queries through GORM.
+package dbtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open creates an in-memory SQLite database with tables for the given models, closed when the test ends.
+// Queries that only PostgreSQL understands cannot be tested with it.
+func Open(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	// Every connection of a shared cache with the same name sees the same database
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared&_pragma=foreign_keys(1)", name)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	// SQLite allows one writer at a time, so transactions must not wait on each other's connections
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return db
+}
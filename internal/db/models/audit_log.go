@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"grocademy/internal/pkg/json_map"
+)
+
+// Audit log target types
+const (
+	AuditTargetUser   = "user"
+	AuditTargetCourse = "course"
+	AuditTargetModule = "module"
+	AuditTargetReview = "review"
+	AuditTargetPayout = "payout"
+)
+
+// Audit log actions, named <target>.<verb>
+const (
+	AuditUserCreate             = "user.create"
+	AuditUserUpdate             = "user.update"
+	AuditUserDelete             = "user.delete"
+	AuditUserBalance            = "user.balance"
+	AuditUserVerifyEmail        = "user.verify_email"
+	AuditUserUnlock             = "user.unlock"
+	AuditCourseCreate           = "course.create"
+	AuditCourseUpdate           = "course.update"
+	AuditCourseDelete           = "course.delete"
+	AuditCourseBuy              = "course.buy"
+	AuditCoursePrerequisites    = "course.prerequisites"
+	AuditCourseStatus           = "course.status"
+	AuditCourseRestore          = "course.restore"
+	AuditCourseInstructorAdd    = "course.instructor_add"
+	AuditCourseInstructorRemove = "course.instructor_remove"
+	AuditModuleCreate           = "module.create"
+	AuditModuleUpdate           = "module.update"
+	AuditModuleDelete           = "module.delete"
+	AuditModuleReorder          = "module.reorder"
+	AuditModulePrerequisites    = "module.prerequisites"
+	AuditModuleRestore          = "module.restore"
+	AuditReviewHide             = "review.hide"
+	AuditReviewUnhide           = "review.unhide"
+	AuditPayoutSettle           = "payout.settle"
+)
+
+// AuditLog records who changed what through an administrative or financial action. Entries are never
+// updated, and outlive the users and records they mention.
+type AuditLog struct {
+	ID            uint             `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time        `json:"created_at" gorm:"index"`
+	ActorID       *uint            `json:"actor_id" gorm:"index"`
+	ActorUsername string           `json:"actor_username" gorm:"type:varchar(255);not null;default:''"`
+	ActorRole     string           `json:"actor_role" gorm:"type:varchar(20);not null;default:''"`
+	Action        string           `json:"action" gorm:"type:varchar(64);not null;index"`
+	TargetType    string           `json:"target_type" gorm:"type:varchar(20);not null;index:idx_audit_logs_target"`
+	TargetID      string           `json:"target_id" gorm:"type:varchar(64);not null;index:idx_audit_logs_target"`
+	Before        json_map.JSONMap `json:"before" gorm:"type:jsonb"` // State of the target before the action, if it existed
+	After         json_map.JSONMap `json:"after" gorm:"type:jsonb"`  // State of the target or the change made, if it still exists
+	IPAddress     string           `json:"ip_address" gorm:"type:varchar(45);not null;default:''"`
+	RequestID     string           `json:"request_id" gorm:"type:varchar(64);not null;default:'';index"`
+}
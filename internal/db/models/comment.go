@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment kinds. Threads are questions or discussions, replies to a question are answers,
+// and every other reply is a plain reply.
+const (
+	CommentKindQuestion   = "question"
+	CommentKindDiscussion = "discussion"
+	CommentKindAnswer     = "answer"
+	CommentKindReply      = "reply"
+)
+
+// Comment is a post in a module's discussion, either a thread or a reply within one.
+type Comment struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggerignore:"true"`
+	ModuleID         uint           `json:"module_id" gorm:"not null;index"`
+	Module           Module         `json:"-"` // GORM association
+	UserID           uint           `json:"user_id" gorm:"not null;index"`
+	User             User           `json:"-"`                      // GORM association
+	ThreadID         *uint          `json:"thread_id" gorm:"index"` // Thread the reply belongs to, nil for threads
+	ParentID         *uint          `json:"parent_id"`              // Comment replied to, nil for threads
+	Kind             string         `json:"kind" gorm:"type:varchar(20);not null"`
+	Body             string         `json:"body" gorm:"type:text;not null"`
+	Score            int64          `json:"score" gorm:"not null;default:0"` // Number of upvotes
+	AcceptedAnswerID *uint          `json:"accepted_answer_id"`              // Questions only
+	PinnedAt         *time.Time     `json:"pinned_at"`                       // Threads only, pinned threads are listed first
+}
+
+// CommentVote is a user's upvote of a comment.
+type CommentVote struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	CommentID uint      `json:"comment_id" gorm:"not null;uniqueIndex:uq_comment_vote"`
+	Comment   Comment   `json:"-"` // GORM association
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:uq_comment_vote"`
+	User      User      `json:"-"` // GORM association
+}
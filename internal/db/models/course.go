@@ -5,18 +5,35 @@ import (
 
 	"grocademy/internal/pkg/string_array"
 
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
+// Course publication statuses. Only published courses appear in the public catalog and can be bought.
+const (
+	CourseStatusDraft     = "draft"
+	CourseStatusInReview  = "in_review"
+	CourseStatusPublished = "published"
+	CourseStatusArchived  = "archived" // Still open to existing enrollees, no longer for sale
+)
+
 type Course struct {
-	ID             uint                     `gorm:"primaryKey" json:"id" faker:"-"`
-	CreatedAt      time.Time                `json:"created_at" faker:"-"`
-	UpdatedAt      time.Time                `json:"updated_at" faker:"-"`
-	DeletedAt      gorm.DeletedAt           `gorm:"index" json:"deleted_at,omitempty" swaggerignore:"true" faker:"-"`
-	Title          string                   `json:"title" gorm:"unique;not null" faker:"sentence"`
-	Description    string                   `json:"description" gorm:"type:text" faker:"paragraph"`
-	Instructor     string                   `json:"instructor" faker:"name"`
-	Topics         string_array.StringArray `json:"topics" gorm:"type:text[]" faker:"topics"`
-	Price          float64                  `json:"price" faker:"amount"`
-	ThumbnailImage string                   `json:"thumbnail_image" faker:"thumbnail"`
+	ID                uint                     `gorm:"primaryKey" json:"id" faker:"-"`
+	CreatedAt         time.Time                `json:"created_at" faker:"-"`
+	UpdatedAt         time.Time                `json:"updated_at" faker:"-"`
+	DeletedAt         gorm.DeletedAt           `gorm:"index" json:"deleted_at,omitempty" swaggerignore:"true" faker:"-"`
+	Title             string                   `json:"title" gorm:"unique;not null" faker:"sentence"`
+	Description       string                   `json:"description" gorm:"type:text" faker:"paragraph"`
+	Instructor        string                   `json:"instructor" faker:"name"`
+	Topics            string_array.StringArray `json:"topics" gorm:"type:text[]" faker:"topics"`
+	Price             float64                  `json:"price" faker:"amount"`
+	ThumbnailImage    string                   `json:"thumbnail_image" faker:"thumbnail"`
+	SequentialModules bool                     `json:"sequential_modules" gorm:"not null;default:false" faker:"-"` // Module N unlocks only after module N-1 is completed
+	Status            string                   `json:"status" gorm:"type:varchar(20);not null;default:draft;index" faker:"course_status"`
+	PublishAt         *time.Time               `json:"publish_at" faker:"-"`    // Scheduled publication, cleared on any status change
+	PublishedAt       *time.Time               `json:"published_at" faker:"-"`  // When the course last went live
+	RevenueShare      *float64                 `json:"revenue_share" faker:"-"` // Instructor's percentage of each sale, overrides the instructor's own share
+	RatingAverage     float64                  `json:"rating_average" gorm:"not null;default:0" faker:"-"`
+	RatingCount       int64                    `json:"rating_count" gorm:"not null;default:0" faker:"-"`
+	RatingHistogram   pq.Int64Array            `json:"rating_histogram" gorm:"type:bigint[];not null;default:'{0,0,0,0,0}'" faker:"-"` // Number of visible reviews per star, 1 to 5
 }
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Course instructor roles. A course has one owner, who can add and remove co-instructors.
+const (
+	CourseInstructorOwner        = "owner"
+	CourseInstructorCoInstructor = "co_instructor"
+)
+
+// CourseInstructor links an instructor account to a course they can manage.
+type CourseInstructor struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	CourseID  uint      `json:"course_id" gorm:"not null;uniqueIndex:uq_course_instructor"`
+	Course    Course    `json:"-"` // GORM association
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:uq_course_instructor;index"`
+	User      User      `json:"-"` // GORM association
+	Role      string    `json:"role" gorm:"type:varchar(20);not null"`
+}
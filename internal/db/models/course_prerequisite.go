@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// CoursePrerequisite marks PrerequisiteID as a course that must be finished before CourseID can be bought.
+type CoursePrerequisite struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	CourseID       uint      `json:"course_id" gorm:"not null;uniqueIndex:uq_course_prerequisite"`
+	Course         Course    `json:"-"` // GORM association
+	PrerequisiteID uint      `json:"prerequisite_id" gorm:"not null;uniqueIndex:uq_course_prerequisite"`
+	Prerequisite   Course    `json:"-"` // GORM association
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// ExternalIdentity links a user to an account at an OpenID Connect or OAuth2 provider they sign in with.
+type ExternalIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	User        User       `json:"-"` // GORM association
+	Provider    string     `json:"provider" gorm:"type:varchar(50);not null;uniqueIndex:idx_external_identities_provider_subject"`
+	Subject     string     `json:"-" gorm:"not null;uniqueIndex:idx_external_identities_provider_subject"` // The user's ID at the provider
+	Email       string     `json:"email" gorm:"not null"`                                                  // Email address the provider reported at the last sign in
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
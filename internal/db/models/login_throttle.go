@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginThrottle counts the recent failed logins with a login identifier or from a client IP.
+type LoginThrottle struct {
+	Key            string     `gorm:"column:throttle_key;primaryKey;type:varchar(320)" json:"key"` // login:<identifier> or ip:<address>
+	FailedAttempts int        `json:"failed_attempts" gorm:"not null;default:0"`
+	LastFailedAt   time.Time  `json:"last_failed_at" gorm:"not null;index"`
+	LockedUntil    *time.Time `json:"locked_until"`
+}
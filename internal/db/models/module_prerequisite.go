@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// ModulePrerequisite marks PrerequisiteID as a module that must be completed before ModuleID unlocks.
+type ModulePrerequisite struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ModuleID       uint      `json:"module_id" gorm:"not null;uniqueIndex:uq_module_prerequisite"`
+	Module         Module    `json:"-"` // GORM association
+	PrerequisiteID uint      `json:"prerequisite_id" gorm:"not null;uniqueIndex:uq_module_prerequisite"`
+	Prerequisite   Module    `json:"-"` // GORM association
+}
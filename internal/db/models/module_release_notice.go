@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// ModuleReleaseNotice records that a user has been told a drip-scheduled module is now available to them.
+type ModuleReleaseNotice struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:uq_user_module_release"`
+	User      User      `json:"-"` // GORM association
+	ModuleID  uint      `json:"module_id" gorm:"not null;uniqueIndex:uq_user_module_release"`
+	Module    Module    `json:"-"` // GORM association
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification kinds, also the keys of notification preferences.
+const (
+	NotificationCoursePurchased = "course.purchased"
+	NotificationBalanceTopUp    = "balance.topped_up"
+	NotificationModuleAdded     = "module.added"
+	NotificationModuleReleased  = "module.released"
+	NotificationCourseCompleted = "course.completed"
+	NotificationCommentMention  = "comment.mention"
+	NotificationAnswerAccepted  = "comment.accepted"
+)
+
+// NotificationKinds lists every notification kind users can turn off.
+var NotificationKinds = []string{
+	NotificationCoursePurchased,
+	NotificationBalanceTopUp,
+	NotificationModuleAdded,
+	NotificationModuleReleased,
+	NotificationCourseCompleted,
+	NotificationCommentMention,
+	NotificationAnswerAccepted,
+}
+
+// Notification is a message in a user's notification center.
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"not null;index:idx_notifications_user_read"`
+	User      User       `json:"-"` // GORM association
+	Kind      string     `json:"kind" gorm:"type:varchar(50);not null"`
+	Title     string     `json:"title" gorm:"not null"`
+	Body      string     `json:"body" gorm:"type:text"`
+	ReadAt    *time.Time `json:"read_at" gorm:"index:idx_notifications_user_read"`
+}
+
+// NotificationPreference turns a kind of notification on or off for a user. Kinds without a preference are on.
+type NotificationPreference struct {
+	ID      uint   `gorm:"primaryKey" json:"id"`
+	UserID  uint   `json:"user_id" gorm:"not null;uniqueIndex:uq_notification_preference"`
+	User    User   `json:"-"` // GORM association
+	Kind    string `json:"kind" gorm:"type:varchar(50);not null;uniqueIndex:uq_notification_preference"`
+	Enabled bool   `json:"enabled" gorm:"not null"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEmail is a rendered email waiting to be delivered by the email outbox job.
+type OutboxEmail struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Recipient     string     `json:"recipient" gorm:"not null"`
+	Template      string     `json:"template" gorm:"type:varchar(50);not null"`
+	Subject       string     `json:"subject" gorm:"not null"`
+	TextBody      string     `json:"text_body" gorm:"type:text"`
+	HTMLBody      string     `json:"html_body" gorm:"type:text"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;index:idx_outbox_emails_due,where:sent_at IS NULL AND failed_at IS NULL"`
+	SentAt        *time.Time `json:"sent_at"`
+	FailedAt      *time.Time `json:"failed_at"` // Set once delivery is given up after too many attempts
+	LastError     string     `json:"last_error" gorm:"type:text"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// PayoutEntry is an instructor's earning from a single course sale. Entries are settled in bulk per instructor and month.
+type PayoutEntry struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	InstructorID    uint       `json:"instructor_id" gorm:"not null;index:idx_payout_instructor_period"`
+	Instructor      User       `json:"-"` // GORM association
+	CourseID        uint       `json:"course_id" gorm:"not null;index"`
+	Course          Course     `json:"-"` // GORM association
+	TransactionID   uint       `json:"transaction_id" gorm:"not null;uniqueIndex"`
+	Period          string     `json:"period" gorm:"type:char(7);not null;index:idx_payout_instructor_period"` // Month of the sale, YYYY-MM
+	SaleAmount      float64    `json:"sale_amount" gorm:"not null"`
+	SharePercentage float64    `json:"share_percentage" gorm:"not null"`
+	Amount          float64    `json:"amount" gorm:"not null"`
+	SettledAt       *time.Time `json:"settled_at"`
+	SettlementRef   string     `json:"settlement_ref"` // Bank transfer or other reference given when settling
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"grocademy/internal/pkg/string_array"
+)
+
+// Personal access token scopes. A read scope allows GET requests, a write scope every request on the resource.
+const (
+	ScopeCoursesRead  = "courses:read"
+	ScopeCoursesWrite = "courses:write"
+	ScopeUsersRead    = "users:read"
+	ScopeUsersWrite   = "users:write"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so they can be told apart from session tokens.
+const PersonalAccessTokenPrefix = "gat_"
+
+// PersonalAccessToken lets scripts call the API on behalf of a user, limited to the token's scopes.
+// Only the SHA-256 hash of the token is stored.
+type PersonalAccessToken struct {
+	ID         uint                     `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time                `json:"created_at"`
+	UserID     uint                     `json:"user_id" gorm:"not null;index"`
+	User       User                     `json:"-"` // GORM association
+	Name       string                   `json:"name" gorm:"type:varchar(100);not null"`
+	TokenHash  string                   `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	Hint       string                   `json:"hint" gorm:"type:varchar(20);not null"` // Start of the token, to recognize it
+	Scopes     string_array.StringArray `json:"scopes" gorm:"type:text[];not null"`
+	ExpiresAt  *time.Time               `json:"expires_at"` // Tokens without an expiry stay valid until revoked
+	LastUsedAt *time.Time               `json:"last_used_at"`
+	RevokedAt  *time.Time               `json:"-"`
+}
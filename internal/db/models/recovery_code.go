@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a single-use code that replaces an authenticator code when signing in with 2FA.
+// Only the bcrypt hash of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      User       `json:"-"` // GORM association
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Review is a student's rating of a course they are enrolled in. Hidden reviews are left out of the
+// course's rating and of its public review list.
+type Review struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggerignore:"true"`
+	CourseID     uint           `json:"course_id" gorm:"not null;uniqueIndex:uq_review_course_user,where:deleted_at IS NULL"`
+	Course       Course         `json:"-"` // GORM association
+	UserID       uint           `json:"user_id" gorm:"not null;uniqueIndex:uq_review_course_user,where:deleted_at IS NULL;index"`
+	User         User           `json:"-"` // GORM association
+	Rating       int            `json:"rating" gorm:"not null;check:chk_reviews_rating,rating BETWEEN 1 AND 5"`
+	Body         string         `json:"body" gorm:"type:text"`
+	HiddenAt     *time.Time     `json:"hidden_at"`
+	HiddenByID   *uint          `json:"hidden_by_id"`  // Admin who hid the review
+	HiddenReason string         `json:"hidden_reason"` // Shown to the review's author
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"grocademy/internal/pkg/json_map"
+	"grocademy/internal/pkg/string_array"
+)
+
+// Entities with revision history
+const (
+	RevisionEntityCourse = "course"
+	RevisionEntityModule = "module"
+)
+
+// Revision actions
+const (
+	RevisionActionCreate  = "create"
+	RevisionActionUpdate  = "update"
+	RevisionActionRestore = "restore"
+)
+
+// Revision is an immutable snapshot of a course or module taken after every change.
+type Revision struct {
+	ID         uint                     `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time                `json:"created_at"`
+	EntityType string                   `json:"entity_type" gorm:"type:varchar(20);not null;uniqueIndex:uq_entity_revision"`
+	EntityID   uint                     `json:"entity_id" gorm:"not null;uniqueIndex:uq_entity_revision"`
+	Version    int                      `json:"version" gorm:"not null;uniqueIndex:uq_entity_revision"`
+	Action     string                   `json:"action" gorm:"type:varchar(20);not null"`
+	ActorID    uint                     `json:"actor_id" gorm:"not null"`
+	Actor      User                     `json:"-"`                                   // GORM association
+	Snapshot   json_map.JSONMap         `json:"snapshot" gorm:"type:jsonb;not null"` // State of the entity after the change
+	Diff       json_map.JSONMap         `json:"diff" gorm:"type:jsonb;not null"`     // Changed fields as {"field": {"from": ..., "to": ...}}
+	Assets     string_array.StringArray `json:"assets" gorm:"type:text[]"`           // Storage objects the snapshot points to, kept for restores
+}
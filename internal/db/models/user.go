@@ -6,15 +6,32 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles. Instructors can manage the courses they are linked to through CourseInstructor.
+const (
+	UserRoleStudent    = "student"
+	UserRoleInstructor = "instructor"
+	UserRoleAdmin      = "admin"
+)
+
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id" faker:"-"`
-	CreatedAt time.Time      `json:"created_at" faker:"-"`
-	UpdatedAt time.Time      `json:"updated_at" faker:"-"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggerignore:"true" faker:"-"`
-	Username  string         `json:"username" gorm:"unique;not null" faker:"username"`
-	Email     string         `json:"email" gorm:"unique;not null"  faker:"email"`
-	Password  string         `json:"-" gorm:"not null" faker:"password"`
-	FirstName string         `json:"first_name" gorm:"not null"  faker:"first_name"`
-	LastName  string         `json:"last_name" gorm:"not null" faker:"last_name"`
-	Balance   float64        `json:"balance" gorm:"not null" faker:"amount"`
+	ID              uint           `gorm:"primaryKey" json:"id" faker:"-"`
+	CreatedAt       time.Time      `json:"created_at" faker:"-"`
+	UpdatedAt       time.Time      `json:"updated_at" faker:"-"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty" swaggerignore:"true" faker:"-"`
+	Username        string         `json:"username" gorm:"unique;not null" faker:"username"`
+	Email           string         `json:"email" gorm:"unique;not null"  faker:"email"`
+	Password        string         `json:"-" gorm:"not null" faker:"password"`
+	FirstName       string         `json:"first_name" gorm:"not null"  faker:"first_name"`
+	LastName        string         `json:"last_name" gorm:"not null" faker:"last_name"`
+	Balance         float64        `json:"balance" gorm:"not null" faker:"amount"`
+	Role            string         `json:"role" gorm:"type:varchar(20);not null;default:student;index" faker:"-"`
+	RevenueShare    *float64       `json:"revenue_share" faker:"-"`                                     // Percentage of each sale paid to this instructor, unless the course sets its own
+	Locale          string         `json:"locale" gorm:"type:varchar(5);not null;default:id" faker:"-"` // Language of the emails sent to this user
+	SessionVersion  int            `json:"-" gorm:"not null;default:0" faker:"-"`                       // Session tokens carrying another version are rejected
+	EmailVerifiedAt *time.Time     `json:"email_verified_at" faker:"-"`                                 // Unverified users cannot buy courses
+	PendingEmail    string         `json:"pending_email" gorm:"not null;default:''" faker:"-"`          // New email address waiting to be confirmed, Email stays in use until then
+	AvatarURL       string         `json:"avatar_url" gorm:"not null;default:''" faker:"-"`
+	TOTPSecret      string         `json:"-" gorm:"column:totp_secret;not null;default:''" faker:"-"` // Base32 authenticator secret, pending until TOTPEnabledAt is set
+	TOTPEnabledAt   *time.Time     `json:"totp_enabled_at" gorm:"column:totp_enabled_at" faker:"-"`
+	TOTPLastStep    int64          `json:"-" gorm:"column:totp_last_step;not null;default:0" faker:"-"` // Time step of the last accepted code, so codes cannot be replayed
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// User token purposes.
+const (
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenEmailVerification = "email_verification"
+	UserTokenEmailChange       = "email_change"
+)
+
+// UserToken is a single-use token emailed to a user. Only the SHA-256 hash of the token is stored.
+type UserToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      User       `json:"-"` // GORM association
+	Purpose   string     `json:"purpose" gorm:"type:varchar(30);not null"`
+	TokenHash string     `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+}
@@ -0,0 +1,130 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultFrom = "Grocademy <no-reply@grocademy.local>"
+
+// Message is a rendered email ready to be handed to a Sender.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers emails.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSenderFromEnv creates the Sender selected by EMAIL_MODE: "smtp" delivers through SMTP_HOST,
+// "file" writes every email to EMAIL_DIR for development. Without EMAIL_MODE, SMTP is used when
+// SMTP_HOST is set.
+func NewSenderFromEnv() Sender {
+	from := os.Getenv("EMAIL_FROM")
+	if from == "" {
+		from = defaultFrom
+	}
+
+	mode := os.Getenv("EMAIL_MODE")
+	if mode == "" {
+		mode = "file"
+		if os.Getenv("SMTP_HOST") != "" {
+			mode = "smtp"
+		}
+	}
+
+	switch mode {
+	case "smtp":
+		return NewSMTPSender(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			from,
+		)
+	case "file":
+	default:
+		log.Printf("WARNING: invalid EMAIL_MODE %q, writing emails to disk", mode)
+	}
+
+	dir := os.Getenv("EMAIL_DIR")
+	if dir == "" {
+		dir = "tmp/emails"
+	}
+	log.Printf("Emails are written to %s instead of being sent", dir)
+	return NewFileSender(dir, from)
+}
+
+// Bytes encodes the message as a multipart/alternative MIME document with the given sender.
+func (m Message) Bytes(from string) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", m.Text},
+		{"text/html; charset=UTF-8", m.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	headers := []struct{ key, value string }{
+		{"From", from},
+		{"To", m.To},
+		{"Subject", mime.QEncoding.Encode("UTF-8", m.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(from)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", parts.Boundary())},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", header.key, header.value)
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// messageID generates a unique Message-ID in the domain of the sender address.
+func messageID(from string) string {
+	domain := "grocademy.local"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.TrimRight(from[at+1:], ">")
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%s.%s@%s>", time.Now().Format("20060102150405"), hex.EncodeToString(random), domain)
+}
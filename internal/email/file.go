@@ -0,0 +1,38 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// FileSender writes every email to a directory as an .eml file instead of sending it.
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+// NewFileSender creates a new FileSender.
+func NewFileSender(dir, from string) *FileSender {
+	return &FileSender{Dir: dir, From: from}
+}
+
+func (s *FileSender) Send(msg Message) error {
+	data, err := msg.Bytes(s.From)
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create email directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	if err := os.WriteFile(filepath.Join(s.Dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
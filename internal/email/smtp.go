@@ -0,0 +1,104 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SendTimeout bounds a whole SMTP delivery, from dialing to QUIT, so a hung server cannot stall the sender.
+const SendTimeout = 30 * time.Second
+
+// SMTPSender delivers emails to an SMTP server, upgrading to TLS when the server supports STARTTLS.
+type SMTPSender struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+// NewSMTPSender creates a new SMTPSender. Authentication is skipped when username is empty,
+// as with local mail catchers such as MailHog.
+func NewSMTPSender(host, port, username, password, from string) *SMTPSender {
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPSender{Addr: net.JoinHostPort(host, port), Auth: auth, From: from}
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	from, err := mail.ParseAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	data, err := msg.Bytes(s.From)
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+	if err := s.sendMail(from.Address, to.Address, data); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// sendMail does what smtp.SendMail does, within SendTimeout.
+func (s *SMTPSender) sendMail(from, to string, data []byte) error {
+	conn, err := net.DialTimeout("tcp", s.Addr, SendTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(SendTimeout)); err != nil {
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(s.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(s.Auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,67 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"slices"
+	texttemplate "text/template"
+)
+
+// Supported email locales.
+const (
+	LocaleIndonesian = "id"
+	LocaleEnglish    = "en"
+	DefaultLocale    = LocaleIndonesian
+)
+
+// Locales lists every locale emails are written in.
+var Locales = []string{LocaleIndonesian, LocaleEnglish}
+
+// Email templates. Every template has a <name>.txt file defining its "subject" and "text"
+// and a <name>.html file defining its "content" inside the locale's layout.
+const (
+	TemplateWelcome         = "welcome"
+	TemplateCoursePurchased = "course_purchased"
+	TemplateCourseCompleted = "course_completed"
+	TemplatePasswordReset   = "password_reset"
+	TemplateVerifyEmail     = "verify_email"
+	TemplateSuspiciousLogin = "suspicious_login"
+	TemplateConfirmEmail    = "confirm_email"
+	TemplateEmailChange     = "email_change"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Render renders a template in the given locale, falling back to DefaultLocale for unknown locales.
+// The returned message has no recipient yet.
+func Render(name, locale string, data map[string]interface{}) (Message, error) {
+	if !slices.Contains(Locales, locale) {
+		locale = DefaultLocale
+	}
+	dir := "templates/" + locale + "/"
+
+	text, err := texttemplate.ParseFS(templateFS, dir+name+".txt")
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to parse email template %s: %w", name, err)
+	}
+	html, err := htmltemplate.ParseFS(templateFS, dir+"layout.html", dir+name+".html")
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to parse email template %s: %w", name, err)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render email template %s: %w", name, err)
+	}
+	if err := text.ExecuteTemplate(&textBody, "text", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render email template %s: %w", name, err)
+	}
+	if err := html.ExecuteTemplate(&htmlBody, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render email template %s: %w", name, err)
+	}
+
+	return Message{Subject: subject.String(), Text: textBody.String(), HTML: htmlBody.String()}, nil
+}
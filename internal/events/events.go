@@ -0,0 +1,107 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	CourseCreated   = "course.created"
+	CourseUpdated   = "course.updated"
+	CourseDeleted   = "course.deleted"
+	CoursePurchased = "course.purchased"
+	ModuleAdded     = "module.added"
+	ProgressChanged = "progress.changed"
+
+	NotificationCreated = "notification.created"
+)
+
+// Event is something that happened to a course that connected clients may want to show.
+type Event struct {
+	Type     string                 `json:"type"`
+	CourseID uint                   `json:"course_id"`
+	ModuleID uint                   `json:"module_id,omitempty"`
+	UserID   uint                   `json:"-"` // Only this user receives the event when set
+	Public   bool                   `json:"-"` // Everyone receives the event, e.g. changes to the public catalog
+	Data     map[string]interface{} `json:"data,omitempty"`
+	Time     time.Time              `json:"time"`
+}
+
+// envelope carries the routing fields hidden from clients across replicas.
+type envelope struct {
+	Event
+	UserID uint `json:"user_id,omitempty"`
+	Public bool `json:"public,omitempty"`
+}
+
+// Publisher is what services publish events to.
+type Publisher interface {
+	Publish(event Event)
+}
+
+// Relay carries events to every replica of the application, including the publishing one.
+type Relay interface {
+	Send(event Event) error
+}
+
+// subscriberBuffer is how many events a slow subscriber may lag behind before events are dropped for it.
+const subscriberBuffer = 32
+
+// Bus fans events out to the subscribers of this process. With a relay, published events go through
+// the relay first so that subscribers on other replicas receive them too.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+	Relay       Relay
+}
+
+// NewBus creates a new Bus delivering events within the process only, until a relay is set.
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Publish sends the event to every subscriber. It never blocks on slow subscribers.
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if b.Relay != nil {
+		err := b.Relay.Send(event)
+		if err == nil {
+			return
+		}
+		log.Printf("failed to relay event %s, delivering locally only: %v", event.Type, err)
+	}
+	b.Deliver(event)
+}
+
+// Deliver sends the event to the subscribers of this process only. Relays call it for received events.
+func (b *Bus) Deliver(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// the subscriber is not keeping up, it misses this event
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event from now on, and a function to unsubscribe.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
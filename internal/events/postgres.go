@@ -0,0 +1,86 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// pgChannel is the Postgres notification channel events are relayed on.
+const pgChannel = "grocademy_events"
+
+// PGRelay relays events between replicas with Postgres LISTEN/NOTIFY.
+type PGRelay struct {
+	DB       *sql.DB
+	Listener *pq.Listener
+	Bus      *Bus
+}
+
+// NewPGRelay listens for relayed events on a dedicated connection to dsn and delivers them to bus.
+// It becomes the bus's relay once listening.
+func NewPGRelay(db *sql.DB, dsn string, bus *Bus) (*PGRelay, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("event listener: %v", err)
+		}
+	})
+	if err := listener.Listen(pgChannel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen for events: %w", err)
+	}
+
+	relay := &PGRelay{DB: db, Listener: listener, Bus: bus}
+	go relay.listen()
+	bus.Relay = relay
+
+	return relay, nil
+}
+
+// Send notifies every listening replica of the event. Notifications are delivered once the
+// publishing transaction commits, so events must be published after committing.
+func (r *PGRelay) Send(event Event) error {
+	payload, err := json.Marshal(envelope{Event: event, UserID: event.UserID, Public: event.Public})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if _, err := r.DB.Exec("SELECT pg_notify($1, $2)", pgChannel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify: %w", err)
+	}
+	return nil
+}
+
+func (r *PGRelay) listen() {
+	for {
+		select {
+		case notification, ok := <-r.Listener.Notify:
+			if !ok {
+				return
+			}
+			// A nil notification means the connection was lost and re-established, events may have been missed
+			if notification == nil {
+				continue
+			}
+
+			var received envelope
+			if err := json.Unmarshal([]byte(notification.Extra), &received); err != nil {
+				log.Printf("failed to decode relayed event: %v", err)
+				continue
+			}
+			event := received.Event
+			event.UserID = received.UserID
+			event.Public = received.Public
+			r.Bus.Deliver(event)
+		case <-time.After(90 * time.Second):
+			go r.Listener.Ping()
+		}
+	}
+}
+
+// Close stops listening.
+func (r *PGRelay) Close() error {
+	return r.Listener.Close()
+}
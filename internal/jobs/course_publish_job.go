@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/events"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CoursePublishJob publishes courses whose scheduled publish_at has passed.
+type CoursePublishJob struct {
+	DB     *gorm.DB
+	Events events.Publisher
+}
+
+// NewCoursePublishJob creates a new CoursePublishJob.
+func NewCoursePublishJob(db *gorm.DB, events events.Publisher) *CoursePublishJob {
+	return &CoursePublishJob{DB: db, Events: events}
+}
+
+func (j *CoursePublishJob) Name() string {
+	return "course-publish"
+}
+
+func (j *CoursePublishJob) Run() error {
+	now := time.Now()
+
+	var courses []models.Course
+	result := j.DB.Model(&courses).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "title"}}}).
+		Where("publish_at IS NOT NULL AND publish_at <= ?", now).
+		Where("status IN (?)", []string{models.CourseStatusDraft, models.CourseStatusInReview, models.CourseStatusArchived}).
+		Updates(map[string]interface{}{
+			"Status":      models.CourseStatusPublished,
+			"PublishedAt": now,
+			"PublishAt":   nil,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to publish scheduled courses: %w", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("Published %d scheduled course(s)", result.RowsAffected)
+	}
+	for _, course := range courses {
+		j.Events.Publish(events.Event{
+			Type:     events.CourseUpdated,
+			CourseID: course.ID,
+			Public:   true,
+			Data:     map[string]interface{}{"title": course.Title, "status": models.CourseStatusPublished},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/email"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// emailOutboxBatchSize caps how many emails a single run sends.
+	emailOutboxBatchSize = 50
+	// emailMaxAttempts is how many times delivery of an email is tried before giving up.
+	emailMaxAttempts = 8
+	// emailMaxBackoff caps the wait between two delivery attempts.
+	emailMaxBackoff = 6 * time.Hour
+	// emailClaimLease is how long claimed emails are left to the run that claimed them. It outlasts a whole
+	// batch of sends timing out, so only emails of a run that crashed are picked up again.
+	emailClaimLease = emailOutboxBatchSize*email.SendTimeout + 5*time.Minute
+)
+
+// EmailOutboxJob delivers queued emails, retrying failed deliveries with exponential backoff.
+type EmailOutboxJob struct {
+	DB     *gorm.DB
+	Sender email.Sender
+}
+
+// NewEmailOutboxJob creates a new EmailOutboxJob.
+func NewEmailOutboxJob(db *gorm.DB, sender email.Sender) *EmailOutboxJob {
+	return &EmailOutboxJob{DB: db, Sender: sender}
+}
+
+func (j *EmailOutboxJob) Name() string {
+	return "email-outbox"
+}
+
+// Run claims a batch of due emails, then sends them outside of any transaction, recording each result
+// as it comes in. A slow SMTP server therefore holds no locks, and a failure to record one result does
+// not resend the emails already sent.
+func (j *EmailOutboxJob) Run() error {
+	pending, err := j.claim()
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	for _, outboxEmail := range pending {
+		updates := j.deliver(outboxEmail)
+		if updates["SentAt"] != nil {
+			sent++
+		}
+		if err := j.DB.Model(&outboxEmail).Updates(updates).Error; err != nil {
+			log.Printf("failed to update email %d: %v", outboxEmail.ID, err)
+		}
+	}
+
+	if sent > 0 {
+		log.Printf("Sent %d email(s)", sent)
+	}
+	return nil
+}
+
+// claim picks the due emails and postpones their next attempt by emailClaimLease, so no other run,
+// on this replica or another, sends them meanwhile.
+func (j *EmailOutboxJob) claim() ([]models.OutboxEmail, error) {
+	var pending []models.OutboxEmail
+	err := j.DB.Transaction(func(tx *gorm.DB) error {
+		// Rows locked by another replica's claim are skipped rather than sent twice
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", now).
+			Order("next_attempt_at").
+			Limit(emailOutboxBatchSize).
+			Find(&pending).Error; err != nil {
+			return fmt.Errorf("failed to find pending emails: %w", err)
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(pending))
+		for i, outboxEmail := range pending {
+			ids[i] = outboxEmail.ID
+		}
+		if err := tx.Model(&models.OutboxEmail{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(emailClaimLease)).Error; err != nil {
+			return fmt.Errorf("failed to claim pending emails: %w", err)
+		}
+		return nil
+	})
+	return pending, err
+}
+
+// deliver sends one email and returns the changes to record on its outbox row.
+func (j *EmailOutboxJob) deliver(outboxEmail models.OutboxEmail) map[string]interface{} {
+	now := time.Now()
+	err := j.Sender.Send(email.Message{
+		To:      outboxEmail.Recipient,
+		Subject: outboxEmail.Subject,
+		Text:    outboxEmail.TextBody,
+		HTML:    outboxEmail.HTMLBody,
+	})
+	if err == nil {
+		// Bodies are dropped once sent, as they can hold one-time tokens
+		return map[string]interface{}{"SentAt": now, "Attempts": outboxEmail.Attempts + 1, "LastError": "", "TextBody": "", "HTMLBody": ""}
+	}
+
+	attempts := outboxEmail.Attempts + 1
+	updates := map[string]interface{}{
+		"Attempts":      attempts,
+		"LastError":     err.Error(),
+		"NextAttemptAt": now.Add(emailRetryBackoff(attempts)),
+	}
+	if attempts >= emailMaxAttempts {
+		log.Printf("giving up on email %d to %s after %d attempts: %v", outboxEmail.ID, outboxEmail.Recipient, attempts, err)
+		updates["FailedAt"] = now
+	}
+	return updates
+}
+
+// emailRetryBackoff is the wait before the next attempt after the given number of failed ones.
+func emailRetryBackoff(attempts int) time.Duration {
+	backoff := time.Minute << (attempts - 1)
+	if backoff <= 0 || backoff > emailMaxBackoff {
+		return emailMaxBackoff
+	}
+	return backoff
+}
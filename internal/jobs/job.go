@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Job is a unit of background work that runs periodically for the lifetime of the process.
+type Job interface {
+	Name() string
+	Run() error
+}
+
+// Start runs the job once every interval in its own goroutine. The interval can be
+// overridden with an environment variable holding a Go duration string (e.g. "30s").
+func Start(job Job, interval time.Duration, intervalEnv string) {
+	if value := os.Getenv(intervalEnv); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Printf("WARNING: invalid %s %q, using default %s", intervalEnv, value, interval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := job.Run(); err != nil {
+				log.Printf("job %s failed: %v", job.Name(), err)
+			}
+		}
+	}()
+
+	log.Printf("Job %s scheduled every %s", job.Name(), interval)
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"grocademy/internal/db/models"
+
+	"gorm.io/gorm"
+)
+
+// loginThrottleRetention is how long failed logins are kept, well past the longest throttle window.
+const loginThrottleRetention = 24 * time.Hour
+
+// LoginThrottleCleanupJob deletes the failed login counters that no longer throttle anything.
+type LoginThrottleCleanupJob struct {
+	DB *gorm.DB
+}
+
+// NewLoginThrottleCleanupJob creates a new LoginThrottleCleanupJob.
+func NewLoginThrottleCleanupJob(db *gorm.DB) *LoginThrottleCleanupJob {
+	return &LoginThrottleCleanupJob{DB: db}
+}
+
+func (j *LoginThrottleCleanupJob) Name() string {
+	return "login-throttle-cleanup"
+}
+
+func (j *LoginThrottleCleanupJob) Run() error {
+	now := time.Now()
+	if err := j.DB.Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-loginThrottleRetention), now).
+		Delete(&models.LoginThrottle{}).Error; err != nil {
+		return fmt.Errorf("failed to delete old login throttles: %w", err)
+	}
+	return nil
+}
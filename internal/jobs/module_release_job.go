@@ -0,0 +1,117 @@
+package jobs
+
+import (
+	"fmt"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/events"
+	"grocademy/internal/services"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// moduleReleaseBatchSize caps how many notifications a single run sends.
+const moduleReleaseBatchSize = 500
+
+// ModuleReleaseJob notifies enrolled users when a drip-scheduled module becomes available to them, and
+// publishes the modules that were hidden until then to their open event streams.
+type ModuleReleaseJob struct {
+	DB       *gorm.DB
+	Notifier services.TxNotifier
+	Events   events.Publisher
+}
+
+// NewModuleReleaseJob creates a new ModuleReleaseJob.
+func NewModuleReleaseJob(db *gorm.DB, notifier services.TxNotifier, events events.Publisher) *ModuleReleaseJob {
+	return &ModuleReleaseJob{DB: db, Notifier: notifier, Events: events}
+}
+
+func (j *ModuleReleaseJob) Name() string {
+	return "module-release"
+}
+
+// Run sends one notification per (user, module) pair whose release moment has passed.
+// Modules that were already available when the user bought the course are skipped.
+func (j *ModuleReleaseJob) Run() error {
+	var released []struct {
+		UserID      uint
+		ModuleID    uint
+		ModuleTitle string
+		Hidden      bool
+		CourseID    uint
+		CourseTitle string
+	}
+
+	err := j.DB.Raw(`
+		SELECT r.user_id, r.module_id, r.module_title, r.hidden, r.course_id, r.course_title
+		FROM (
+			SELECT enrollments.user_id, enrollments.purchased_at,
+				modules.id AS module_id, modules.title AS module_title, modules.hide_until_released AS hidden,
+				courses.id AS course_id, courses.title AS course_title,
+				GREATEST(
+					COALESCE(modules.release_at, '-infinity'::timestamptz),
+					COALESCE(enrollments.purchased_at + make_interval(days => modules.release_after_days), '-infinity'::timestamptz)
+				) AS released_at
+			FROM modules
+			JOIN courses ON courses.id = modules.course_id AND courses.deleted_at IS NULL
+			JOIN enrollments ON enrollments.course_id = modules.course_id AND enrollments.deleted_at IS NULL
+			WHERE modules.deleted_at IS NULL
+			AND (modules.release_at IS NOT NULL OR modules.release_after_days IS NOT NULL)
+		) r
+		LEFT JOIN module_release_notices ON module_release_notices.user_id = r.user_id AND module_release_notices.module_id = r.module_id
+		WHERE module_release_notices.id IS NULL
+		AND r.released_at <= NOW()
+		AND r.released_at > r.purchased_at
+		ORDER BY r.released_at ASC
+		LIMIT ?`, moduleReleaseBatchSize).Scan(&released).Error
+	if err != nil {
+		return fmt.Errorf("failed to find released modules: %w", err)
+	}
+
+	for _, r := range released {
+		// The notice and the notification are stored together, so a failed notification is retried on the
+		// next run, and a notice another replica already recorded is skipped.
+		recorded := false
+		var notified []events.Event
+		err := j.DB.Transaction(func(tx *gorm.DB) error {
+			notice := models.ModuleReleaseNotice{UserID: r.UserID, ModuleID: r.ModuleID}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&notice)
+			if result.Error != nil {
+				return fmt.Errorf("failed to record release notice: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return nil
+			}
+
+			title := "New module available"
+			body := fmt.Sprintf("%q in %q is now available.", r.ModuleTitle, r.CourseTitle)
+			var err error
+			notified, err = j.Notifier.NotifyTx(tx, r.UserID, models.NotificationModuleReleased, title, body)
+			if err != nil {
+				return fmt.Errorf("failed to notify user %d: %w", r.UserID, err)
+			}
+			recorded = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, event := range notified {
+			j.Events.Publish(event)
+		}
+
+		// Creating the module only published it when it was not hidden until released
+		if recorded && r.Hidden {
+			j.Events.Publish(events.Event{
+				Type:     events.ModuleAdded,
+				CourseID: r.CourseID,
+				ModuleID: r.ModuleID,
+				UserID:   r.UserID,
+				Data:     map[string]interface{}{"title": r.ModuleTitle},
+			})
+		}
+	}
+
+	return nil
+}
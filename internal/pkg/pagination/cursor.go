@@ -0,0 +1,180 @@
+package pagination
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCursor is returned when a cursor was not issued by KeysetPaginate or does not fit its keys.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Key is a column a keyset page is ordered by. The keys of a keyset must identify a row uniquely,
+// so the last one is usually the primary key.
+type Key struct {
+	Column string // Qualified column name, e.g. "users.id"
+	Desc   bool
+}
+
+// CursorPagination describes a keyset page. Cursors are opaque to clients, which pass them back
+// as is to get the next or previous page.
+type CursorPagination struct {
+	Limit      int64  `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Next       string `json:"next,omitempty"` // Link to the next page, set by the handler
+	Prev       string `json:"prev,omitempty"` // Link to the previous page, set by the handler
+	TotalItems *int64 `json:"total_items,omitempty"`
+}
+
+// cursor is the decoded form of a cursor: the key values of the row to start after, and
+// whether to walk backwards from it.
+type cursor struct {
+	Values   []any `json:"v"`
+	Backward bool  `json:"b,omitempty"`
+}
+
+// KeysetPaginate runs db for the page of dest following (or preceding) the row the cursor points at,
+// ordered by keys. An empty cursor gives the first page. keyOf returns the values of keys for a row,
+// in the same order. Unlike Paginate it needs no OFFSET, and the total is only counted when withCount is set.
+func KeysetPaginate[T any](db *gorm.DB, dest *[]T, keys []Key, keyOf func(T) []any, cursorStr string, limit int64, withCount bool) (*[]T, CursorPagination, error) {
+	pagination := CursorPagination{Limit: limit}
+
+	if db.Statement.Model == nil {
+		db = db.Model(dest)
+	}
+
+	if withCount {
+		var totalItems int64
+		if err := db.Session(&gorm.Session{}).Count(&totalItems).Error; err != nil {
+			return nil, pagination, err
+		}
+		pagination.TotalItems = &totalItems
+	}
+
+	var after cursor
+	if cursorStr != "" {
+		var err error
+		if after, err = decodeCursor(cursorStr, len(keys)); err != nil {
+			return nil, pagination, err
+		}
+		db = db.Where(keysetCondition(keys, after.Values, after.Backward))
+	}
+
+	// Walking backwards reverses the order, the rows are put back in order below
+	for _, key := range keys {
+		desc := key.Desc != after.Backward
+		if desc {
+			db = db.Order(key.Column + " DESC")
+		} else {
+			db = db.Order(key.Column + " ASC")
+		}
+	}
+
+	// Fetch one row more than asked to know whether there is a page beyond this one
+	if err := db.Limit(int(limit + 1)).Find(dest).Error; err != nil {
+		return nil, pagination, err
+	}
+
+	rows := *dest
+	hasMore := int64(len(rows)) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if after.Backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	*dest = rows
+
+	if len(rows) == 0 {
+		return dest, pagination, nil
+	}
+
+	hasNext, hasPrev := hasMore, cursorStr != ""
+	if after.Backward {
+		hasNext, hasPrev = true, hasMore
+	}
+	if hasNext {
+		pagination.NextCursor = encodeCursor(cursor{Values: keyOf(rows[len(rows)-1])})
+	}
+	if hasPrev {
+		pagination.PrevCursor = encodeCursor(cursor{Values: keyOf(rows[0]), Backward: true})
+	}
+
+	return dest, pagination, nil
+}
+
+// keysetCondition matches the rows after values in the order of keys, or before them when backward is set.
+// For keys (a, b) ascending it is "a > ? OR (a = ? AND b > ?)".
+func keysetCondition(keys []Key, values []any, backward bool) clause.Expr {
+	var parts []string
+	var args []any
+	for i, key := range keys {
+		var conds []string
+		for j := range i {
+			conds = append(conds, keys[j].Column+" = ?")
+			args = append(args, values[j])
+		}
+
+		op := ">"
+		if key.Desc != backward {
+			op = "<"
+		}
+		conds = append(conds, fmt.Sprintf("%s %s ?", key.Column, op))
+		args = append(args, values[i])
+
+		parts = append(parts, "("+strings.Join(conds, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(parts, " OR ") + ")", Vars: args}
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor for n keys. JSON loses the types of the key values, so numbers are
+// turned back into integers where they are whole and strings into times where they parse as one.
+func decodeCursor(s string, n int) (cursor, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil || len(c.Values) != n {
+		return c, ErrInvalidCursor
+	}
+
+	for i, value := range c.Values {
+		switch v := value.(type) {
+		case json.Number:
+			if integer, err := v.Int64(); err == nil {
+				c.Values[i] = integer
+			} else if f, err := v.Float64(); err == nil {
+				c.Values[i] = f
+			} else {
+				return c, ErrInvalidCursor
+			}
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				c.Values[i] = t
+			}
+		case nil:
+			// NULL keys cannot be compared
+			return c, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
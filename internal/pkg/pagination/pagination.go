@@ -13,31 +13,13 @@ type Pagination struct {
 	TotalItems  int64 `json:"total_items"`
 }
 
+// Paginate runs db for one page of dest. query, when set, filters the rows on searchableColumns.
 func Paginate(db *gorm.DB, dest any, page, limit int64, searchableColumns []string, query string) (any, Pagination, error) {
 	var totalItems int64
 
-	countDB := db
-	if query != "" && len(searchableColumns) > 0 {
-		searchQuery := ""
-		for i, col := range searchableColumns {
-			if col == "courses.topics" {
-				searchQuery += "EXISTS (SELECT 1 FROM unnest(courses.topics) AS t WHERE t ILIKE ?)"
-			} else {
-				searchQuery += fmt.Sprintf("%s ILIKE ?", col)
-			}
-			// searchQuery += fmt.Sprintf("%s ILIKE ?", col) // ILIKE is case-insensitive
-			if i < len(searchableColumns)-1 {
-				searchQuery += " OR "
-			}
-		}
-
-		args := make([]interface{}, len(searchableColumns))
-		for i := range searchableColumns {
-			args[i] = fmt.Sprintf("%%%s%%", query)
-		}
-
-		db = db.Where(searchQuery, args...)
-		countDB = countDB.Where(searchQuery, args...)
+	db = Search(db, searchableColumns, query)
+	if db.Statement.Model == nil {
+		db = db.Model(dest)
 	}
 
 	pagination := Pagination{
@@ -46,11 +28,52 @@ func Paginate(db *gorm.DB, dest any, page, limit int64, searchableColumns []stri
 		TotalItems:  0,
 	}
 
-	if err := countDB.Find(dest).Count(&totalItems).Error; err != nil {
+	// Count on a copy of the statement, so that the count does not load the rows nor alter the query
+	if err := db.Session(&gorm.Session{}).Count(&totalItems).Error; err != nil {
+		return nil, pagination, err
+	}
+
+	pagination = New(page, limit, totalItems)
+
+	offset := (pagination.CurrentPage - 1) * limit
+
+	// Execute the query with limit and offset
+	if err := db.Limit(int(limit)).Offset(int(offset)).Find(dest).Error; err != nil {
 		return nil, pagination, err
 	}
-	println(totalItems)
 
+	return dest, pagination, nil
+}
+
+// Search filters db on the rows where any of searchableColumns contains query, case-insensitively.
+// db is returned as is when there is no query.
+func Search(db *gorm.DB, searchableColumns []string, query string) *gorm.DB {
+	if query == "" || len(searchableColumns) == 0 {
+		return db
+	}
+
+	searchQuery := ""
+	for i, col := range searchableColumns {
+		if col == "courses.topics" {
+			searchQuery += "EXISTS (SELECT 1 FROM unnest(courses.topics) AS t WHERE t ILIKE ?)"
+		} else {
+			searchQuery += fmt.Sprintf("%s ILIKE ?", col) // ILIKE is case-insensitive
+		}
+		if i < len(searchableColumns)-1 {
+			searchQuery += " OR "
+		}
+	}
+
+	args := make([]interface{}, len(searchableColumns))
+	for i := range searchableColumns {
+		args[i] = fmt.Sprintf("%%%s%%", query)
+	}
+
+	return db.Where(searchQuery, args...)
+}
+
+// New computes the pagination of totalItems, clamping page to the available pages.
+func New(page, limit, totalItems int64) Pagination {
 	totalPages := int64(math.Ceil(float64(totalItems) / float64(limit)))
 	if totalPages == 0 && totalItems > 0 {
 		totalPages = 1
@@ -63,16 +86,9 @@ func Paginate(db *gorm.DB, dest any, page, limit int64, searchableColumns []stri
 		page = totalPages
 	}
 
-	pagination.CurrentPage = page
-	pagination.TotalItems = totalItems
-	pagination.TotalPages = totalPages
-
-	offset := (page - 1) * limit
-
-	// Execute the query with limit and offset
-	if err := db.Limit(int(limit)).Offset(int(offset)).Find(dest).Error; err != nil {
-		return nil, pagination, err
+	return Pagination{
+		CurrentPage: page,
+		TotalPages:  totalPages,
+		TotalItems:  totalItems,
 	}
-
-	return dest, pagination, nil
 }
@@ -0,0 +1,31 @@
+package pagination
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Sorts maps the sort names an endpoint accepts to their ORDER BY expressions.
+type Sorts map[string]string
+
+// ApplySort orders db by the named sort, or by the fallback sort when name is empty.
+func ApplySort(db *gorm.DB, sorts Sorts, name, fallback string) (*gorm.DB, error) {
+	if name == "" {
+		name = fallback
+	}
+	order, ok := sorts[name]
+	if !ok {
+		return db, fmt.Errorf("invalid sort %q", name)
+	}
+	return db.Order(order), nil
+}
+
+// FacetBucket is one value of a facet and the number of items having it.
+type FacetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets groups facet buckets by facet name.
+type Facets map[string][]FacetBucket
@@ -6,6 +6,7 @@ import (
 	"grocademy/internal/pkg/string_array"
 	"math/rand"
 	"reflect"
+	"time"
 
 	"github.com/go-faker/faker/v4"
 	"gorm.io/gorm"
@@ -38,6 +39,8 @@ func (s *Seeder) SeedUser(userCount int) {
 		if err != nil {
 			fmt.Println(err)
 		}
+		verifiedAt := time.Now()
+		a.EmailVerifiedAt = &verifiedAt
 		fmt.Printf("%+v\n", a)
 		if res := s.DB.Create(&a); res.Error != nil {
 			fmt.Println(res.Error)
@@ -80,6 +83,10 @@ func (s *Seeder) CustomGenerator(courseCount int) {
 		return "https://res.cloudinary.com/dlybowzgq/image/upload/v1756054842/dafdaf_fsusvf.jpg", nil
 	})
 
+	_ = faker.AddProvider("course_status", func(v reflect.Value) (interface{}, error) {
+		return models.CourseStatusPublished, nil
+	})
+
 	_ = faker.AddProvider("course_id", func(v reflect.Value) (interface{}, error) {
 		var course models.Course
 		s.DB.Order("RANDOM()").First(&course)
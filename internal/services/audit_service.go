@@ -0,0 +1,191 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/json_map"
+	"grocademy/internal/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+// auditExportBatchSize is how many audit log entries an export loads at a time.
+const auditExportBatchSize = 500
+
+// AuditEntry is an action to record in the audit log. Before and After are structs or maps, encoded
+// like the API encodes them, so fields hidden from JSON are never recorded.
+type AuditEntry struct {
+	ActorID       uint
+	ActorUsername string
+	ActorRole     string
+	Action        string
+	TargetType    string
+	TargetID      string
+	Before        any
+	After         any
+	IPAddress     string
+	RequestID     string
+}
+
+// AuditLogFilter narrows down the audit log. Empty fields match every entry.
+type AuditLogFilter struct {
+	ActorID    *uint
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+}
+
+type AuditServicer interface {
+	Record(entry AuditEntry) error
+	RecordTx(tx *gorm.DB, entry AuditEntry) error
+	Snapshot(targetType string, targetID uint) any
+	GetAuditLogs(filter AuditLogFilter, cursor string, limit int64, withCount bool) (*[]models.AuditLog, pagination.CursorPagination, error)
+	ExportAuditLogs(filter AuditLogFilter, write func([]models.AuditLog) error) error
+}
+
+type AuditService struct {
+	DB *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{DB: db}
+}
+
+// auditLogKeys orders the audit log for keyset pagination, newest entries first.
+var auditLogKeys = []pagination.Key{
+	{Column: "audit_logs.created_at", Desc: true},
+	{Column: "audit_logs.id", Desc: true},
+}
+
+// Record stores an entry in the audit log.
+func (s *AuditService) Record(entry AuditEntry) error {
+	return s.RecordTx(s.DB, entry)
+}
+
+// RecordTx stores an entry in the audit log within the transaction of the action, so the action fails
+// if it cannot be recorded.
+func (s *AuditService) RecordTx(tx *gorm.DB, entry AuditEntry) error {
+	before, err := auditSnapshot(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := auditSnapshot(entry.After)
+	if err != nil {
+		return err
+	}
+
+	log := models.AuditLog{
+		ActorUsername: entry.ActorUsername,
+		ActorRole:     entry.ActorRole,
+		Action:        entry.Action,
+		TargetType:    entry.TargetType,
+		TargetID:      entry.TargetID,
+		Before:        before,
+		After:         after,
+		IPAddress:     entry.IPAddress,
+		RequestID:     entry.RequestID,
+	}
+	if entry.ActorID != 0 {
+		log.ActorID = &entry.ActorID
+	}
+	if err := tx.Create(&log).Error; err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
+
+// Snapshot loads the current state of a user, course, module or review, to record as the state before
+// an action. It returns nil for other targets and missing records.
+func (s *AuditService) Snapshot(targetType string, targetID uint) any {
+	var target any
+	switch targetType {
+	case models.AuditTargetUser:
+		target = &models.User{}
+	case models.AuditTargetCourse:
+		target = &models.Course{}
+	case models.AuditTargetModule:
+		target = &models.Module{}
+	case models.AuditTargetReview:
+		target = &models.Review{}
+	default:
+		return nil
+	}
+	if err := s.DB.First(target, targetID).Error; err != nil {
+		return nil
+	}
+	return target
+}
+
+// GetAuditLogs lists the audit log entries matching the filter on the keyset page the cursor points at.
+func (s *AuditService) GetAuditLogs(filter AuditLogFilter, cursor string, limit int64, withCount bool) (*[]models.AuditLog, pagination.CursorPagination, error) {
+	var logs []models.AuditLog
+	return pagination.KeysetPaginate(
+		filterAuditLogs(s.DB.Model(&models.AuditLog{}), filter),
+		&logs,
+		auditLogKeys,
+		func(log models.AuditLog) []any { return []any{log.CreatedAt, log.ID} },
+		cursor,
+		limit,
+		withCount,
+	)
+}
+
+// ExportAuditLogs passes every audit log entry matching the filter to write, oldest first, a batch at a time.
+func (s *AuditService) ExportAuditLogs(filter AuditLogFilter, write func([]models.AuditLog) error) error {
+	var batch []models.AuditLog
+	result := filterAuditLogs(s.DB.Model(&models.AuditLog{}), filter).
+		FindInBatches(&batch, auditExportBatchSize, func(tx *gorm.DB, _ int) error {
+			return write(batch)
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to export audit logs: %w", result.Error)
+	}
+	return nil
+}
+
+func filterAuditLogs(db *gorm.DB, filter AuditLogFilter) *gorm.DB {
+	if filter.ActorID != nil {
+		db = db.Where("audit_logs.actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		db = db.Where("audit_logs.action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		db = db.Where("audit_logs.target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		db = db.Where("audit_logs.target_id = ?", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		db = db.Where("audit_logs.request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		db = db.Where("audit_logs.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("audit_logs.created_at < ?", *filter.To)
+	}
+	return db
+}
+
+// auditSnapshot encodes the state of a target the way the API shows it.
+func auditSnapshot(value any) (json_map.JSONMap, error) {
+	if value == nil {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+	var snapshot json_map.JSONMap
+	if err := json.Unmarshal(bytes, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode audit snapshot: %w", err)
+	}
+	return snapshot, nil
+}
@@ -5,25 +5,77 @@ import (
 	"fmt"
 	"grocademy/internal/auth"
 	"grocademy/internal/db/models"
+	"grocademy/internal/email"
+	"grocademy/internal/sso"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthServicer interface {
-	RegisterUser(username, email, password, firstName, lastName string) (*models.User, error)
-	LoginUser(email, password, site string) (string, string, error)
+	RegisterUser(username, email, password, firstName, lastName, locale string) (*models.User, error)
+	LoginUser(email, password, site, ip string) (*LoginResult, error)
+	LoginWithIdentity(provider string, identity *sso.Identity) (*LoginResult, error)
+	BeginLoginTOTPSetup(challengeToken string) (*TOTPSetup, error)
+	CompleteLogin(challengeToken, code string) (*LoginResult, error)
 	GetCurrentUser(username string) (*models.User, error)
+	RequestPasswordReset(identifier string) error
+	ResetPassword(token, password string) error
+	ResendVerificationEmail(userID uint) error
+	VerifyEmail(token string) error
+	ConfirmEmailChange(token string) error
+	ValidateSession(userID uint, sessionVersion int) error
+}
+
+const (
+	// passwordResetTokenTTL is how long an emailed password reset link stays valid.
+	passwordResetTokenTTL = time.Hour
+	// emailVerificationTokenTTL is how long an emailed verification link stays valid.
+	emailVerificationTokenTTL = 48 * time.Hour
+)
+
+// ErrEmailAlreadyVerified is returned when asking to verify an email address that is already verified.
+var ErrEmailAlreadyVerified = errors.New("email already verified")
+
+// ErrUnverifiedIdentityEmail is returned when an external provider did not verify the user's email address,
+// so the identity cannot be linked to an account.
+var ErrUnverifiedIdentityEmail = errors.New("the sign in provider did not verify your email address")
+
+// RateLimitError is returned when too many requests were made for the same identifier.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return "too many requests, try again later"
+}
+
+// LoginResult is the outcome of a login step. Either Token is set, or the user still has to pass
+// the second factor of ChallengePurpose using ChallengeToken.
+type LoginResult struct {
+	Username         string
+	Token            string
+	ChallengeToken   string
+	ChallengePurpose string
+	RecoveryCodes    []string // Set when the login enrolled the user in two-factor authentication
 }
 
 type AuthService struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Mailer Mailer
+	MFA    MFAServicer
 }
 
-func NewAuthService(db *gorm.DB) *AuthService {
-	return &AuthService{DB: db}
+func NewAuthService(db *gorm.DB, mailer Mailer, mfa MFAServicer) *AuthService {
+	return &AuthService{DB: db, Mailer: mailer, MFA: mfa}
 }
 
-func (s *AuthService) RegisterUser(username, email, password, firstName, lastName string) (*models.User, error) {
+func (s *AuthService) RegisterUser(username, email, password, firstName, lastName, locale string) (*models.User, error) {
 
 	if username == "admin" || email == "admin@example.com" {
 		return nil, errors.New("username is reserved")
@@ -58,42 +110,360 @@ func (s *AuthService) RegisterUser(username, email, password, firstName, lastNam
 		FirstName: firstName,
 		LastName:  lastName,
 		Balance:   0,
+		Role:      models.UserRoleStudent,
+		Locale:    locale,
 	}
 
 	if result := s.DB.Create(user); result.Error != nil {
 		return nil, fmt.Errorf("failed to register user: %w", result.Error)
 	}
 
+	if err := sendVerificationEmail(s.DB, s.Mailer, user.ID); err != nil {
+		log.Printf("failed to email user %d a verification link: %v", user.ID, err)
+	}
 	return user, nil
 }
 
-func (s *AuthService) LoginUser(identifier, password, site string) (string, string, error) {
+// sendWelcome queues the welcome email of a user who just verified their email address.
+func (s *AuthService) sendWelcome(user *models.User) {
+	if err := s.Mailer.Mail(user.ID, email.TemplateWelcome, nil); err != nil {
+		log.Printf("failed to email user %d a welcome: %v", user.ID, err)
+	}
+}
+
+// LoginUser checks the user's password. Users with two-factor authentication, or whose role requires it,
+// get a challenge token for CompleteLogin instead of a session token.
+//
+// Failed logins are counted per submitted identifier, per account and per client IP. After a few failures
+// every attempt has to wait longer, and after more the identifier or account is locked out for a while.
+// Identifiers are throttled the same way whether or not they belong to an account, so the response never
+// reveals whether one exists. The account's throttle is shared by its username and email address.
+func (s *AuthService) LoginUser(identifier, password, site, ip string) (*LoginResult, error) {
+	ipKey := ipLoginThrottleKey(ip)
+	if _, _, err := reserveLoginAttempt(s.DB, ipKey, ipLoginPolicy); err != nil {
+		return nil, err
+	}
+	identifierKey := identifierLoginThrottleKey(identifier)
+	failures, locked, err := reserveLoginAttempt(s.DB, identifierKey, accountLoginPolicy)
+	if err != nil {
+		// The attempt was never made, so it does not count against the client
+		if releaseErr := releaseLoginAttempt(s.DB, ipKey, ipLoginPolicy); releaseErr != nil {
+			log.Print(releaseErr)
+		}
+		return nil, err
+	}
 
 	var user models.User
 
 	result := s.DB.Where("email = ?", identifier).Or("username = ?", identifier).First(&user)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return "", "", errors.New("invalid credentials")
-		}
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("database error during login: %w", result.Error)
+	}
 
-		return "", "", fmt.Errorf("database error during login: %w", result.Error)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		// Take as long as checking a real password
+		auth.CheckPasswordHash(password, dummyPasswordHash())
+		return nil, errors.New("invalid credentials")
 	}
 
-	if user.Username != "admin" && site == "admin" {
-		return "", "", errors.New("non-admin cannot login to admin FE")
+	accountFailures, accountLocked, err := reserveLoginAttempt(s.DB, userLoginThrottleKey(user.ID), accountLoginPolicy)
+	if err != nil {
+		// The attempt was never made, so it does not count against the client or the identifier
+		if releaseErr := releaseLoginAttempt(s.DB, ipKey, ipLoginPolicy); releaseErr != nil {
+			log.Print(releaseErr)
+		}
+		if releaseErr := releaseLoginAttempt(s.DB, identifierKey, accountLoginPolicy); releaseErr != nil {
+			log.Print(releaseErr)
+		}
+		return nil, err
 	}
+	failures = max(failures, accountFailures)
 
 	if !auth.CheckPasswordHash(password, user.Password) {
-		return "", "", errors.New("invalid credentials")
+		if locked || accountLocked {
+			s.sendSuspiciousLogin(&user, failures, ip, true)
+		}
+		return nil, errors.New("invalid credentials")
+	}
+
+	if err := releaseLoginAttempt(s.DB, ipKey, ipLoginPolicy); err != nil {
+		return nil, err
+	}
+	if err := clearLoginThrottle(s.DB, append(accountLoginThrottleKeys(&user), identifierKey)...); err != nil {
+		return nil, err
+	}
+	// The reservation of this attempt is not one of the failures
+	if failures-1 >= accountLoginPolicy.FreeAttempts {
+		s.sendSuspiciousLogin(&user, failures-1, ip, false)
+	}
+
+	if user.Role != models.UserRoleAdmin && site == "admin" {
+		return nil, errors.New("non-admin cannot login to admin FE")
+	}
+
+	return s.startSession(&user)
+}
+
+// sendSuspiciousLogin emails a user about failed attempts to sign in to their account, either because
+// it was locked out or because a login succeeded after many failures.
+func (s *AuthService) sendSuspiciousLogin(user *models.User, failures int, ip string, locked bool) {
+	if err := s.Mailer.Mail(user.ID, email.TemplateSuspiciousLogin, map[string]interface{}{
+		"FailedAttempts": failures,
+		"IPAddress":      ip,
+		"Locked":         locked,
+		"LockedMinutes":  int(accountLoginPolicy.LockoutDuration.Minutes()),
+	}); err != nil {
+		log.Printf("failed to email user %d about suspicious logins: %v", user.ID, err)
 	}
+}
+
+// dummyPasswordHash is checked against the password of logins without an account.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("not-a-real-password")
+	return hash
+})
+
+// startSession issues the session token of a user who proved their identity, or a challenge token
+// if they still have to pass the second factor.
+func (s *AuthService) startSession(user *models.User) (*LoginResult, error) {
+	purpose := ""
+	if user.TOTPEnabledAt != nil {
+		purpose = auth.ChallengeMFAVerify
+	} else if s.MFA.IsMFARequired(user.Role) {
+		purpose = auth.ChallengeMFASetup
+	}
+	if purpose != "" {
+		challengeToken, err := auth.GenerateChallengeJWT(user.ID, user.SessionVersion, purpose)
+		if err != nil {
+			return nil, errors.New("failed to generate token")
+		}
+		return &LoginResult{Username: user.Username, ChallengeToken: challengeToken, ChallengePurpose: purpose}, nil
+	}
+
+	return s.issueSession(user)
+}
+
+// validateChallenge parses a challenge token, rejecting it once the user's sessions were revoked since,
+// for example by a password reset.
+func (s *AuthService) validateChallenge(challengeToken string) (*auth.ChallengeClaims, error) {
+	claims, err := auth.ValidateChallengeJWT(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ValidateSession(claims.ID, claims.SessionVersion); err != nil {
+		if err.Error() == "user not found" || err.Error() == "session revoked" {
+			return nil, errors.New("invalid or expired challenge token")
+		}
+		return nil, err
+	}
+	return claims, nil
+}
+
+// BeginLoginTOTPSetup starts enrolling a user whose role requires two-factor authentication during login.
+func (s *AuthService) BeginLoginTOTPSetup(challengeToken string) (*TOTPSetup, error) {
+	claims, err := s.validateChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != auth.ChallengeMFASetup {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return s.MFA.BeginTOTPSetup(claims.ID)
+}
+
+// CompleteLogin passes the second factor of a login and issues the session token. For users enrolling
+// during login, the code confirms their new authenticator and their recovery codes are returned.
+func (s *AuthService) CompleteLogin(challengeToken, code string) (*LoginResult, error) {
+	claims, err := s.validateChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	switch claims.Purpose {
+	case auth.ChallengeMFAVerify:
+		err = s.MFA.VerifySecondFactor(claims.ID, code)
+	case auth.ChallengeMFASetup:
+		recoveryCodes, err = s.MFA.EnableTOTP(claims.ID, code)
+	default:
+		err = errors.New("invalid or expired challenge token")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.DB.First(&user, claims.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid credentials")
+		}
+		return nil, fmt.Errorf("database error during login: %w", err)
+	}
+
+	result, err := s.issueSession(&user)
+	if err != nil {
+		return nil, err
+	}
+	result.RecoveryCodes = recoveryCodes
+	return result, nil
+}
+
+// LoginWithIdentity signs in the user linked to an identity at an external provider. Identities that are not
+// linked yet are linked to the user with the same email address, or to a new account, if the provider
+// verified the address.
+func (s *AuthService) LoginWithIdentity(provider string, identity *sso.Identity) (*LoginResult, error) {
+	var user models.User
+	created := false
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var linked models.ExternalIdentity
+		err := tx.Where("provider = ? AND subject = ?", provider, identity.Subject).First(&linked).Error
+		if err == nil {
+			if err := tx.First(&user, linked.UserID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errors.New("invalid credentials")
+				}
+				return fmt.Errorf("database error during login: %w", err)
+			}
+			return tx.Model(&linked).Updates(map[string]interface{}{"Email": identity.Email, "LastLoginAt": now}).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("database error during login: %w", err)
+		}
+
+		if identity.Email == "" || !identity.EmailVerified {
+			return ErrUnverifiedIdentityEmail
+		}
+
+		err = tx.Where("LOWER(email) = LOWER(?)", identity.Email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := s.createIdentityUser(tx, &user, identity); err != nil {
+				return err
+			}
+			created = true
+		case err != nil:
+			return fmt.Errorf("database error during login: %w", err)
+		case user.EmailVerifiedAt == nil:
+			// Nobody proved owning the address when the account was registered, so whoever did may not be
+			// the person signing in now. Their password and authenticator stop working.
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"EmailVerifiedAt": now,
+				"Password":        "",
+				"TOTPSecret":      "",
+				"TOTPEnabledAt":   nil,
+				"SessionVersion":  revokeSessions,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to claim account: %w", err)
+			}
+			if err := tx.Select("session_version").First(&user).Error; err != nil {
+				return fmt.Errorf("database error during login: %w", err)
+			}
+			if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+				return fmt.Errorf("failed to claim account: %w", err)
+			}
+		}
+
+		if err := tx.Create(&models.ExternalIdentity{
+			UserID:      user.ID,
+			Provider:    provider,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			LastLoginAt: &now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to link identity: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if created {
+		s.sendWelcome(&user)
+	}
+	return s.startSession(&user)
+}
 
-	token, err := auth.GenerateJWT(user.ID, user.Username, user.Email)
+// createIdentityUser creates the account of a user signing in with an external provider for the first time.
+// The account has no password until the user resets it.
+func (s *AuthService) createIdentityUser(tx *gorm.DB, user *models.User, identity *sso.Identity) error {
+	username, err := availableUsername(tx, identity)
 	if err != nil {
-		return "", "", errors.New("failed to generate token")
+		return err
 	}
 
-	return user.Username, token, nil
+	firstName := identity.FirstName
+	if firstName == "" {
+		firstName = username
+	}
+	locale := email.LocaleIndonesian
+	if strings.HasPrefix(strings.ToLower(identity.Locale), email.LocaleEnglish) {
+		locale = email.LocaleEnglish
+	}
+	now := time.Now()
+
+	*user = models.User{
+		Username:        username,
+		Email:           identity.Email,
+		FirstName:       firstName,
+		LastName:        identity.LastName,
+		Role:            models.UserRoleStudent,
+		Locale:          locale,
+		EmailVerifiedAt: &now,
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to register user: %w", err)
+	}
+	return nil
+}
+
+// availableUsername derives an unused username from the identity's preferred username or email address.
+func availableUsername(tx *gorm.DB, identity *sso.Identity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, base)
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	username := base
+	for attempt := 0; attempt < 10; attempt++ {
+		if username != "admin" {
+			// Deleted users keep their username
+			var taken int64
+			if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+				return "", fmt.Errorf("database error checking existing user: %w", err)
+			}
+			if taken == 0 {
+				return username, nil
+			}
+		}
+		username = fmt.Sprintf("%s%04d", base, rand.Intn(10000))
+	}
+	return "", errors.New("failed to find an available username")
+}
+
+func (s *AuthService) issueSession(user *models.User) (*LoginResult, error) {
+	token, err := auth.GenerateJWT(user.ID, user.Username, user.Email, user.Role, user.SessionVersion)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+	return &LoginResult{Username: user.Username, Token: token}, nil
 }
 
 func (s *AuthService) GetCurrentUser(username string) (*models.User, error) {
@@ -111,3 +481,203 @@ func (s *AuthService) GetCurrentUser(username string) (*models.User, error) {
 	return &user, nil
 
 }
+
+// RequestPasswordReset emails a password reset link to the user with the given email or username.
+// Unknown identifiers are not reported, so the endpoint cannot be used to find out who has an account.
+//
+// Requests are limited per identifier, whether or not it belongs to an account, and per account, so the
+// username and email address of one account share a limit.
+func (s *AuthService) RequestPasswordReset(identifier string) error {
+	identifier = strings.TrimSpace(identifier)
+	if _, _, err := reserveLoginAttempt(s.DB, "reset:"+identifierLoginThrottleKey(identifier), resetRequestPolicy); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := s.DB.Where("email = ?", identifier).Or("username = ?", identifier).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("database error finding user: %w", err)
+	}
+	if _, _, err := reserveLoginAttempt(s.DB, "reset:"+userLoginThrottleKey(user.ID), resetRequestPolicy); err != nil {
+		return err
+	}
+
+	token, err := issueUserToken(s.DB, user.ID, models.UserTokenPasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+	return s.Mailer.Mail(user.ID, email.TemplatePasswordReset, map[string]interface{}{
+		"Token":            token,
+		"ExpiresInMinutes": int(passwordResetTokenTTL.Minutes()),
+	})
+}
+
+// ResetPassword sets a new password using an emailed reset token, signs the user out everywhere and revokes
+// their personal access tokens.
+func (s *AuthService) ResetPassword(token, password string) error {
+	if !auth.IsStrongPassword(password) {
+		return errors.New("password is weak")
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, token, models.UserTokenPasswordReset)
+		if err != nil {
+			return err
+		}
+
+		// A pending email change may have been started by whoever made the owner reset their password
+		if err := tx.Model(&models.User{ID: userToken.UserID}).Updates(map[string]interface{}{
+			"Password":       hashedPassword,
+			"SessionVersion": revokeSessions,
+			"PendingEmail":   "",
+		}).Error; err != nil {
+			return fmt.Errorf("failed to reset password: %w", err)
+		}
+		// Tokens may have been created by whoever made the owner reset their password, too
+		if err := tx.Model(&models.PersonalAccessToken{}).Where("user_id = ? AND revoked_at IS NULL", userToken.UserID).
+			Update("RevokedAt", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to revoke access tokens: %w", err)
+		}
+		// Whoever proves owning the email address may sign in again right away
+		var user models.User
+		if err := tx.Select("id", "username", "email").First(&user, userToken.UserID).Error; err != nil {
+			return fmt.Errorf("database error finding user: %w", err)
+		}
+		return clearLoginThrottle(tx, accountLoginThrottleKeys(&user)...)
+	})
+}
+
+// revokeSessions is the update of User.SessionVersion that revokes every session token issued so far.
+var revokeSessions = gorm.Expr("session_version + 1")
+
+// ValidateSession rejects tokens of deleted users and tokens issued before the user's sessions were revoked.
+func (s *AuthService) ValidateSession(userID uint, sessionVersion int) error {
+	var user models.User
+	if err := s.DB.Select("id", "session_version").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return fmt.Errorf("database error finding user: %w", err)
+	}
+
+	if sessionVersion != user.SessionVersion {
+		return errors.New("session revoked")
+	}
+	return nil
+}
+
+// ResendVerificationEmail emails a new verification link to an unverified user, invalidating the previous one.
+func (s *AuthService) ResendVerificationEmail(userID uint) error {
+	var user models.User
+	if err := s.DB.Select("id", "email_verified_at").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return fmt.Errorf("database error finding user: %w", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	if _, _, err := reserveLoginAttempt(s.DB, "verify:"+userLoginThrottleKey(userID), verifyResendPolicy); err != nil {
+		return err
+	}
+	return sendVerificationEmail(s.DB, s.Mailer, userID)
+}
+
+// VerifyEmail marks the email address of a user as verified using an emailed verification token.
+func (s *AuthService) VerifyEmail(token string) error {
+	var user models.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, token, models.UserTokenEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		user.ID = userToken.UserID
+		result := tx.Model(&user).Where("email_verified_at IS NULL").Update("EmailVerifiedAt", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("failed to verify email: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrEmailAlreadyVerified
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sendWelcome(&user)
+	return nil
+}
+
+// ConfirmEmailChange replaces the email address of a user with their pending one, using the token from
+// the confirmation link emailed to the pending address. Opening the link also verifies the address.
+func (s *AuthService) ConfirmEmailChange(token string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		userToken, err := consumeUserToken(tx, token, models.UserTokenEmailChange)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userToken.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidUserToken
+			}
+			return fmt.Errorf("database error finding user: %w", err)
+		}
+		// Cancelled since the link was sent
+		if user.PendingEmail == "" {
+			return ErrInvalidUserToken
+		}
+
+		var taken int64
+		if err := tx.Model(&models.User{}).Where("LOWER(email) = LOWER(?) AND id <> ?", user.PendingEmail, user.ID).Count(&taken).Error; err != nil {
+			return fmt.Errorf("database error checking existing user: %w", err)
+		}
+		if taken > 0 {
+			return errors.New("email already registered")
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"Email":           user.PendingEmail,
+			"PendingEmail":    "",
+			"EmailVerifiedAt": time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to change email: %w", err)
+		}
+		return nil
+	})
+}
+
+// sendEmailChangeConfirmation emails a link to confirm the email address a user is changing to.
+func sendEmailChangeConfirmation(db *gorm.DB, mailer Mailer, userID uint, pendingEmail string) error {
+	token, err := issueUserToken(db, userID, models.UserTokenEmailChange, emailVerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+	return mailer.MailTo(userID, pendingEmail, email.TemplateConfirmEmail, map[string]interface{}{
+		"Token":          token,
+		"ExpiresInHours": int(emailVerificationTokenTTL.Hours()),
+	})
+}
+
+// sendVerificationEmail emails a user a link to verify their email address.
+func sendVerificationEmail(db *gorm.DB, mailer Mailer, userID uint) error {
+	token, err := issueUserToken(db, userID, models.UserTokenEmailVerification, emailVerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+	return mailer.Mail(userID, email.TemplateVerifyEmail, map[string]interface{}{
+		"Token":          token,
+		"ExpiresInHours": int(emailVerificationTokenTTL.Hours()),
+	})
+}
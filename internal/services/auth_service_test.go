@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"grocademy/internal/db/dbtest"
+	"grocademy/internal/db/models"
+	"grocademy/internal/email"
+)
+
+func TestRequestPasswordResetThrottle(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.UserToken{}, &models.LoginThrottle{}, &models.OutboxEmail{})
+	s := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+	createTestUser(t, db, models.User{Username: "ada", Email: "ada@example.com", FirstName: "Ada"})
+
+	tests := []struct {
+		name        string
+		identifiers []string // Requested in turn, the last one is expected to be throttled
+	}{
+		{"unknown identifier", []string{"nobody", "nobody", "Nobody", "nobody"}},
+		{"username and email of one account", []string{"ada", "ada@example.com", "ada", "ada@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := len(tt.identifiers) - 1
+			for _, identifier := range tt.identifiers[:last] {
+				if err := s.RequestPasswordReset(identifier); err != nil {
+					t.Fatalf("request with %q error = %v", identifier, err)
+				}
+			}
+			var rateLimitErr *RateLimitError
+			if err := s.RequestPasswordReset(tt.identifiers[last]); !errors.As(err, &rateLimitErr) {
+				t.Errorf("request with %q error = %v, want to wait", tt.identifiers[last], err)
+			}
+		})
+	}
+
+	var sent int64
+	db.Model(&models.OutboxEmail{}).Where("template = ?", email.TemplatePasswordReset).Count(&sent)
+	if sent != int64(resetRequestPolicy.LockoutAttempts) {
+		t.Errorf("%d reset emails were queued, want %d", sent, resetRequestPolicy.LockoutAttempts)
+	}
+}
+
+func TestResetPasswordRevokesAccessTokens(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.UserToken{}, &models.LoginThrottle{}, &models.PersonalAccessToken{})
+	s := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+	user := createTestUser(t, db, models.User{Username: "ada", Email: "ada@example.com", FirstName: "Ada"})
+
+	tokens := NewPersonalAccessTokenService(db)
+	_, plain, err := tokens.CreateToken(user.ID, "script", []string{models.ScopeCoursesRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetToken, err := issueUserToken(db, user.ID, models.UserTokenPasswordReset, passwordResetTokenTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ResetPassword(resetToken, "N3w!Passw0rd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tokens.AuthenticateToken(plain); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Errorf("AuthenticateToken after the reset error = %v, want %v", err, ErrInvalidAccessToken)
+	}
+}
+
+func TestResendVerificationEmailThrottle(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.UserToken{}, &models.LoginThrottle{}, &models.OutboxEmail{})
+	user := createTestUser(t, db, models.User{Username: "ada", Email: "ada@example.com", FirstName: "Ada"})
+
+	// Each request goes to another replica, the throttle is shared through the database
+	for attempt := 1; attempt <= verifyResendPolicy.LockoutAttempts; attempt++ {
+		s := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+		if err := s.ResendVerificationEmail(user.ID); err != nil {
+			t.Fatalf("request %d error = %v", attempt, err)
+		}
+	}
+
+	s := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+	var rateLimitErr *RateLimitError
+	if err := s.ResendVerificationEmail(user.ID); !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter < 59*time.Minute {
+		t.Errorf("request after the limit error = %v, want to wait an hour", err)
+	}
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+// CommentServicer defines the operations on module discussions. Every method checks that the user
+// may take part in the discussion of the module: enrolled students, the course's instructors and admins.
+type CommentServicer interface {
+	GetThreads(userID uint, role string, moduleID uint, kind string, page, limit int64, query string) (*[]CommentView, pagination.Pagination, error)
+	GetThread(userID uint, role string, threadID uint, page, limit int64) (*CommentThread, pagination.Pagination, error)
+	CreateThread(userID uint, role string, moduleID uint, kind, body string) (*models.Comment, error)
+	CreateReply(userID uint, role string, parentID uint, body string) (*models.Comment, error)
+	UpdateComment(userID uint, role string, commentID uint, body string) (*models.Comment, error)
+	DeleteComment(userID uint, role string, commentID uint) error
+	VoteComment(userID uint, role string, commentID uint, upvote bool) (*models.Comment, error)
+	AcceptAnswer(userID uint, role string, answerID uint, accepted bool) (*models.Comment, error)
+	PinThread(userID uint, role string, threadID uint, pinned bool) (*models.Comment, error)
+}
+
+// ErrNotInDiscussion is returned when a user may not read or post in a module's discussion.
+var ErrNotInDiscussion = errors.New("only enrolled users can take part in this discussion")
+
+// ErrNotDiscussionModerator is returned when a user who is not an instructor of the course or an admin tries to moderate.
+var ErrNotDiscussionModerator = errors.New("only the course's instructors can moderate this discussion")
+
+// mentionPattern matches @username mentions in comment bodies.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.-]+)`)
+
+// CommentView is a comment along with its author and how the requesting user relates to it.
+type CommentView struct {
+	models.Comment
+	Username   string `json:"username"`
+	ReplyCount int64  `json:"reply_count"` // Threads only
+	Voted      bool   `json:"voted"`       // Whether the requesting user upvoted the comment
+}
+
+// CommentThread is a thread and one page of its replies. Replies point at the comment they answer
+// through parent_id, so that clients can nest them.
+type CommentThread struct {
+	CommentView
+	Replies []CommentView `json:"replies"`
+}
+
+type CommentService struct {
+	DB       *gorm.DB
+	Notifier Notifier
+}
+
+func NewCommentService(db *gorm.DB, notifier Notifier) *CommentService {
+	return &CommentService{DB: db, Notifier: notifier}
+}
+
+// GetThreads lists the threads of a module, pinned ones first and then the most recently active.
+// kind narrows the list to questions or discussions when set.
+func (s *CommentService) GetThreads(userID uint, role string, moduleID uint, kind string, page, limit int64, query string) (*[]CommentView, pagination.Pagination, error) {
+	if _, err := s.authorize(userID, role, moduleID); err != nil {
+		return nil, pagination.Pagination{}, err
+	}
+
+	var threads []CommentView
+	dbQuery := s.commentQuery(userID).
+		Where("comments.module_id = ? AND comments.thread_id IS NULL", moduleID).
+		Order("comments.pinned_at DESC NULLS LAST, comments.updated_at DESC, comments.id DESC")
+	if kind != "" {
+		dbQuery = dbQuery.Where("comments.kind = ?", kind)
+	}
+
+	_, pagination, err := pagination.Paginate(dbQuery, &threads, page, limit, []string{"comments.body"}, query)
+	if err != nil {
+		return nil, pagination, fmt.Errorf("database error finding threads: %w", err)
+	}
+	return &threads, pagination, nil
+}
+
+// GetThread returns a thread with a page of its replies, the accepted answer first and then oldest first.
+func (s *CommentService) GetThread(userID uint, role string, threadID uint, page, limit int64) (*CommentThread, pagination.Pagination, error) {
+	var thread CommentThread
+	if err := s.commentQuery(userID).Where("comments.id = ? AND comments.thread_id IS NULL", threadID).
+		Take(&thread.CommentView).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, pagination.Pagination{}, errors.New("comment not found")
+		}
+		return nil, pagination.Pagination{}, fmt.Errorf("database error finding thread: %w", err)
+	}
+	if _, err := s.authorize(userID, role, thread.ModuleID); err != nil {
+		return nil, pagination.Pagination{}, err
+	}
+
+	dbQuery := s.commentQuery(userID).Where("comments.thread_id = ?", threadID)
+	if thread.AcceptedAnswerID != nil {
+		dbQuery = dbQuery.Order(fmt.Sprintf("comments.id = %d DESC", *thread.AcceptedAnswerID))
+	}
+	dbQuery = dbQuery.Order("comments.created_at ASC, comments.id ASC")
+
+	thread.Replies = []CommentView{}
+	_, pagination, err := pagination.Paginate(dbQuery, &thread.Replies, page, limit, nil, "")
+	if err != nil {
+		return nil, pagination, fmt.Errorf("database error finding replies: %w", err)
+	}
+	return &thread, pagination, nil
+}
+
+func (s *CommentService) CreateThread(userID uint, role string, moduleID uint, kind, body string) (*models.Comment, error) {
+	if kind != models.CommentKindQuestion && kind != models.CommentKindDiscussion {
+		return nil, errors.New("invalid thread kind")
+	}
+	if _, err := s.authorize(userID, role, moduleID); err != nil {
+		return nil, err
+	}
+
+	comment := models.Comment{ModuleID: moduleID, UserID: userID, Kind: kind, Body: body}
+	if err := s.DB.Create(&comment).Error; err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+
+	s.notifyMentions(comment, "")
+	return &comment, nil
+}
+
+// CreateReply replies to a thread or to another reply. Direct replies to a question are answers.
+func (s *CommentService) CreateReply(userID uint, role string, parentID uint, body string) (*models.Comment, error) {
+	parent, err := s.getComment(parentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.authorize(userID, role, parent.ModuleID); err != nil {
+		return nil, err
+	}
+
+	comment := models.Comment{ModuleID: parent.ModuleID, UserID: userID, ParentID: &parent.ID, Kind: models.CommentKindReply, Body: body}
+	if parent.ThreadID == nil {
+		comment.ThreadID = &parent.ID
+		if parent.Kind == models.CommentKindQuestion {
+			comment.Kind = models.CommentKindAnswer
+		}
+	} else {
+		comment.ThreadID = parent.ThreadID
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return fmt.Errorf("failed to create comment: %w", err)
+		}
+		// Bump the thread so that active threads are listed first
+		if err := tx.Model(&models.Comment{}).Where("id = ?", *comment.ThreadID).
+			UpdateColumn("updated_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to update thread: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyMentions(comment, "")
+	return &comment, nil
+}
+
+// UpdateComment edits the body of a comment. Only its author can do this.
+func (s *CommentService) UpdateComment(userID uint, role string, commentID uint, body string) (*models.Comment, error) {
+	comment, err := s.getComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.authorize(userID, role, comment.ModuleID); err != nil {
+		return nil, err
+	}
+	if comment.UserID != userID {
+		return nil, errors.New("only the author can edit a comment")
+	}
+
+	previous := comment.Body
+	if err := s.DB.Model(comment).Update("Body", body).Error; err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	s.notifyMentions(*comment, previous)
+	return comment, nil
+}
+
+// DeleteComment deletes a comment, and all its replies when it is a thread. Authors can delete their
+// own comments, moderators any comment.
+func (s *CommentService) DeleteComment(userID uint, role string, commentID uint) error {
+	comment, err := s.getComment(commentID)
+	if err != nil {
+		return err
+	}
+	moderator, err := s.authorize(userID, role, comment.ModuleID)
+	if err != nil {
+		return err
+	}
+	if comment.UserID != userID && !moderator {
+		return ErrNotDiscussionModerator
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if comment.ThreadID == nil {
+			if err := tx.Where("thread_id = ?", comment.ID).Delete(&models.Comment{}).Error; err != nil {
+				return fmt.Errorf("failed to delete replies: %w", err)
+			}
+		} else if err := tx.Model(&models.Comment{}).
+			Where("id = ? AND accepted_answer_id = ?", *comment.ThreadID, comment.ID).
+			UpdateColumn("accepted_answer_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to update thread: %w", err)
+		}
+		if err := tx.Delete(comment).Error; err != nil {
+			return fmt.Errorf("failed to delete comment: %w", err)
+		}
+		return nil
+	})
+}
+
+// VoteComment adds or withdraws the user's upvote of a comment. Voting twice counts once.
+func (s *CommentService) VoteComment(userID uint, role string, commentID uint, upvote bool) (*models.Comment, error) {
+	comment, err := s.getComment(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.authorize(userID, role, comment.ModuleID); err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if upvote {
+			if err := tx.Exec("INSERT INTO comment_votes (created_at, comment_id, user_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+				time.Now(), comment.ID, userID).Error; err != nil {
+				return fmt.Errorf("failed to save vote: %w", err)
+			}
+		} else if err := tx.Where("comment_id = ? AND user_id = ?", comment.ID, userID).Delete(&models.CommentVote{}).Error; err != nil {
+			return fmt.Errorf("failed to delete vote: %w", err)
+		}
+
+		// UpdateColumn leaves updated_at alone, votes are not activity on the thread
+		if err := tx.Model(comment).UpdateColumn("score",
+			tx.Model(&models.CommentVote{}).Select("count(*)").Where("comment_id = ?", comment.ID)).Error; err != nil {
+			return fmt.Errorf("failed to update score: %w", err)
+		}
+		return tx.First(comment, comment.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// AcceptAnswer marks an answer as the accepted one of its question, replacing any previous one,
+// or unmarks it. Only moderators can do this.
+func (s *CommentService) AcceptAnswer(userID uint, role string, answerID uint, accepted bool) (*models.Comment, error) {
+	answer, err := s.getComment(answerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeModerator(userID, role, answer.ModuleID); err != nil {
+		return nil, err
+	}
+	if answer.Kind != models.CommentKindAnswer {
+		return nil, errors.New("only answers to a question can be accepted")
+	}
+
+	question, err := s.getComment(*answer.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+
+	var update interface{}
+	if accepted {
+		update = answer.ID
+	} else if question.AcceptedAnswerID == nil || *question.AcceptedAnswerID != answer.ID {
+		return question, nil
+	}
+	if err := s.DB.Model(question).UpdateColumn("accepted_answer_id", update).Error; err != nil {
+		return nil, fmt.Errorf("failed to accept answer: %w", err)
+	}
+	if accepted {
+		question.AcceptedAnswerID = &answer.ID
+
+		title := "Your answer was accepted"
+		if err := s.Notifier.Notify(answer.UserID, models.NotificationAnswerAccepted, title, answer.Body); err != nil {
+			log.Printf("failed to notify user %d of accepted answer %d: %v", answer.UserID, answer.ID, err)
+		}
+	} else {
+		question.AcceptedAnswerID = nil
+	}
+	return question, nil
+}
+
+// PinThread pins a thread to the top of the module's discussion, or unpins it. Only moderators can do this.
+func (s *CommentService) PinThread(userID uint, role string, threadID uint, pinned bool) (*models.Comment, error) {
+	thread, err := s.getComment(threadID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorizeModerator(userID, role, thread.ModuleID); err != nil {
+		return nil, err
+	}
+	if thread.ThreadID != nil {
+		return nil, errors.New("only threads can be pinned")
+	}
+
+	var pinnedAt *time.Time
+	if pinned {
+		now := time.Now()
+		pinnedAt = &now
+	}
+	if err := s.DB.Model(thread).UpdateColumn("pinned_at", pinnedAt).Error; err != nil {
+		return nil, fmt.Errorf("failed to pin thread: %w", err)
+	}
+	thread.PinnedAt = pinnedAt
+	return thread, nil
+}
+
+// authorize checks that the user may take part in the discussion of a module, and reports whether
+// they may moderate it: admins and the course's instructors.
+func (s *CommentService) authorize(userID uint, role string, moduleID uint) (bool, error) {
+	var module models.Module
+	if err := s.DB.Select("id", "course_id").First(&module, moduleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, errors.New("module not found")
+		}
+		return false, fmt.Errorf("database error finding module: %w", err)
+	}
+
+	err := authorizeCourseManager(s.DB, userID, role, module.CourseID, false)
+	if err == nil {
+		return true, nil
+	} else if !errors.Is(err, ErrNotCourseInstructor) {
+		return false, err
+	}
+
+	var enrolled bool
+	if err := s.DB.Model(&models.Enrollment{}).Select("count(*) > 0").
+		Where("user_id = ? AND course_id = ?", userID, module.CourseID).Find(&enrolled).Error; err != nil {
+		return false, fmt.Errorf("database error finding enrollment: %w", err)
+	}
+	if !enrolled {
+		return false, ErrNotInDiscussion
+	}
+	return false, nil
+}
+
+func (s *CommentService) authorizeModerator(userID uint, role string, moduleID uint) error {
+	moderator, err := s.authorize(userID, role, moduleID)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		return ErrNotDiscussionModerator
+	}
+	return nil
+}
+
+func (s *CommentService) getComment(commentID uint) (*models.Comment, error) {
+	var comment models.Comment
+	if err := s.DB.First(&comment, commentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("comment not found")
+		}
+		return nil, fmt.Errorf("database error finding comment: %w", err)
+	}
+	return &comment, nil
+}
+
+func (s *CommentService) commentQuery(userID uint) *gorm.DB {
+	return s.DB.Model(&models.Comment{}).
+		Select(`comments.*, users.username,
+			(SELECT count(*) FROM comments AS replies WHERE replies.thread_id = comments.id AND replies.deleted_at IS NULL) AS reply_count,
+			EXISTS (SELECT 1 FROM comment_votes WHERE comment_votes.comment_id = comments.id AND comment_votes.user_id = ?) AS voted`, userID).
+		Joins("JOIN users ON users.id = comments.user_id")
+}
+
+// notifyMentions notifies the users mentioned in a comment who can read the discussion, except its author
+// and those already mentioned in the previous body of an edited comment. Failing to notify does not fail the comment.
+func (s *CommentService) notifyMentions(comment models.Comment, previousBody string) {
+	notified := make(map[string]bool)
+	for _, username := range parseMentions(previousBody) {
+		notified[username] = true
+	}
+
+	var usernames []string
+	for _, username := range parseMentions(comment.Body) {
+		if !notified[username] {
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 {
+		return
+	}
+
+	var author models.User
+	if err := s.DB.Select("id", "username").First(&author, comment.UserID).Error; err != nil {
+		log.Printf("failed to find author of comment %d: %v", comment.ID, err)
+		return
+	}
+
+	var users []models.User
+	if err := s.DB.Select("id", "username", "role").Where("username IN ? AND id <> ?", usernames, comment.UserID).Find(&users).Error; err != nil {
+		log.Printf("failed to find users mentioned in comment %d: %v", comment.ID, err)
+		return
+	}
+
+	threadID := comment.ID
+	if comment.ThreadID != nil {
+		threadID = *comment.ThreadID
+	}
+	title := fmt.Sprintf("%s mentioned you in a discussion", author.Username)
+	for _, user := range users {
+		if _, err := s.authorize(user.ID, user.Role, comment.ModuleID); err != nil {
+			continue
+		}
+		body := fmt.Sprintf("/modules/%d/comments/%d: %s", comment.ModuleID, threadID, comment.Body)
+		if err := s.Notifier.Notify(user.ID, models.NotificationCommentMention, title, body); err != nil {
+			log.Printf("failed to notify user %d of mention in comment %d: %v", user.ID, comment.ID, err)
+		}
+	}
+}
+
+// parseMentions returns the distinct usernames mentioned in a comment body.
+func parseMentions(body string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		if username := match[1]; !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
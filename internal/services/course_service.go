@@ -1,33 +1,128 @@
 package services
 
 import (
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"grocademy/internal/db/models"
+	"grocademy/internal/email"
+	"grocademy/internal/events"
 	"grocademy/internal/pkg/pagination"
+	"grocademy/internal/pkg/string_array"
 	"grocademy/internal/storage"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CourseServicer interface {
-	CreateCourse(title, description, instructor string, topics []string, price float64, thumbnail *multipart.FileHeader) (*models.Course, error)
-	GetCourseByID(userID, courseID uint) (*models.Course, int64, bool, error)
+	CreateCourse(actorID, ownerID uint, title, description, instructor string, topics []string, price float64, sequentialModules bool, thumbnail *multipart.FileHeader) (*models.Course, error)
+	GetCourseByID(userID, courseID uint, includeUnpublished bool) (*models.Course, int64, bool, error)
 	GetMyCourses(userID uint, page, limit int64, query string) (*[]MyCourseResponse, pagination.Pagination, error)
-	GetAllCoursesPaginated(page, limit int64, query string) (*[]map[string]interface{}, pagination.Pagination, error)
-	UpdateCourse(id uint, updates map[string]interface{}, thumbnail *multipart.FileHeader) (*models.Course, error)
+	GetAllCoursesPaginated(userID uint, page, limit int64, query string, filter CourseFilter, includeUnpublished bool) (*[]map[string]interface{}, pagination.Pagination, pagination.Facets, error)
+	UpdateCourse(id, actorID uint, updates map[string]interface{}, thumbnail *multipart.FileHeader) (*models.Course, error)
 	DeleteCourse(id uint) error
-	BuyCourse(userID uint, courseID uint) (float64, uint, error)
+	BuyCourse(userID uint, courseID uint, audit AuditEntry) (float64, uint, error)
+	GetCoursePrerequisites(userID, courseID uint) ([]CoursePrerequisiteStatus, error)
+	SetCoursePrerequisites(courseID uint, prerequisiteIDs []uint) error
+	ChangeCourseStatus(courseID uint, status string, publishAt *time.Time) (*models.Course, error)
+	GetCourseRevisions(courseID uint, page, limit int64) (*[]models.Revision, pagination.Pagination, error)
+	GetCourseRevision(courseID uint, version int) (*models.Revision, error)
+	RestoreCourseRevision(courseID, actorID uint, version int) (*models.Course, *models.Revision, error)
+	AuthorizeCourse(userID uint, role string, courseID uint) error
+}
+
+// courseStatusTransitions lists the statuses each course status may move to.
+var courseStatusTransitions = map[string][]string{
+	models.CourseStatusDraft:     {models.CourseStatusInReview, models.CourseStatusPublished},
+	models.CourseStatusInReview:  {models.CourseStatusDraft, models.CourseStatusPublished},
+	models.CourseStatusPublished: {models.CourseStatusArchived},
+	models.CourseStatusArchived:  {models.CourseStatusPublished},
+}
+
+// CourseFilter narrows down the course catalog. Zero values leave a filter out.
+type CourseFilter struct {
+	Topics       []string
+	TopicsMatch  string // "any" (default) or "all" of Topics
+	MinPrice     *float64
+	MaxPrice     *float64
+	FreeOnly     bool
+	Instructor   string // Part of the instructor's name
+	InstructorID uint   // Linked instructor account
+	HasVideo     *bool
+	MinRating    *float64
+	Enrolled     *bool // Whether the requesting user bought the course
+	Sort         string
+}
+
+// courseSorts are the catalog sort options. relevance only applies to searches.
+var courseSorts = pagination.Sorts{
+	"relevance":  "search_rank DESC, courses.id ASC",
+	"newest":     "courses.published_at DESC NULLS LAST, courses.created_at DESC, courses.id DESC",
+	"price_asc":  "courses.price ASC, courses.id ASC",
+	"price_desc": "courses.price DESC, courses.id ASC",
+	"popularity": "enrollment_count DESC, courses.id ASC",
+	"rating":     "courses.rating_average DESC, courses.rating_count DESC, courses.id ASC",
+	"title":      "courses.title ASC, courses.id ASC",
+}
+
+// Catalog facets.
+const (
+	courseFacetTopics     = "topics"
+	courseFacetPrice      = "price"
+	courseTopicFacetLimit = 30
+	coursePriceBucketTop  = "200+"
+)
+
+// coursePriceBuckets are the price facet buckets in increasing order, each holding prices below its bound.
+// Prices from the last bound up fall in coursePriceBucketTop.
+var coursePriceBuckets = []struct {
+	Label string
+	Below float64
+}{
+	{"free", 0.01},
+	{"0-50", 50},
+	{"50-100", 100},
+	{"100-200", 200},
+}
+
+// ErrInvalidStatusTransition is returned when a course status change is not allowed from the current status.
+var ErrInvalidStatusTransition = errors.New("cannot change course status")
+
+// ErrUnmetPrerequisites is returned when a user tries to buy a course before finishing its prerequisites.
+var ErrUnmetPrerequisites = errors.New("unmet course prerequisites")
+
+// ErrEmailNotVerified is returned when a user who has not verified their email address tries to buy a course.
+var ErrEmailNotVerified = errors.New("verify your email address before buying courses")
+
+// CoursePrerequisiteStatus is one entry of a course's prerequisite chain along with the user's progress on it.
+type CoursePrerequisiteStatus struct {
+	CourseID           uint    `json:"course_id"`
+	Title              string  `json:"title"`
+	RequiredBy         uint    `json:"required_by"` // Course that directly requires this one
+	Depth              int     `json:"depth"`       // 1 for direct prerequisites, 2 for theirs, and so on
+	Completed          bool    `json:"completed"`
+	ProgressPercentage float64 `json:"progress_percentage"`
 }
 
 type CourseService struct {
-	DB    *gorm.DB
-	Cloud storage.CloudStorage
+	DB        *gorm.DB
+	Cloud     storage.CloudStorage
+	Revisions RevisionServicer
+	Events    events.Publisher
+	Notifier  Notifier
+	Mailer    Mailer
+	Audit     AuditServicer
 }
 
 type MyCourseResponse struct {
@@ -36,16 +131,25 @@ type MyCourseResponse struct {
 	ProgressPercentage float64 `json:"progress_percentage"`
 }
 
-func NewCourseService(db *gorm.DB, cloud storage.CloudStorage) *CourseService {
-	return &CourseService{DB: db, Cloud: cloud}
+func NewCourseService(db *gorm.DB, cloud storage.CloudStorage, revisions RevisionServicer, events events.Publisher, notifier Notifier, mailer Mailer, audit AuditServicer) *CourseService {
+	return &CourseService{DB: db, Cloud: cloud, Revisions: revisions, Events: events, Notifier: notifier, Mailer: mailer, Audit: audit}
 }
 
+// CreateCourse creates a draft course. When ownerID is set, that instructor is linked to the course as its owner.
 func (s *CourseService) CreateCourse(
+	actorID, ownerID uint,
 	title, description, instructor string,
 	topics []string,
 	price float64,
+	sequentialModules bool,
 	thumbnail *multipart.FileHeader,
 ) (*models.Course, error) {
+	if ownerID != 0 {
+		if err := checkInstructorAccount(s.DB, ownerID); err != nil {
+			return nil, err
+		}
+	}
+
 	var thumbnailPath string
 
 	if thumbnail != nil {
@@ -58,22 +162,39 @@ func (s *CourseService) CreateCourse(
 	}
 
 	course := models.Course{
-		Title:          title,
-		Description:    description,
-		Instructor:     instructor,
-		Topics:         topics,
-		Price:          price,
-		ThumbnailImage: thumbnailPath,
+		Title:             title,
+		Description:       description,
+		Instructor:        instructor,
+		Topics:            topics,
+		Price:             price,
+		ThumbnailImage:    thumbnailPath,
+		SequentialModules: sequentialModules,
+		Status:            models.CourseStatusDraft,
 	}
 
-	if result := s.DB.Create(&course); result.Error != nil {
-		return nil, fmt.Errorf("failed to create course in DB: %w", result.Error)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&course).Error; err != nil {
+			return fmt.Errorf("failed to create course in DB: %w", err)
+		}
+		if ownerID != 0 {
+			owner := models.CourseInstructor{CourseID: course.ID, UserID: ownerID, Role: models.CourseInstructorOwner}
+			if err := tx.Create(&owner).Error; err != nil {
+				return fmt.Errorf("failed to link course owner: %w", err)
+			}
+		}
+		_, err := s.Revisions.Record(tx, models.RevisionEntityCourse, course.ID, actorID, models.RevisionActionCreate, nil, courseSnapshot(course), courseAssets(course))
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	s.Events.Publish(courseEvent(events.CourseCreated, course, false))
 	return &course, nil
 }
 
-func (s *CourseService) GetCourseByID(userID, courseID uint) (*models.Course, int64, bool, error) {
+// GetCourseByID retrieves a course. Unpublished courses are only visible to enrollees, or when includeUnpublished is set.
+func (s *CourseService) GetCourseByID(userID, courseID uint, includeUnpublished bool) (*models.Course, int64, bool, error) {
 	var course models.Course
 	result := s.DB.First(&course, courseID)
 	if result.Error != nil {
@@ -91,48 +212,194 @@ func (s *CourseService) GetCourseByID(userID, courseID uint) (*models.Course, in
 		return nil, 0, false, err
 	}
 
+	if course.Status != models.CourseStatusPublished && !includeUnpublished && !purchased {
+		return nil, 0, false, errors.New("course not found")
+	}
+
 	return &course, totalModules, purchased, nil
 }
 
-func (s *CourseService) GetAllCoursesPaginated(page, limit int64, query string) (*[]map[string]interface{}, pagination.Pagination, error) {
+// GetAllCoursesPaginated lists the catalog filtered and sorted as asked, along with topic and price facet counts.
+// Only published courses are listed unless includeUnpublished is set. A query runs a ranked full-text search,
+// and every course then carries its rank and highlighted snippets.
+func (s *CourseService) GetAllCoursesPaginated(userID uint, page, limit int64, query string, filter CourseFilter, includeUnpublished bool) (*[]map[string]interface{}, pagination.Pagination, pagination.Facets, error) {
 	var results []struct {
 		models.Course
-		TotalModules int64
+		TotalModules         int64
+		EnrollmentCount      int64
+		SearchRank           float64
+		TitleHighlight       string
+		DescriptionHighlight string
+	}
+
+	sort := filter.Sort
+	if sort == "" && query != "" {
+		sort = "relevance"
+	} else if sort == "relevance" && query == "" {
+		sort = ""
 	}
 
 	// Build the base query for both filtering and counting.
-	dbQuery := s.DB.Model(&models.Course{}).
-		Select("courses.*, count(modules.id) as total_modules").
-		Joins("left join modules on modules.course_id = courses.id").
-		Group("courses.id")
+	selects := `courses.*,
+		(SELECT count(*) FROM modules WHERE modules.course_id = courses.id AND modules.deleted_at IS NULL) AS total_modules,
+		(SELECT count(*) FROM enrollments WHERE enrollments.course_id = courses.id AND enrollments.deleted_at IS NULL) AS enrollment_count`
+	dbQuery := s.filterCourses(userID, query, filter, includeUnpublished, "").Select(selects)
+	if query != "" {
+		dbQuery = dbQuery.Select(selects+", "+courseSearchColumns, sql.Named("q", query))
+	}
+	dbQuery, err := pagination.ApplySort(dbQuery, courseSorts, sort, "newest")
+	if err != nil {
+		return nil, pagination.Pagination{}, nil, err
+	}
 
-	searchableColumns := []string{"courses.title", "courses.instructor", "courses.topics"}
-	// Paginate the query and execute
-	_, pagination, err := pagination.Paginate(dbQuery, &results, page, limit, searchableColumns, query)
+	// Paginate the query and execute, the search condition replaces Paginate's own filter
+	_, pagination, err := pagination.Paginate(dbQuery, &results, page, limit, nil, "")
 	if err != nil {
-		return nil, pagination, err
+		return nil, pagination, nil, err
+	}
+
+	facets, err := s.getCourseFacets(userID, query, filter, includeUnpublished)
+	if err != nil {
+		return nil, pagination, nil, err
 	}
 
 	// Transfer data to a final response format
 	var coursesWithCount []map[string]interface{}
 	for _, res := range results {
 		courseMap := map[string]interface{}{
-			"id":              res.ID,
-			"title":           res.Title,
-			"description":     res.Description,
-			"instructor":      res.Instructor,
-			"topics":          res.Topics,
-			"price":           res.Price,
-			"thumbnail_image": res.ThumbnailImage,
-			"created_at":      res.CreatedAt,
-			"updated_at":      res.UpdatedAt,
-			"deleted_at":      res.DeletedAt,
-			"total_modules":   res.TotalModules, // ADDED
+			"id":                 res.ID,
+			"title":              res.Title,
+			"description":        res.Description,
+			"instructor":         res.Instructor,
+			"topics":             res.Topics,
+			"price":              res.Price,
+			"thumbnail_image":    res.ThumbnailImage,
+			"sequential_modules": res.SequentialModules,
+			"status":             res.Status,
+			"publish_at":         res.PublishAt,
+			"published_at":       res.PublishedAt,
+			"rating_average":     res.RatingAverage,
+			"rating_count":       res.RatingCount,
+			"rating_histogram":   res.RatingHistogram,
+			"enrollment_count":   res.EnrollmentCount,
+			"created_at":         res.CreatedAt,
+			"updated_at":         res.UpdatedAt,
+			"deleted_at":         res.DeletedAt,
+			"total_modules":      res.TotalModules, // ADDED
+		}
+		if query != "" {
+			courseMap["search_rank"] = res.SearchRank
+			courseMap["highlights"] = map[string]string{
+				"title":       res.TitleHighlight,
+				"description": res.DescriptionHighlight,
+			}
 		}
 		coursesWithCount = append(coursesWithCount, courseMap)
 	}
 
-	return &coursesWithCount, pagination, nil
+	return &coursesWithCount, pagination, facets, nil
+}
+
+// filterCourses builds the catalog query for a search and filter. The filter named by skip is left out,
+// so that a facet counts every value of its own dimension rather than only the selected ones.
+func (s *CourseService) filterCourses(userID uint, query string, filter CourseFilter, includeUnpublished bool, skip string) *gorm.DB {
+	dbQuery := s.DB.Model(&models.Course{})
+	if !includeUnpublished {
+		dbQuery = dbQuery.Where("courses.status = ?", models.CourseStatusPublished)
+	}
+	if query != "" {
+		dbQuery = dbQuery.Where(courseSearchCondition, sql.Named("q", query))
+	}
+
+	if len(filter.Topics) > 0 && skip != courseFacetTopics {
+		if filter.TopicsMatch == "all" {
+			dbQuery = dbQuery.Where("courses.topics @> ?", string_array.StringArray(filter.Topics))
+		} else {
+			dbQuery = dbQuery.Where("courses.topics && ?", string_array.StringArray(filter.Topics))
+		}
+	}
+	if skip != courseFacetPrice {
+		if filter.FreeOnly {
+			dbQuery = dbQuery.Where("courses.price = 0")
+		}
+		if filter.MinPrice != nil {
+			dbQuery = dbQuery.Where("courses.price >= ?", *filter.MinPrice)
+		}
+		if filter.MaxPrice != nil {
+			dbQuery = dbQuery.Where("courses.price <= ?", *filter.MaxPrice)
+		}
+	}
+	if filter.Instructor != "" {
+		dbQuery = dbQuery.Where("courses.instructor ILIKE ?", fmt.Sprintf("%%%s%%", filter.Instructor))
+	}
+	if filter.InstructorID != 0 {
+		dbQuery = dbQuery.Where("EXISTS (SELECT 1 FROM course_instructors WHERE course_instructors.course_id = courses.id AND course_instructors.user_id = ?)", filter.InstructorID)
+	}
+	if filter.HasVideo != nil {
+		hasVideo := "EXISTS (SELECT 1 FROM modules WHERE modules.course_id = courses.id AND modules.deleted_at IS NULL AND modules.video_path <> '')"
+		if *filter.HasVideo {
+			dbQuery = dbQuery.Where(hasVideo)
+		} else {
+			dbQuery = dbQuery.Where("NOT " + hasVideo)
+		}
+	}
+	if filter.MinRating != nil {
+		dbQuery = dbQuery.Where("courses.rating_count > 0 AND courses.rating_average >= ?", *filter.MinRating)
+	}
+	if filter.Enrolled != nil {
+		enrolled := "EXISTS (SELECT 1 FROM enrollments WHERE enrollments.course_id = courses.id AND enrollments.user_id = ? AND enrollments.deleted_at IS NULL)"
+		if *filter.Enrolled {
+			dbQuery = dbQuery.Where(enrolled, userID)
+		} else {
+			dbQuery = dbQuery.Where("NOT "+enrolled, userID)
+		}
+	}
+
+	return dbQuery
+}
+
+// getCourseFacets counts the catalog courses per topic and per price bucket.
+func (s *CourseService) getCourseFacets(userID uint, query string, filter CourseFilter, includeUnpublished bool) (pagination.Facets, error) {
+	topics := []pagination.FacetBucket{}
+	if err := s.filterCourses(userID, query, filter, includeUnpublished, courseFacetTopics).
+		Select("topic AS value, count(*) AS count").
+		Joins("CROSS JOIN unnest(courses.topics) AS topic").
+		Group("topic").
+		Order("count DESC, value ASC").
+		Limit(courseTopicFacetLimit).
+		Scan(&topics).Error; err != nil {
+		return nil, fmt.Errorf("database error counting topics: %w", err)
+	}
+
+	bucketCase := "CASE"
+	for _, bucket := range coursePriceBuckets {
+		bucketCase += fmt.Sprintf(" WHEN courses.price < %g THEN '%s'", bucket.Below, bucket.Label)
+	}
+	bucketCase += fmt.Sprintf(" ELSE '%s' END", coursePriceBucketTop)
+
+	var counts []pagination.FacetBucket
+	if err := s.filterCourses(userID, query, filter, includeUnpublished, courseFacetPrice).
+		Select(bucketCase + " AS value, count(*) AS count").
+		Group("value").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("database error counting prices: %w", err)
+	}
+
+	// List every bucket in price order, including empty ones
+	countByLabel := make(map[string]int64)
+	for _, count := range counts {
+		countByLabel[count.Value] = count.Count
+	}
+	prices := []pagination.FacetBucket{}
+	for _, bucket := range coursePriceBuckets {
+		prices = append(prices, pagination.FacetBucket{Value: bucket.Label, Count: countByLabel[bucket.Label]})
+	}
+	prices = append(prices, pagination.FacetBucket{Value: coursePriceBucketTop, Count: countByLabel[coursePriceBucketTop]})
+
+	return pagination.Facets{
+		courseFacetTopics: topics,
+		courseFacetPrice:  prices,
+	}, nil
 }
 
 func (s *CourseService) GetMyCourses(userID uint, page, limit int64, query string) (*[]MyCourseResponse, pagination.Pagination, error) {
@@ -156,15 +423,10 @@ func (s *CourseService) GetMyCourses(userID uint, page, limit int64, query strin
 	myCourses := []MyCourseResponse{}
 	for _, enrolledCourse := range results {
 
-		var totalModules int64
-		s.DB.Model(&models.Module{}).Where("course_id = ?", enrolledCourse.ID).Count(&totalModules)
-
-		// Get total completed modules for this user and course
-		var completedModules int64
-		s.DB.Model(&models.ModuleProgress{}).
-			Joins("JOIN modules ON modules.id = module_progress.module_id").
-			Where("module_progress.user_id = ? AND modules.course_id = ? AND module_progress.is_completed = ?", userID, enrolledCourse.ID, true).
-			Count(&completedModules)
+		totalModules, completedModules, err := countCourseProgress(s.DB, userID, enrolledCourse.ID)
+		if err != nil {
+			return nil, pagination, err
+		}
 
 		// Calculate progress percentage
 		progressPercentage := 0.0
@@ -182,7 +444,9 @@ func (s *CourseService) GetMyCourses(userID uint, page, limit int64, query strin
 	return &myCourses, pagination, nil
 }
 
-func (s *CourseService) UpdateCourse(id uint, updates map[string]interface{}, thumbnail *multipart.FileHeader) (*models.Course, error) {
+// UpdateCourse applies a partial update and records a revision. Replaced thumbnails stay in storage
+// so that older revisions can still be restored.
+func (s *CourseService) UpdateCourse(id, actorID uint, updates map[string]interface{}, thumbnail *multipart.FileHeader) (*models.Course, error) {
 	var course models.Course
 	result := s.DB.First(&course, id)
 	if result.Error != nil {
@@ -191,36 +455,34 @@ func (s *CourseService) UpdateCourse(id uint, updates map[string]interface{}, th
 		}
 		return nil, fmt.Errorf("database error finding course: %w", result.Error)
 	}
+	before := courseSnapshot(course)
 
 	// Handle thumbnail image update if provided
 	if thumbnail != nil {
-		// Save new thumbnail and update path
-		newPath, err := s.saveThumbnail(thumbnail, course.Title, course.ThumbnailImage)
+		// Upload as a new object, the old one is still referenced by earlier revisions
+		newPath, err := s.saveThumbnail(thumbnail, course.Title, "")
 		if err != nil {
 			return nil, err
 		}
 		updates["ThumbnailImage"] = newPath
-
-		// Optionally, delete the old thumbnail file
-		if course.ThumbnailImage != "" {
-			if err := os.Remove(course.ThumbnailImage); err != nil {
-				fmt.Printf("Warning: Failed to delete old thumbnail image %s: %v\n", course.ThumbnailImage, err)
-			}
-		}
 	} else if _, ok := updates["thumbnail_image"]; ok && updates["thumbnail_image"] == nil {
 		// If thumbnail_image was explicitly sent as null/empty string, clear the path
 		updates["ThumbnailImage"] = ""
-		if course.ThumbnailImage != "" {
-			if err := os.Remove(course.ThumbnailImage); err != nil {
-				fmt.Printf("Warning: Failed to delete old thumbnail image %s when clearing: %v\n", course.ThumbnailImage, err)
-			}
-		}
 	}
+	delete(updates, "thumbnail_image")
 
-	if err := s.DB.Model(&course).Updates(updates).Error; err != nil {
-		return nil, fmt.Errorf("failed to update course: %w", err)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&course).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update course: %w", err)
+		}
+		_, err := s.Revisions.Record(tx, models.RevisionEntityCourse, course.ID, actorID, models.RevisionActionUpdate, before, courseSnapshot(course), courseAssets(course))
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	s.Events.Publish(courseEvent(events.CourseUpdated, course, false))
 	return &course, nil
 }
 
@@ -237,6 +499,7 @@ func (s *CourseService) DeleteCourse(id uint) error {
 	if deleteResult := s.DB.Delete(&course); deleteResult.Error != nil {
 		return fmt.Errorf("failed to delete course: %w", deleteResult.Error)
 	}
+	s.Events.Publish(courseEvent(events.CourseDeleted, course, false))
 
 	// Optionally, delete the thumbnail file from storage on soft delete
 	if course.ThumbnailImage != "" {
@@ -286,7 +549,7 @@ func (s *CourseService) saveThumbnail(thumbnail *multipart.FileHeader, title str
 	return URL, nil
 }
 
-func (s *CourseService) BuyCourse(userID uint, courseID uint) (float64, uint, error) {
+func (s *CourseService) BuyCourse(userID uint, courseID uint, audit AuditEntry) (float64, uint, error) {
 	tx := s.DB.Begin() // Start a transaction for atomicity
 	if tx.Error != nil {
 		return 0, 0, fmt.Errorf("failed to begin transaction: %w", tx.Error)
@@ -307,6 +570,11 @@ func (s *CourseService) BuyCourse(userID uint, courseID uint) (float64, uint, er
 		return 0, 0, fmt.Errorf("database error checking course: %w", err)
 	}
 
+	if course.Status != models.CourseStatusPublished {
+		tx.Rollback()
+		return 0, 0, errors.New("course is not available for purchase")
+	}
+
 	// 2. Check if the user already purchased the course.
 	var existingEnrollment models.Enrollment
 	if err := tx.Where("user_id = ? AND course_id = ?", userID, courseID).First(&existingEnrollment).Error; err == nil {
@@ -317,29 +585,60 @@ func (s *CourseService) BuyCourse(userID uint, courseID uint) (float64, uint, er
 		return 0, 0, fmt.Errorf("database error checking existing enrollment: %w", err)
 	}
 
-	// 3. Check user balance.
+	// 3. Check that every prerequisite course is finished.
+	var prerequisites []models.Course
+	if err := tx.Joins("JOIN course_prerequisites ON course_prerequisites.prerequisite_id = courses.id").
+		Where("course_prerequisites.course_id = ?", courseID).
+		Order("courses.title ASC").
+		Find(&prerequisites).Error; err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("database error checking prerequisites: %w", err)
+	}
+
+	var unmet []string
+	for _, prerequisite := range prerequisites {
+		total, completed, err := countEnrolledCourseProgress(tx, userID, prerequisite.ID)
+		if err != nil {
+			tx.Rollback()
+			return 0, 0, err
+		}
+		if total == 0 || completed < total {
+			unmet = append(unmet, fmt.Sprintf("%q (%d/%d modules completed)", prerequisite.Title, completed, total))
+		}
+	}
+	if len(unmet) > 0 {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("%w: %s", ErrUnmetPrerequisites, strings.Join(unmet, ", "))
+	}
+
+	// 4. Check user balance. The row stays locked until commit so concurrent top-ups are not lost.
 	var user models.User
-	if err := tx.First(&user, userID).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
 		tx.Rollback()
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return 0, 0, errors.New("user not found")
 		}
 		return 0, 0, fmt.Errorf("database error checking user balance: %w", err)
 	}
+	if user.EmailVerifiedAt == nil {
+		tx.Rollback()
+		return user.Balance, 0, ErrEmailNotVerified
+	}
 
 	if user.Balance < course.Price {
 		tx.Rollback()
 		return user.Balance, 0, errors.New("insufficient balance")
 	}
 
-	// 4. Reduce user balance.
-	user.Balance -= course.Price
-	if err := tx.Save(&user).Error; err != nil {
+	// 5. Reduce user balance.
+	balanceBefore := user.Balance
+	if err := tx.Model(&user).Update("balance", gorm.Expr("balance - ?", course.Price)).Error; err != nil {
 		tx.Rollback()
-		return user.Balance, 0, fmt.Errorf("failed to reduce user balance: %w", err)
+		return balanceBefore, 0, fmt.Errorf("failed to reduce user balance: %w", err)
 	}
+	user.Balance = balanceBefore - course.Price
 
-	// 5. Create a new enrollment entry.
+	// 6. Create a new enrollment entry.
 	enrollment := models.Enrollment{
 		UserID:   userID,
 		CourseID: courseID,
@@ -349,5 +648,333 @@ func (s *CourseService) BuyCourse(userID uint, courseID uint) (float64, uint, er
 		return user.Balance, 0, fmt.Errorf("failed to create enrollment: %w", err)
 	}
 
-	return user.Balance, enrollment.TransactionID, tx.Commit().Error // Commit the transaction
+	// 7. Credit the instructor's share of the sale.
+	if err := recordPayout(tx, course, enrollment); err != nil {
+		tx.Rollback()
+		return user.Balance, 0, err
+	}
+
+	// 8. Record the purchase in the audit log, a purchase must never go unrecorded.
+	audit.Action = models.AuditCourseBuy
+	audit.TargetType = models.AuditTargetCourse
+	audit.TargetID = strconv.FormatUint(uint64(courseID), 10)
+	audit.Before = map[string]interface{}{"user_balance": balanceBefore}
+	audit.After = map[string]interface{}{"user_balance": user.Balance, "transaction_id": enrollment.TransactionID}
+	if err := s.Audit.RecordTx(tx, audit); err != nil {
+		tx.Rollback()
+		return balanceBefore, 0, err
+	}
+
+	if err := tx.Commit().Error; err != nil { // Commit the transaction
+		return user.Balance, 0, err
+	}
+
+	s.Events.Publish(events.Event{Type: events.CoursePurchased, CourseID: courseID, UserID: userID})
+	body := fmt.Sprintf("You now have access to %q. Happy learning!", course.Title)
+	if err := s.Notifier.Notify(userID, models.NotificationCoursePurchased, "Course purchased", body); err != nil {
+		log.Printf("failed to notify user %d of purchase: %v", userID, err)
+	}
+	if err := s.Mailer.Mail(userID, email.TemplateCoursePurchased, map[string]interface{}{
+		"CourseID":      course.ID,
+		"CourseTitle":   course.Title,
+		"Price":         course.Price,
+		"Balance":       user.Balance,
+		"TransactionID": enrollment.TransactionID,
+	}); err != nil {
+		log.Printf("failed to email user %d a receipt: %v", userID, err)
+	}
+	return user.Balance, enrollment.TransactionID, nil
+}
+
+// GetCoursePrerequisites walks the prerequisite chain of a course breadth-first and reports the
+// user's progress on every course in it. Each course appears once, at its shallowest depth.
+func (s *CourseService) GetCoursePrerequisites(userID, courseID uint) ([]CoursePrerequisiteStatus, error) {
+	chain := []CoursePrerequisiteStatus{}
+	visited := map[uint]bool{courseID: true}
+	frontier := []uint{courseID}
+
+	for depth := 1; len(frontier) > 0; depth++ {
+		var edges []struct {
+			CourseID       uint
+			PrerequisiteID uint
+			Title          string
+		}
+		if err := s.DB.Model(&models.CoursePrerequisite{}).
+			Select("course_prerequisites.course_id, course_prerequisites.prerequisite_id, courses.title").
+			Joins("JOIN courses ON courses.id = course_prerequisites.prerequisite_id AND courses.deleted_at IS NULL").
+			Where("course_prerequisites.course_id IN (?)", frontier).
+			Order("courses.title ASC").
+			Scan(&edges).Error; err != nil {
+			return nil, fmt.Errorf("database error finding prerequisites: %w", err)
+		}
+
+		var next []uint
+		for _, edge := range edges {
+			if visited[edge.PrerequisiteID] {
+				continue
+			}
+			visited[edge.PrerequisiteID] = true
+			next = append(next, edge.PrerequisiteID)
+
+			total, completed, err := countEnrolledCourseProgress(s.DB, userID, edge.PrerequisiteID)
+			if err != nil {
+				return nil, err
+			}
+			progressPercentage := 0.0
+			if total > 0 {
+				progressPercentage = float64(completed) / float64(total) * 100
+			}
+
+			chain = append(chain, CoursePrerequisiteStatus{
+				CourseID:           edge.PrerequisiteID,
+				Title:              edge.Title,
+				RequiredBy:         edge.CourseID,
+				Depth:              depth,
+				Completed:          total > 0 && completed == total,
+				ProgressPercentage: progressPercentage,
+			})
+		}
+		frontier = next
+	}
+
+	return chain, nil
+}
+
+// SetCoursePrerequisites replaces the direct prerequisites of a course. Cycles are rejected.
+func (s *CourseService) SetCoursePrerequisites(courseID uint, prerequisiteIDs []uint) error {
+	var course models.Course
+	if err := s.DB.First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("course not found")
+		}
+		return fmt.Errorf("database error finding course: %w", err)
+	}
+
+	uniqueIDs := make(map[uint]struct{})
+	for _, id := range prerequisiteIDs {
+		if id == courseID {
+			return errors.New("course cannot be its own prerequisite")
+		}
+		uniqueIDs[id] = struct{}{}
+	}
+
+	if len(uniqueIDs) > 0 {
+		var count int64
+		if err := s.DB.Model(&models.Course{}).Where("id IN (?)", prerequisiteIDs).Count(&count).Error; err != nil {
+			return fmt.Errorf("database error verifying prerequisites: %w", err)
+		}
+		if count != int64(len(uniqueIDs)) {
+			return errors.New("some prerequisite course IDs are invalid")
+		}
+	}
+
+	var existing []models.CoursePrerequisite
+	if err := s.DB.Where("course_id <> ?", courseID).Find(&existing).Error; err != nil {
+		return fmt.Errorf("database error loading prerequisites: %w", err)
+	}
+	graph := make(map[uint][]uint)
+	for _, p := range existing {
+		graph[p.CourseID] = append(graph[p.CourseID], p.PrerequisiteID)
+	}
+	for id := range uniqueIDs {
+		graph[courseID] = append(graph[courseID], id)
+	}
+	if hasPrerequisiteCycle(graph, courseID) {
+		return errors.New("prerequisites would create a cycle")
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("course_id = ?", courseID).Delete(&models.CoursePrerequisite{}).Error; err != nil {
+			return fmt.Errorf("failed to clear prerequisites: %w", err)
+		}
+		for id := range uniqueIDs {
+			prerequisite := models.CoursePrerequisite{CourseID: courseID, PrerequisiteID: id}
+			if err := tx.Create(&prerequisite).Error; err != nil {
+				return fmt.Errorf("failed to save prerequisite %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// countCourseProgress returns the number of modules in a course and how many of them the user has completed.
+func countCourseProgress(db *gorm.DB, userID, courseID uint) (int64, int64, error) {
+	var totalModules int64
+	if err := db.Model(&models.Module{}).Where("course_id = ?", courseID).Count(&totalModules).Error; err != nil {
+		return 0, 0, fmt.Errorf("database error counting modules: %w", err)
+	}
+
+	var completedModules int64
+	if err := db.Model(&models.ModuleProgress{}).
+		Joins("JOIN modules ON modules.id = module_progresses.module_id AND modules.deleted_at IS NULL").
+		Where("module_progresses.user_id = ? AND modules.course_id = ? AND module_progresses.is_completed = ?", userID, courseID, true).
+		Count(&completedModules).Error; err != nil {
+		return 0, 0, fmt.Errorf("database error counting completed modules: %w", err)
+	}
+
+	return totalModules, completedModules, nil
+}
+
+// countEnrolledCourseProgress is countCourseProgress for prerequisites: progress only counts once the user is
+// enrolled, so a prerequisite cannot be met with progress recorded while managing the course.
+func countEnrolledCourseProgress(db *gorm.DB, userID, courseID uint) (int64, int64, error) {
+	total, completed, err := countCourseProgress(db, userID, courseID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var enrolled bool
+	if err := db.Model(&models.Enrollment{}).Select("count(*) > 0").Where("user_id = ? AND course_id = ?", userID, courseID).Find(&enrolled).Error; err != nil {
+		return 0, 0, fmt.Errorf("database error finding enrollment: %w", err)
+	}
+	if !enrolled {
+		return total, 0, nil
+	}
+	return total, completed, nil
+}
+
+// ChangeCourseStatus moves a course to another status. Publishing with a future publishAt schedules the
+// publication instead and leaves the current status untouched until the publish job picks it up.
+func (s *CourseService) ChangeCourseStatus(courseID uint, status string, publishAt *time.Time) (*models.Course, error) {
+	var course models.Course
+	if err := s.DB.First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("course not found")
+		}
+		return nil, fmt.Errorf("database error finding course: %w", err)
+	}
+
+	if _, ok := courseStatusTransitions[status]; !ok {
+		return nil, errors.New("invalid course status")
+	}
+
+	allowed := false
+	for _, next := range courseStatusTransitions[course.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w from %s to %s", ErrInvalidStatusTransition, course.Status, status)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"PublishAt": nil}
+	if status == models.CourseStatusPublished && publishAt != nil && publishAt.After(now) {
+		updates["PublishAt"] = *publishAt
+	} else {
+		updates["Status"] = status
+		if status == models.CourseStatusPublished {
+			updates["PublishedAt"] = now
+		}
+	}
+
+	wasPublished := course.Status == models.CourseStatusPublished
+	if err := s.DB.Model(&course).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update course status: %w", err)
+	}
+
+	s.Events.Publish(courseEvent(events.CourseUpdated, course, wasPublished))
+	return &course, nil
+}
+
+// AuthorizeCourse checks that the user may manage the course: admins always, instructors only for courses they teach.
+func (s *CourseService) AuthorizeCourse(userID uint, role string, courseID uint) error {
+	return authorizeCourseManager(s.DB, userID, role, courseID, false)
+}
+
+// GetCourseRevisions lists the revision history of a course, newest first.
+func (s *CourseService) GetCourseRevisions(courseID uint, page, limit int64) (*[]models.Revision, pagination.Pagination, error) {
+	return s.Revisions.GetRevisions(models.RevisionEntityCourse, courseID, page, limit)
+}
+
+// GetCourseRevision retrieves one revision of a course.
+func (s *CourseService) GetCourseRevision(courseID uint, version int) (*models.Revision, error) {
+	return s.Revisions.GetRevision(models.RevisionEntityCourse, courseID, version)
+}
+
+// RestoreCourseRevision puts the content of an earlier revision back on the course, recorded as a new revision.
+func (s *CourseService) RestoreCourseRevision(courseID, actorID uint, version int) (*models.Course, *models.Revision, error) {
+	var course models.Course
+	if err := s.DB.First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("course not found")
+		}
+		return nil, nil, fmt.Errorf("database error finding course: %w", err)
+	}
+
+	revision, err := s.Revisions.GetRevision(models.RevisionEntityCourse, courseID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Snapshot keys are the course's JSON field names, so decode them back into a course.
+	var restored models.Course
+	bytes, err := json.Marshal(revision.Snapshot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read revision: %w", err)
+	}
+	if err := json.Unmarshal(bytes, &restored); err != nil {
+		return nil, nil, fmt.Errorf("failed to read revision: %w", err)
+	}
+
+	before := courseSnapshot(course)
+	updates := map[string]interface{}{
+		"Title":             restored.Title,
+		"Description":       restored.Description,
+		"Instructor":        restored.Instructor,
+		"Topics":            restored.Topics,
+		"Price":             restored.Price,
+		"ThumbnailImage":    restored.ThumbnailImage,
+		"SequentialModules": restored.SequentialModules,
+	}
+
+	var newRevision *models.Revision
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&course).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to restore course: %w", err)
+		}
+		newRevision, err = s.Revisions.Record(tx, models.RevisionEntityCourse, course.ID, actorID, models.RevisionActionRestore, before, courseSnapshot(course), courseAssets(course))
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.Events.Publish(courseEvent(events.CourseUpdated, course, false))
+	return &course, newRevision, nil
+}
+
+// courseEvent describes a change to a course. Changes to published courses, or to courses that were
+// published before the change, are public since they alter the catalog everyone sees.
+func courseEvent(eventType string, course models.Course, wasPublished bool) events.Event {
+	return events.Event{
+		Type:     eventType,
+		CourseID: course.ID,
+		Public:   wasPublished || course.Status == models.CourseStatusPublished,
+		Data:     map[string]interface{}{"title": course.Title, "status": course.Status},
+	}
+}
+
+// courseSnapshot returns the versioned content of a course. Keys match the course's JSON field names.
+func courseSnapshot(course models.Course) map[string]interface{} {
+	return map[string]interface{}{
+		"title":              course.Title,
+		"description":        course.Description,
+		"instructor":         course.Instructor,
+		"topics":             course.Topics,
+		"price":              course.Price,
+		"thumbnail_image":    course.ThumbnailImage,
+		"sequential_modules": course.SequentialModules,
+	}
+}
+
+// courseAssets returns the storage objects a course currently points to.
+func courseAssets(course models.Course) []string {
+	var assets []string
+	if course.ThumbnailImage != "" {
+		assets = append(assets, course.ThumbnailImage)
+	}
+	return assets
 }
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/email"
+
+	"gorm.io/gorm"
+)
+
+// Mailer sends transactional emails to users.
+type Mailer interface {
+	Mail(userID uint, template string, data map[string]interface{}) error
+	// MailTo is Mail sent to another address than the user's, such as the one they are changing it to.
+	MailTo(userID uint, to, template string, data map[string]interface{}) error
+}
+
+// EmailService renders emails and stores them in the outbox, from which the email outbox job delivers them.
+type EmailService struct {
+	DB *gorm.DB
+}
+
+// NewEmailService creates a new EmailService.
+func NewEmailService(db *gorm.DB) *EmailService {
+	return &EmailService{DB: db}
+}
+
+// Mail queues an email for a user in their locale. The user's name and the application's base URL
+// are added to the template data.
+func (s *EmailService) Mail(userID uint, template string, data map[string]interface{}) error {
+	return s.MailTo(userID, "", template, data)
+}
+
+// MailTo queues an email for a user like Mail, sent to the given address instead of the user's own
+// when it is not empty.
+func (s *EmailService) MailTo(userID uint, to, template string, data map[string]interface{}) error {
+	var user models.User
+	if err := s.DB.Select("id", "username", "email", "first_name", "last_name", "locale").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return fmt.Errorf("database error finding user: %w", err)
+	}
+
+	values := map[string]interface{}{
+		"Username":  user.Username,
+		"FirstName": user.FirstName,
+		"LastName":  user.LastName,
+	}
+	for key, value := range data {
+		values[key] = value
+	}
+	if to == "" {
+		to = user.Email
+	}
+	return s.Enqueue(to, user.Locale, template, values)
+}
+
+// Enqueue renders a template and stores it in the outbox for delivery.
+func (s *EmailService) Enqueue(to, locale, template string, data map[string]interface{}) error {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["BaseURL"] = appBaseURL()
+
+	msg, err := email.Render(template, locale, data)
+	if err != nil {
+		return err
+	}
+
+	outboxEmail := models.OutboxEmail{
+		Recipient:     to,
+		Template:      template,
+		Subject:       msg.Subject,
+		TextBody:      msg.Text,
+		HTMLBody:      msg.HTML,
+		NextAttemptAt: time.Now(),
+	}
+	if err := s.DB.Create(&outboxEmail).Error; err != nil {
+		return fmt.Errorf("failed to queue email: %w", err)
+	}
+	return nil
+}
+
+// appBaseURL reads the public URL of the application, used for links in emails, from APP_BASE_URL.
+func appBaseURL() string {
+	if value := os.Getenv("APP_BASE_URL"); value != "" {
+		return strings.TrimRight(value, "/")
+	}
+	port := os.Getenv("APP_PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port
+}
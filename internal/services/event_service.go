@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/events"
+
+	"gorm.io/gorm"
+)
+
+// EventServicer streams the real-time events a user may see.
+type EventServicer interface {
+	Subscribe(userID uint, role string) (<-chan events.Event, func(), error)
+}
+
+type EventService struct {
+	DB  *gorm.DB
+	Bus *events.Bus
+}
+
+func NewEventService(db *gorm.DB, bus *events.Bus) *EventService {
+	return &EventService{DB: db, Bus: bus}
+}
+
+// Subscribe returns the events for a user and a function to stop receiving them. Users receive public events,
+// their own events, and events of the courses they are enrolled in or teach. Admins receive every course event.
+func (s *EventService) Subscribe(userID uint, role string) (<-chan events.Event, func(), error) {
+	courses := make(map[uint]bool)
+	if role != models.UserRoleAdmin {
+		var courseIDs []uint
+		if err := s.DB.Model(&models.Enrollment{}).Where("user_id = ?", userID).Pluck("course_id", &courseIDs).Error; err != nil {
+			return nil, nil, fmt.Errorf("database error finding enrollments: %w", err)
+		}
+		var taughtIDs []uint
+		if err := s.DB.Model(&models.CourseInstructor{}).Where("user_id = ?", userID).Pluck("course_id", &taughtIDs).Error; err != nil {
+			return nil, nil, fmt.Errorf("database error finding taught courses: %w", err)
+		}
+		for _, id := range append(courseIDs, taughtIDs...) {
+			courses[id] = true
+		}
+	}
+
+	received, unsubscribe := s.Bus.Subscribe()
+	filtered := make(chan events.Event, cap(received))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(filtered)
+		for {
+			select {
+			case <-done:
+				return
+			case event := <-received:
+				if event.UserID != 0 {
+					if event.UserID != userID {
+						continue
+					}
+					// Newly bought courses are followed from now on
+					if event.Type == events.CoursePurchased {
+						courses[event.CourseID] = true
+					}
+				} else if !event.Public && role != models.UserRoleAdmin && !courses[event.CourseID] {
+					continue
+				}
+
+				select {
+				case filtered <- event:
+				default:
+					// the client is not keeping up, it misses this event
+				}
+			}
+		}
+	}()
+
+	return filtered, func() {
+		unsubscribe()
+		close(done)
+	}, nil
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+// InstructorServicer defines the operations on course instructors and their scoped views.
+type InstructorServicer interface {
+	AuthorizeCourse(userID uint, role string, courseID uint, ownerOnly bool) error
+	GetCourseInstructors(courseID uint) ([]CourseInstructorInfo, error)
+	AddCourseInstructor(courseID, userID uint, role string) error
+	RemoveCourseInstructor(courseID, userID uint) error
+	GetInstructorCourses(userID uint, page, limit int64, query string) (*[]InstructorCourse, pagination.Pagination, error)
+	GetCourseEnrollments(courseID uint, page, limit int64, query string) (*[]CourseEnrollmentProgress, pagination.Pagination, error)
+}
+
+// ErrNotCourseInstructor is returned when a user tries to manage a course they do not teach.
+var ErrNotCourseInstructor = errors.New("not an instructor of this course")
+
+// CourseInstructorInfo is an instructor of a course as shown to other instructors and admins.
+type CourseInstructorInfo struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// InstructorCourse is a course taught by an instructor along with its enrollment figures.
+type InstructorCourse struct {
+	models.Course
+	InstructorRole            string  `json:"instructor_role"`
+	TotalModules              int64   `json:"total_modules"`
+	EnrollmentCount           int64   `json:"enrollment_count"`
+	AverageProgressPercentage float64 `json:"average_progress_percentage"`
+}
+
+// CourseEnrollmentProgress is a student enrolled in a course and how far they got.
+type CourseEnrollmentProgress struct {
+	UserID             uint      `json:"user_id"`
+	Username           string    `json:"username"`
+	FirstName          string    `json:"first_name"`
+	LastName           string    `json:"last_name"`
+	PurchasedAt        time.Time `json:"purchased_at"`
+	CompletedModules   int64     `json:"completed_modules"`
+	TotalModules       int64     `json:"total_modules"`
+	ProgressPercentage float64   `json:"progress_percentage"`
+}
+
+type InstructorService struct {
+	DB *gorm.DB
+}
+
+func NewInstructorService(db *gorm.DB) *InstructorService {
+	return &InstructorService{DB: db}
+}
+
+// AuthorizeCourse checks that the user may manage the course. Admins manage every course, instructors
+// only the ones they are linked to, and only as owner when ownerOnly is set.
+func (s *InstructorService) AuthorizeCourse(userID uint, role string, courseID uint, ownerOnly bool) error {
+	return authorizeCourseManager(s.DB, userID, role, courseID, ownerOnly)
+}
+
+func (s *InstructorService) GetCourseInstructors(courseID uint) ([]CourseInstructorInfo, error) {
+	instructors := []CourseInstructorInfo{}
+	if err := s.DB.Model(&models.CourseInstructor{}).
+		Select("course_instructors.user_id, users.username, users.first_name, users.last_name, course_instructors.role, course_instructors.created_at").
+		Joins("JOIN users ON users.id = course_instructors.user_id AND users.deleted_at IS NULL").
+		Where("course_instructors.course_id = ?", courseID).
+		Order("course_instructors.role DESC, users.username ASC").
+		Scan(&instructors).Error; err != nil {
+		return nil, fmt.Errorf("database error finding instructors: %w", err)
+	}
+	return instructors, nil
+}
+
+// AddCourseInstructor links an instructor to a course, or changes their role if already linked.
+// Making someone the owner turns the previous owner into a co-instructor.
+func (s *InstructorService) AddCourseInstructor(courseID, userID uint, role string) error {
+	var course models.Course
+	if err := s.DB.First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("course not found")
+		}
+		return fmt.Errorf("database error finding course: %w", err)
+	}
+
+	if err := checkInstructorAccount(s.DB, userID); err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if role == models.CourseInstructorOwner {
+			if err := tx.Model(&models.CourseInstructor{}).
+				Where("course_id = ? AND role = ?", courseID, models.CourseInstructorOwner).
+				Update("role", models.CourseInstructorCoInstructor).Error; err != nil {
+				return fmt.Errorf("failed to transfer ownership: %w", err)
+			}
+		}
+
+		var link models.CourseInstructor
+		err := tx.Where("course_id = ? AND user_id = ?", courseID, userID).First(&link).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			link = models.CourseInstructor{CourseID: courseID, UserID: userID, Role: role}
+			if err := tx.Create(&link).Error; err != nil {
+				return fmt.Errorf("failed to add instructor: %w", err)
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("database error finding instructor: %w", err)
+		}
+
+		if link.Role == models.CourseInstructorOwner && role != models.CourseInstructorOwner {
+			return errors.New("course owner cannot be demoted, transfer ownership instead")
+		}
+		if err := tx.Model(&link).Update("role", role).Error; err != nil {
+			return fmt.Errorf("failed to update instructor: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *InstructorService) RemoveCourseInstructor(courseID, userID uint) error {
+	var link models.CourseInstructor
+	if err := s.DB.Where("course_id = ? AND user_id = ?", courseID, userID).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("instructor not found")
+		}
+		return fmt.Errorf("database error finding instructor: %w", err)
+	}
+
+	if link.Role == models.CourseInstructorOwner {
+		return errors.New("course owner cannot be removed, transfer ownership first")
+	}
+
+	if err := s.DB.Delete(&link).Error; err != nil {
+		return fmt.Errorf("failed to remove instructor: %w", err)
+	}
+	return nil
+}
+
+// GetInstructorCourses lists the courses the user teaches, in any status, with enrollment counts and average progress.
+func (s *InstructorService) GetInstructorCourses(userID uint, page, limit int64, query string) (*[]InstructorCourse, pagination.Pagination, error) {
+	var results []InstructorCourse
+
+	dbQuery := s.DB.Model(&models.Course{}).
+		Select(`courses.*, course_instructors.role AS instructor_role,
+			(SELECT count(*) FROM modules WHERE modules.course_id = courses.id AND modules.deleted_at IS NULL) AS total_modules,
+			(SELECT count(*) FROM enrollments WHERE enrollments.course_id = courses.id AND enrollments.deleted_at IS NULL) AS enrollment_count`).
+		Joins("JOIN course_instructors ON course_instructors.course_id = courses.id").
+		Where("course_instructors.user_id = ?", userID).
+		Order("courses.updated_at DESC")
+
+	searchableColumns := []string{"courses.title", "courses.instructor", "courses.topics"}
+	_, pagination, err := pagination.Paginate(dbQuery, &results, page, limit, searchableColumns, query)
+	if err != nil {
+		return nil, pagination, fmt.Errorf("failed to paginate instructor's courses: %w", err)
+	}
+
+	for i := range results {
+		if results[i].TotalModules == 0 || results[i].EnrollmentCount == 0 {
+			continue
+		}
+
+		var completedModules int64
+		if err := s.DB.Model(&models.ModuleProgress{}).
+			Joins("JOIN modules ON modules.id = module_progresses.module_id AND modules.deleted_at IS NULL").
+			Joins("JOIN enrollments ON enrollments.user_id = module_progresses.user_id AND enrollments.course_id = modules.course_id AND enrollments.deleted_at IS NULL").
+			Where("modules.course_id = ? AND module_progresses.is_completed = ?", results[i].ID, true).
+			Count(&completedModules).Error; err != nil {
+			return nil, pagination, fmt.Errorf("database error counting completed modules: %w", err)
+		}
+
+		results[i].AverageProgressPercentage = float64(completedModules) / float64(results[i].TotalModules*results[i].EnrollmentCount) * 100
+	}
+
+	return &results, pagination, nil
+}
+
+// GetCourseEnrollments lists the students enrolled in a course with their progress.
+func (s *InstructorService) GetCourseEnrollments(courseID uint, page, limit int64, query string) (*[]CourseEnrollmentProgress, pagination.Pagination, error) {
+	var results []CourseEnrollmentProgress
+
+	dbQuery := s.DB.Model(&models.Enrollment{}).
+		Select(`enrollments.user_id, users.username, users.first_name, users.last_name, enrollments.purchased_at,
+			(SELECT count(*) FROM module_progresses
+				JOIN modules ON modules.id = module_progresses.module_id AND modules.deleted_at IS NULL
+				WHERE module_progresses.user_id = enrollments.user_id AND modules.course_id = enrollments.course_id
+				AND module_progresses.is_completed) AS completed_modules`).
+		Joins("JOIN users ON users.id = enrollments.user_id AND users.deleted_at IS NULL").
+		Where("enrollments.course_id = ?", courseID).
+		Order("enrollments.purchased_at DESC")
+
+	searchableColumns := []string{"users.username", "users.first_name", "users.last_name"}
+	_, pagination, err := pagination.Paginate(dbQuery, &results, page, limit, searchableColumns, query)
+	if err != nil {
+		return nil, pagination, fmt.Errorf("failed to paginate enrollments: %w", err)
+	}
+
+	var totalModules int64
+	if err := s.DB.Model(&models.Module{}).Where("course_id = ?", courseID).Count(&totalModules).Error; err != nil {
+		return nil, pagination, fmt.Errorf("database error counting modules: %w", err)
+	}
+
+	for i := range results {
+		results[i].TotalModules = totalModules
+		if totalModules > 0 {
+			results[i].ProgressPercentage = float64(results[i].CompletedModules) / float64(totalModules) * 100
+		}
+	}
+
+	return &results, pagination, nil
+}
+
+// authorizeCourseManager checks that the user may manage the course. Admins manage every course,
+// instructors only the ones they are linked to, and only as owner when ownerOnly is set.
+func authorizeCourseManager(db *gorm.DB, userID uint, role string, courseID uint, ownerOnly bool) error {
+	if role == models.UserRoleAdmin {
+		return nil
+	}
+	if role != models.UserRoleInstructor {
+		return ErrNotCourseInstructor
+	}
+
+	query := db.Model(&models.CourseInstructor{}).Where("course_id = ? AND user_id = ?", courseID, userID)
+	if ownerOnly {
+		query = query.Where("role = ?", models.CourseInstructorOwner)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("database error checking course instructors: %w", err)
+	}
+	if count == 0 {
+		return ErrNotCourseInstructor
+	}
+	return nil
+}
+
+// checkInstructorAccount verifies that the user exists and has the instructor role.
+func checkInstructorAccount(db *gorm.DB, userID uint) error {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return fmt.Errorf("database error finding user: %w", err)
+	}
+	if user.Role != models.UserRoleInstructor {
+		return errors.New("user is not an instructor")
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"grocademy/internal/db/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginThrottlePolicy decides how failed logins slow down further attempts.
+type loginThrottlePolicy struct {
+	FreeAttempts    int           // Failures allowed before each attempt has to wait
+	LockoutAttempts int           // Failures that lock out further attempts
+	LockoutDuration time.Duration // Also caps the wait between attempts
+	ResetAfter      time.Duration // Failures are forgotten after this long without another one
+}
+
+var (
+	// accountLoginPolicy throttles guessing the password of one account.
+	accountLoginPolicy = loginThrottlePolicy{FreeAttempts: 3, LockoutAttempts: 10, LockoutDuration: 15 * time.Minute, ResetAfter: time.Hour}
+	// ipLoginPolicy throttles one client guessing the passwords of many accounts.
+	ipLoginPolicy = loginThrottlePolicy{FreeAttempts: 20, LockoutAttempts: 100, LockoutDuration: time.Hour, ResetAfter: time.Hour}
+	// resetRequestPolicy limits password reset emails to 3 every 15 minutes. Every request counts, not only failed ones.
+	resetRequestPolicy = loginThrottlePolicy{FreeAttempts: 3, LockoutAttempts: 3, LockoutDuration: 15 * time.Minute, ResetAfter: 15 * time.Minute}
+	// verifyResendPolicy limits verification emails a user asks for to 3 every hour.
+	verifyResendPolicy = loginThrottlePolicy{FreeAttempts: 3, LockoutAttempts: 3, LockoutDuration: time.Hour, ResetAfter: time.Hour}
+	// mfaCodePolicy throttles guessing the authenticator and recovery codes of one user, 5 guesses every 5 minutes.
+	mfaCodePolicy = loginThrottlePolicy{FreeAttempts: 5, LockoutAttempts: 5, LockoutDuration: 5 * time.Minute, ResetAfter: 5 * time.Minute}
+)
+
+// delay is how long to wait after the last of the given number of failures. It doubles with every failure
+// past the free attempts.
+func (p loginThrottlePolicy) delay(failures int) time.Duration {
+	if failures < p.FreeAttempts {
+		return 0
+	}
+	if shift := failures - p.FreeAttempts; shift < 30 {
+		if delay := time.Second << shift; delay < p.LockoutDuration {
+			return delay
+		}
+	}
+	return p.LockoutDuration
+}
+
+// identifierLoginThrottleKey is the throttle key of the identifier a login was submitted with. Whether or not
+// it matches an account, it is throttled the same way, so throttling does not reveal whether an account exists.
+func identifierLoginThrottleKey(identifier string) string {
+	return "login:" + strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// userLoginThrottleKey is the throttle key of an account, shared by logins with its username and its email
+// address so that both together get no more guesses than one.
+func userLoginThrottleKey(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// accountLoginThrottleKeys are every throttle key that failed logins of a user count against.
+func accountLoginThrottleKeys(user *models.User) []string {
+	return []string{userLoginThrottleKey(user.ID), identifierLoginThrottleKey(user.Username), identifierLoginThrottleKey(user.Email)}
+}
+
+func ipLoginThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// mfaThrottleKey is the throttle key of the second factor of a user.
+func mfaThrottleKey(userID uint) string {
+	return fmt.Sprintf("mfa:%d", userID)
+}
+
+// retryAfter is how long the throttled key has to wait at the given time before its next login attempt.
+func (p loginThrottlePolicy) retryAfter(throttle models.LoginThrottle, now time.Time) time.Duration {
+	if now.Sub(throttle.LastFailedAt) >= p.ResetAfter {
+		return 0
+	}
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now)
+	}
+	return max(throttle.LastFailedAt.Add(p.delay(throttle.FailedAttempts)).Sub(now), 0)
+}
+
+// fail counts a failed login at the given time, after forgetting failures older than ResetAfter, and locks
+// the key out once it reaches LockoutAttempts. It reports whether this failure locked the key out.
+func (p loginThrottlePolicy) fail(throttle *models.LoginThrottle, now time.Time) bool {
+	if now.Sub(throttle.LastFailedAt) >= p.ResetAfter {
+		throttle.FailedAttempts = 0
+		throttle.LockedUntil = nil
+	}
+	throttle.FailedAttempts++
+	throttle.LastFailedAt = now
+	if throttle.FailedAttempts < p.LockoutAttempts {
+		return false
+	}
+	lockedUntil := now.Add(p.LockoutDuration)
+	throttle.LockedUntil = &lockedUntil
+	return throttle.FailedAttempts == p.LockoutAttempts
+}
+
+// reserveLoginAttempt counts a login attempt of the key as failed before its password is checked, so that
+// concurrent attempts cannot all get past the throttle. If the key has to wait it returns a RateLimitError
+// without counting the attempt. Otherwise it returns the number of recent failures including this attempt
+// and whether the attempt locked the key out. Attempts that succeed give their reservation back with
+// releaseLoginAttempt or clearLoginThrottle.
+func reserveLoginAttempt(db *gorm.DB, key string, policy loginThrottlePolicy) (int, bool, error) {
+	now := time.Now()
+
+	var throttle models.LoginThrottle
+	var locked bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Make sure there is a row to lock, one whose failures were forgotten long ago
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginThrottle{Key: key, LastFailedAt: now.Add(-policy.ResetAfter)}).Error; err != nil {
+			return fmt.Errorf("failed to reserve login attempt: %w", err)
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("throttle_key = ?", key).First(&throttle).Error; err != nil {
+			return fmt.Errorf("database error checking failed logins: %w", err)
+		}
+
+		if wait := policy.retryAfter(throttle, now); wait > 0 {
+			return &RateLimitError{RetryAfter: wait}
+		}
+		locked = policy.fail(&throttle, now)
+		if err := tx.Save(&throttle).Error; err != nil {
+			return fmt.Errorf("failed to reserve login attempt: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return throttle.FailedAttempts, locked, nil
+}
+
+// releaseLoginAttempt gives back the attempt reserved by reserveLoginAttempt, lifting the lockout it may
+// have caused.
+func releaseLoginAttempt(db *gorm.DB, key string, policy loginThrottlePolicy) error {
+	if err := db.Model(&models.LoginThrottle{}).Where("throttle_key = ? AND failed_attempts > 0", key).
+		Updates(map[string]interface{}{
+			"FailedAttempts": gorm.Expr("failed_attempts - 1"),
+			"LockedUntil":    gorm.Expr("CASE WHEN failed_attempts > ? THEN locked_until END", policy.LockoutAttempts),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+	return nil
+}
+
+// clearLoginThrottle forgets the failed logins of the keys and lifts their lockouts.
+func clearLoginThrottle(db *gorm.DB, keys ...string) error {
+	if err := db.Where("throttle_key IN ?", keys).Delete(&models.LoginThrottle{}).Error; err != nil {
+		return fmt.Errorf("failed to clear failed logins: %w", err)
+	}
+	return nil
+}
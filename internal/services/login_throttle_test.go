@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"grocademy/internal/auth"
+	"grocademy/internal/db/dbtest"
+	"grocademy/internal/db/models"
+)
+
+func TestLoginThrottleDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{12, 512 * time.Second},
+		{13, 15 * time.Minute}, // 1024s is past the lockout duration
+		{100, 15 * time.Minute},
+		{1 << 20, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := accountLoginPolicy.delay(tt.failures); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottleFail(t *testing.T) {
+	now := time.Now()
+	policy := accountLoginPolicy
+
+	tests := []struct {
+		name         string
+		throttle     models.LoginThrottle
+		wantFailures int
+		wantLocked   bool // Whether this failure locked the key out
+		wantLockout  bool
+	}{
+		{
+			name:         "first failure",
+			throttle:     models.LoginThrottle{LastFailedAt: now.Add(-policy.ResetAfter)},
+			wantFailures: 1,
+		},
+		{
+			name:         "recent failures add up",
+			throttle:     models.LoginThrottle{FailedAttempts: 5, LastFailedAt: now.Add(-time.Minute)},
+			wantFailures: 6,
+		},
+		{
+			name:         "failure reaching the limit locks out",
+			throttle:     models.LoginThrottle{FailedAttempts: policy.LockoutAttempts - 1, LastFailedAt: now.Add(-time.Minute)},
+			wantFailures: policy.LockoutAttempts,
+			wantLocked:   true,
+			wantLockout:  true,
+		},
+		{
+			name:         "failure past the limit stays locked out",
+			throttle:     models.LoginThrottle{FailedAttempts: policy.LockoutAttempts, LastFailedAt: now.Add(-time.Minute)},
+			wantFailures: policy.LockoutAttempts + 1,
+			wantLockout:  true,
+		},
+		{
+			name: "old failures are forgotten",
+			throttle: models.LoginThrottle{
+				FailedAttempts: policy.LockoutAttempts,
+				LastFailedAt:   now.Add(-policy.ResetAfter),
+				LockedUntil:    ptr(now.Add(-policy.ResetAfter + policy.LockoutDuration)),
+			},
+			wantFailures: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := tt.throttle
+			locked := policy.fail(&throttle, now)
+			if throttle.FailedAttempts != tt.wantFailures || locked != tt.wantLocked {
+				t.Errorf("fail = %d failures, locked %v, want %d failures, locked %v", throttle.FailedAttempts, locked, tt.wantFailures, tt.wantLocked)
+			}
+			if !throttle.LastFailedAt.Equal(now) {
+				t.Errorf("last failure at %v, want %v", throttle.LastFailedAt, now)
+			}
+			if lockout := throttle.LockedUntil != nil; lockout != tt.wantLockout {
+				t.Errorf("locked out = %v, want %v", lockout, tt.wantLockout)
+			} else if lockout && !throttle.LockedUntil.Equal(now.Add(policy.LockoutDuration)) {
+				t.Errorf("locked until %v, want %v", throttle.LockedUntil, now.Add(policy.LockoutDuration))
+			}
+		})
+	}
+}
+
+func TestLoginThrottleRetryAfter(t *testing.T) {
+	now := time.Now()
+	policy := accountLoginPolicy
+
+	tests := []struct {
+		name     string
+		throttle models.LoginThrottle
+		want     time.Duration
+	}{
+		{"free attempts", models.LoginThrottle{FailedAttempts: 2, LastFailedAt: now}, 0},
+		{"waiting for the delay", models.LoginThrottle{FailedAttempts: 4, LastFailedAt: now.Add(-time.Second)}, time.Second},
+		{"delay passed", models.LoginThrottle{FailedAttempts: 4, LastFailedAt: now.Add(-2 * time.Second)}, 0},
+		{
+			"locked out",
+			models.LoginThrottle{FailedAttempts: 10, LastFailedAt: now.Add(-time.Minute), LockedUntil: ptr(now.Add(14 * time.Minute))},
+			14 * time.Minute,
+		},
+		{
+			"lockout over",
+			models.LoginThrottle{FailedAttempts: 10, LastFailedAt: now.Add(-16 * time.Minute), LockedUntil: ptr(now.Add(-time.Minute))},
+			0,
+		},
+		{
+			"failures forgotten",
+			models.LoginThrottle{FailedAttempts: 100, LastFailedAt: now.Add(-policy.ResetAfter), LockedUntil: ptr(now.Add(time.Minute))},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.retryAfter(tt.throttle, now); got != tt.want {
+				t.Errorf("retryAfter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginUserThrottle(t *testing.T) {
+	if err := auth.Init(); err != nil {
+		t.Fatal(err)
+	}
+	db := dbtest.Open(t, &models.User{}, &models.LoginThrottle{}, &models.OutboxEmail{})
+	s := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+	t.Setenv("MFA_REQUIRED_ROLES", "none")
+	user := createTestUser(t, db, models.User{Username: "ada", Email: "ada@example.com", FirstName: "Ada", EmailVerifiedAt: ptr(time.Now())})
+
+	// skipDelay moves the last failures of the identifier and of its account back, as if the client waited
+	// out the delay
+	skipDelay := func(identifier string) {
+		t.Helper()
+		keys := []string{identifierLoginThrottleKey(identifier), userLoginThrottleKey(user.ID)}
+		if err := db.Model(&models.LoginThrottle{}).Where("throttle_key IN ?", keys).
+			Update("LastFailedAt", time.Now().Add(-accountLoginPolicy.LockoutDuration)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each identifier is guessed from its own client, the client throttle is tested separately
+	clients := map[string]string{"ada": "192.0.2.1", "nobody": "192.0.2.2"}
+	login := func(identifier, password string) error {
+		_, err := s.LoginUser(identifier, password, "", clients[strings.ToLower(strings.TrimSpace(identifier))])
+		return err
+	}
+
+	for _, identifier := range []string{"ada", "nobody"} {
+		t.Run("wrong passwords for "+identifier, func(t *testing.T) {
+			for attempt := 1; attempt <= accountLoginPolicy.FreeAttempts; attempt++ {
+				if err := login(identifier, "wrong"); err == nil || err.Error() != "invalid credentials" {
+					t.Fatalf("attempt %d error = %v, want invalid credentials", attempt, err)
+				}
+			}
+			var rateLimitErr *RateLimitError
+			if err := login(identifier, "Str0ng!Passw0rd"); !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter > time.Second {
+				t.Fatalf("attempt after the free attempts error = %v, want to wait a second", err)
+			}
+
+			for attempt := accountLoginPolicy.FreeAttempts + 1; attempt <= accountLoginPolicy.LockoutAttempts; attempt++ {
+				skipDelay(identifier)
+				if err := login(identifier, "wrong"); err == nil || err.Error() != "invalid credentials" {
+					t.Fatalf("attempt %d error = %v, want invalid credentials", attempt, err)
+				}
+			}
+			skipDelay(identifier)
+			if err := login(identifier, "Str0ng!Passw0rd"); !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter < 14*time.Minute {
+				t.Fatalf("attempt after the lockout error = %v, want to wait out the lockout", err)
+			}
+		})
+	}
+
+	t.Run("identifier case and spacing", func(t *testing.T) {
+		var rateLimitErr *RateLimitError
+		if err := login("  ADA ", "Str0ng!Passw0rd"); !errors.As(err, &rateLimitErr) {
+			t.Errorf("login with the identifier spelled differently error = %v, want it to share the lockout", err)
+		}
+	})
+
+	t.Run("other identifier of the account", func(t *testing.T) {
+		clients["ada@example.com"] = clients["ada"]
+		var rateLimitErr *RateLimitError
+		if err := login("ada@example.com", "Str0ng!Passw0rd"); !errors.As(err, &rateLimitErr) {
+			t.Errorf("login with the email address error = %v, want it to share the lockout of the account", err)
+		}
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		users := &UserService{DB: db}
+		if err := users.UnlockUser(user.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := login("ada", "Str0ng!Passw0rd"); err != nil {
+			t.Errorf("login after unlocking error = %v", err)
+		}
+		var throttles int64
+		db.Model(&models.LoginThrottle{}).Where("throttle_key IN ?", accountLoginThrottleKeys(&user)).Count(&throttles)
+		if throttles != 0 {
+			t.Errorf("%d throttles of the account are left after signing in", throttles)
+		}
+	})
+
+	t.Run("successful logins do not count against the client", func(t *testing.T) {
+		var ipThrottle models.LoginThrottle
+		db.Where("throttle_key = ?", ipLoginThrottleKey(clients["ada"])).First(&ipThrottle)
+		// The identifier failed up to the lockout, every other attempt succeeded or was throttled
+		if ipThrottle.FailedAttempts != accountLoginPolicy.LockoutAttempts {
+			t.Errorf("client has %d failures, want %d", ipThrottle.FailedAttempts, accountLoginPolicy.LockoutAttempts)
+		}
+	})
+}
+
+func TestLoginUserThrottlesAccount(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.LoginThrottle{}, &models.OutboxEmail{})
+	s := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+	user := createTestUser(t, db, models.User{Username: "ada", Email: "ada@example.com", FirstName: "Ada", EmailVerifiedAt: ptr(time.Now())})
+
+	// Alternate between the username and the email address, so neither identifier reaches the lockout
+	identifiers := []string{"ada", "ada@example.com"}
+	// skipDelays moves the last failures of the identifiers and the account back, as if the client waited
+	// out the delay
+	skipDelays := func() {
+		t.Helper()
+		if err := db.Model(&models.LoginThrottle{}).Where("throttle_key NOT LIKE ?", "ip:%").
+			Update("LastFailedAt", time.Now().Add(-accountLoginPolicy.LockoutDuration)).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for attempt := 1; attempt <= accountLoginPolicy.LockoutAttempts; attempt++ {
+		skipDelays()
+		if _, err := s.LoginUser(identifiers[attempt%2], "wrong", "", "192.0.2.1"); err == nil || err.Error() != "invalid credentials" {
+			t.Fatalf("attempt %d error = %v, want invalid credentials", attempt, err)
+		}
+	}
+
+	skipDelays()
+	for _, identifier := range identifiers {
+		var rateLimitErr *RateLimitError
+		if _, err := s.LoginUser(identifier, "Str0ng!Passw0rd", "", "192.0.2.1"); !errors.As(err, &rateLimitErr) || rateLimitErr.RetryAfter < 14*time.Minute {
+			t.Errorf("login with %q after the lockout error = %v, want to wait out the lockout", identifier, err)
+		}
+	}
+
+	var identifierThrottle, accountThrottle models.LoginThrottle
+	db.Where("throttle_key = ?", identifierLoginThrottleKey("ada")).First(&identifierThrottle)
+	if identifierThrottle.FailedAttempts != accountLoginPolicy.LockoutAttempts/2 {
+		t.Errorf("username has %d failures, want %d", identifierThrottle.FailedAttempts, accountLoginPolicy.LockoutAttempts/2)
+	}
+	db.Where("throttle_key = ?", userLoginThrottleKey(user.ID)).First(&accountThrottle)
+	if accountThrottle.FailedAttempts != accountLoginPolicy.LockoutAttempts {
+		t.Errorf("account has %d failures, want %d", accountThrottle.FailedAttempts, accountLoginPolicy.LockoutAttempts)
+	}
+}
+
+func TestLoginUserThrottlesClient(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.LoginThrottle{}, &models.OutboxEmail{})
+	s := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+
+	// Guess one password of many accounts, staying under the throttle of each identifier
+	for attempt := 1; attempt <= ipLoginPolicy.FreeAttempts; attempt++ {
+		if _, err := s.LoginUser(fmt.Sprintf("user%d", attempt), "wrong", "", "192.0.2.1"); err == nil || err.Error() != "invalid credentials" {
+			t.Fatalf("attempt %d error = %v, want invalid credentials", attempt, err)
+		}
+	}
+
+	var rateLimitErr *RateLimitError
+	if _, err := s.LoginUser("another", "wrong", "", "192.0.2.1"); !errors.As(err, &rateLimitErr) {
+		t.Fatalf("attempt after the free attempts of the client error = %v, want to wait", err)
+	}
+	if _, err := s.LoginUser("another", "wrong", "", "192.0.2.2"); err == nil || err.Error() != "invalid credentials" {
+		t.Errorf("attempt from another client error = %v, want invalid credentials", err)
+	}
+
+	// The identifier was not tried from the throttled client, so it does not count against it
+	var throttle models.LoginThrottle
+	db.Where("throttle_key = ?", identifierLoginThrottleKey("another")).First(&throttle)
+	if throttle.FailedAttempts != 1 {
+		t.Errorf("identifier has %d failures, want 1", throttle.FailedAttempts)
+	}
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"grocademy/internal/auth"
+	"grocademy/internal/db/models"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer = "Grocademy"
+	// totpPeriod is the RFC 6238 time step.
+	totpPeriod = 30
+	// recoveryCodeCount is how many recovery codes a user gets at a time.
+	recoveryCodeCount = 10
+)
+
+// totpOpts are the authenticator settings every common app supports.
+var totpOpts = totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+
+// MFAServicer defines the operations on users' two-factor authentication.
+type MFAServicer interface {
+	BeginTOTPSetup(userID uint) (*TOTPSetup, error)
+	EnableTOTP(userID uint, code string) ([]string, error)
+	DisableTOTP(userID uint, password, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	VerifySecondFactor(userID uint, code string) error
+	IsMFARequired(role string) bool
+}
+
+// TOTPSetup is what an authenticator app needs to be linked to an account.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URL    string `json:"otpauth_url"`
+	QRCode string `json:"qr_code"` // PNG data URI of URL
+}
+
+var (
+	ErrMFAAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrMFASetupNotStarted = errors.New("two-factor authentication setup was not started")
+	ErrInvalidMFACode     = errors.New("invalid authentication code")
+	ErrMFARequired        = errors.New("two-factor authentication is required for your role")
+)
+
+type MFAService struct {
+	DB            *gorm.DB
+	RequiredRoles []string
+}
+
+func NewMFAService(db *gorm.DB) *MFAService {
+	return &MFAService{DB: db, RequiredRoles: mfaRequiredRoles()}
+}
+
+// IsMFARequired reports whether users of the role must use two-factor authentication.
+func (s *MFAService) IsMFARequired(role string) bool {
+	return slices.Contains(s.RequiredRoles, role)
+}
+
+// BeginTOTPSetup generates a new authenticator secret for the user. It is only used once confirmed with EnableTOTP.
+func (s *MFAService) BeginTOTPSetup(userID uint) (*TOTPSetup, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Email,
+		Period:      totpOpts.Period,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate authenticator secret: %w", err)
+	}
+
+	image, err := key.Image(240, 240)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	var qrCode bytes.Buffer
+	if err := png.Encode(&qrCode, image); err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	if err := s.DB.Model(user).Updates(map[string]interface{}{"TOTPSecret": key.Secret(), "TOTPLastStep": 0}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save authenticator secret: %w", err)
+	}
+
+	return &TOTPSetup{
+		Secret: key.Secret(),
+		URL:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode.Bytes()),
+	}, nil
+}
+
+// EnableTOTP turns on two-factor authentication once the user proves their authenticator works,
+// and returns their recovery codes.
+func (s *MFAService) EnableTOTP(userID uint, code string) ([]string, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFASetupNotStarted
+	}
+	if err := s.throttleCode(user.ID, func() error { return s.checkTOTP(user, code) }); err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Model(user).Update("TOTPEnabledAt", time.Now()).Error; err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	return s.replaceRecoveryCodes(user.ID)
+}
+
+// DisableTOTP turns off two-factor authentication, unless the user's role requires it.
+func (s *MFAService) DisableTOTP(userID uint, password, code string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+	if s.IsMFARequired(user.Role) {
+		return ErrMFARequired
+	}
+	if !auth.CheckPasswordHash(password, user.Password) {
+		return errors.New("current password is incorrect")
+	}
+	if err := s.verify(user, code); err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"TOTPSecret":    "",
+			"TOTPEnabledAt": nil,
+			"TOTPLastStep":  0,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		return nil
+	})
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes with new ones.
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.throttleCode(user.ID, func() error { return s.checkTOTP(user, code) }); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(user.ID)
+}
+
+// VerifySecondFactor checks an authenticator code or an unused recovery code of the user.
+func (s *MFAService) VerifySecondFactor(userID uint, code string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+	return s.verify(user, code)
+}
+
+func (s *MFAService) findUser(userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("database error finding user: %w", err)
+	}
+	return &user, nil
+}
+
+// verify accepts an authenticator code or, failing that, a recovery code.
+func (s *MFAService) verify(user *models.User, code string) error {
+	return s.throttleCode(user.ID, func() error {
+		err := s.checkTOTP(user, code)
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return err
+		}
+		return s.useRecoveryCode(user.ID, code)
+	})
+}
+
+// throttleCode runs check on a code the user entered. Wrong codes count against the user's second factor
+// throttle in the database, so every replica shares it; a right code forgets the wrong ones.
+func (s *MFAService) throttleCode(userID uint, check func() error) error {
+	key := mfaThrottleKey(userID)
+	if _, _, err := reserveLoginAttempt(s.DB, key, mfaCodePolicy); err != nil {
+		return err
+	}
+
+	err := check()
+	switch {
+	case err == nil:
+		if err := clearLoginThrottle(s.DB, key); err != nil {
+			log.Print(err)
+		}
+	case !errors.Is(err, ErrInvalidMFACode):
+		// The code was never checked, so it does not count as a guess
+		if err := releaseLoginAttempt(s.DB, key, mfaCodePolicy); err != nil {
+			log.Print(err)
+		}
+	}
+	return err
+}
+
+// checkTOTP accepts a code of the current time step or an adjacent one, for clock drift.
+// A code is accepted once; later codes of the same or earlier steps are rejected.
+func (s *MFAService) checkTOTP(user *models.User, code string) error {
+	code = strings.TrimSpace(code)
+	now := time.Now()
+	for _, skew := range []int64{-1, 0, 1} {
+		at := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+		step := at.Unix() / totpPeriod
+		if step <= user.TOTPLastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(user.TOTPSecret, at, totpOpts)
+		if err != nil {
+			return fmt.Errorf("failed to generate authenticator code: %w", err)
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+			continue
+		}
+
+		// The condition makes concurrent uses of the same code race for a single row update
+		result := s.DB.Model(user).Where("totp_last_step < ?", step).Update("TOTPLastStep", step)
+		if result.Error != nil {
+			return fmt.Errorf("failed to record authenticator code: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+	return ErrInvalidMFACode
+}
+
+// useRecoveryCode marks a matching unused recovery code of the user as used.
+func (s *MFAService) useRecoveryCode(userID uint, code string) error {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return ErrInvalidMFACode
+	}
+
+	var codes []models.RecoveryCode
+	if err := s.DB.Where("user_id = ? AND used_at IS NULL", userID).Find(&codes).Error; err != nil {
+		return fmt.Errorf("database error finding recovery codes: %w", err)
+	}
+	for _, recoveryCode := range codes {
+		if !auth.CheckPasswordHash(code, recoveryCode.CodeHash) {
+			continue
+		}
+		result := s.DB.Model(&recoveryCode).Where("used_at IS NULL").Update("UsedAt", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("failed to use recovery code: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+	return ErrInvalidMFACode
+}
+
+// replaceRecoveryCodes gives the user a new set of recovery codes, invalidating the old ones.
+func (s *MFAService) replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		random := make([]byte, 5)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(random))
+		hash, err := auth.HashPassword(code)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:4] + "-" + code[4:]
+		rows[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return fmt.Errorf("failed to save recovery codes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode drops the separators users may type, so "ABCD EFGH" matches "abcd-efgh".
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// mfaRequiredRoles reads the roles that must use two-factor authentication from MFA_REQUIRED_ROLES,
+// a comma-separated list of roles or "none". Defaults to admins.
+func mfaRequiredRoles() []string {
+	value, ok := os.LookupEnv("MFA_REQUIRED_ROLES")
+	if !ok || strings.TrimSpace(value) == "" {
+		return []string{models.UserRoleAdmin}
+	}
+	if strings.TrimSpace(value) == "none" {
+		return nil
+	}
+
+	var roles []string
+	for _, role := range strings.Split(value, ",") {
+		role = strings.TrimSpace(role)
+		switch role {
+		case models.UserRoleStudent, models.UserRoleInstructor, models.UserRoleAdmin:
+			roles = append(roles, role)
+		default:
+			log.Printf("WARNING: unknown role %q in MFA_REQUIRED_ROLES, ignoring it", role)
+		}
+	}
+	return roles
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"grocademy/internal/auth"
+	"grocademy/internal/db/dbtest"
+	"grocademy/internal/db/models"
+
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+// createTOTPUser creates a user who signs in with the authenticator secret "JBSWY3DPEHPK3PXP".
+func createTOTPUser(t *testing.T, db *gorm.DB) models.User {
+	t.Helper()
+	return createTestUser(t, db, models.User{
+		Username:        "ada",
+		Email:           "ada@example.com",
+		FirstName:       "Ada",
+		EmailVerifiedAt: ptr(time.Now()),
+		TOTPSecret:      "JBSWY3DPEHPK3PXP",
+		TOTPEnabledAt:   ptr(time.Now()),
+	})
+}
+
+func TestVerifySecondFactorThrottle(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.RecoveryCode{}, &models.LoginThrottle{})
+	user := createTOTPUser(t, db)
+	// Each replica has its own service, the throttle is shared through the database
+	replicas := []*MFAService{NewMFAService(db), NewMFAService(db)}
+
+	for attempt := 1; attempt <= mfaCodePolicy.LockoutAttempts; attempt++ {
+		if err := replicas[attempt%2].VerifySecondFactor(user.ID, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d error = %v, want %v", attempt, err, ErrInvalidMFACode)
+		}
+	}
+
+	code, err := totp.GenerateCodeCustom(user.TOTPSecret, time.Now(), totpOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rateLimitErr *RateLimitError
+	for i, replica := range replicas {
+		if err := replica.VerifySecondFactor(user.ID, code); !errors.As(err, &rateLimitErr) {
+			t.Errorf("replica %d error = %v, want to wait", i, err)
+		}
+	}
+
+	if err := db.Model(&models.LoginThrottle{}).Where("throttle_key = ?", mfaThrottleKey(user.ID)).
+		Update("LastFailedAt", time.Now().Add(-mfaCodePolicy.ResetAfter)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := replicas[0].VerifySecondFactor(user.ID, code); err != nil {
+		t.Fatalf("right code after the lockout error = %v", err)
+	}
+	var throttles int64
+	db.Model(&models.LoginThrottle{}).Where("throttle_key = ?", mfaThrottleKey(user.ID)).Count(&throttles)
+	if throttles != 0 {
+		t.Error("right code did not forget the wrong ones")
+	}
+}
+
+func TestCompleteLoginRejectsRevokedChallenge(t *testing.T) {
+	if err := auth.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MFA_REQUIRED_ROLES", "none")
+	db := dbtest.Open(t, &models.User{}, &models.RecoveryCode{}, &models.LoginThrottle{}, &models.OutboxEmail{})
+	s := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+	user := createTOTPUser(t, db)
+
+	result, err := s.LoginUser("ada", "Str0ng!Passw0rd", "", "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ChallengeToken == "" {
+		t.Fatal("login did not ask for the second factor")
+	}
+
+	// As a password reset does
+	if err := db.Model(&user).Update("SessionVersion", revokeSessions).Error; err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.GenerateCodeCustom(user.TOTPSecret, time.Now(), totpOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompleteLogin(result.ChallengeToken, code); err == nil || err.Error() != "invalid or expired challenge token" {
+		t.Errorf("CompleteLogin error = %v, want the challenge to be rejected", err)
+	}
+}
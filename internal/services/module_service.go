@@ -1,16 +1,21 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"grocademy/internal/db/models"
+	"grocademy/internal/email"
+	"grocademy/internal/events"
 	"grocademy/internal/pkg/pagination"
 	"grocademy/internal/storage"
 
@@ -19,29 +24,75 @@ import (
 
 // ModuleServicer defines the interface for module-related operations.
 type ModuleServicer interface {
-	CreateModule(courseID uint, title, description string, pdf *multipart.FileHeader, video *multipart.FileHeader) (*models.Module, error)
-	GetModuleByID(id uint, userID uint) (*models.Module, bool, error)
-	GetAllModulesByCourseID(courseID uint, userID uint, page, limit int64) (*[]models.Module, *map[uint]bool, pagination.Pagination, error)
-	UpdateModule(id uint, updates map[string]interface{}, pdf *multipart.FileHeader, video *multipart.FileHeader) (*models.Module, error)
+	CreateModule(courseID, actorID uint, title, description string, release ModuleReleaseRule, pdf *multipart.FileHeader, video *multipart.FileHeader) (*models.Module, error)
+	GetModuleByID(id uint, userID uint, canManage bool) (*models.Module, bool, error)
+	GetAllModulesByCourseID(courseID uint, userID uint, canManage bool, page, limit int64) (*[]models.Module, *map[uint]bool, *map[uint]ModuleLockStatus, pagination.Pagination, error)
+	UpdateModule(id, actorID uint, updates map[string]interface{}, pdf *multipart.FileHeader, video *multipart.FileHeader) (*models.Module, error)
 	DeleteModule(id uint) error
 	ReorderModules(courseID uint, moduleOrders []models.Module) error // Expects a slice of Module with ID and Order
-	CompleteModuleByID(moduleID uint, userID uint, isCompleted bool) (int64, int64, float64, *time.Time, error)
+	CompleteModuleByID(moduleID uint, userID uint, canManage bool, isCompleted bool) (int64, int64, float64, *time.Time, error)
+	GetModulePrerequisites(moduleID uint, userID uint, canManage bool) ([]uint, error)
+	SetModulePrerequisites(moduleID uint, prerequisiteIDs []uint) error
+	GetModuleRevisions(moduleID uint, page, limit int64) (*[]models.Revision, pagination.Pagination, error)
+	GetModuleRevision(moduleID uint, version int) (*models.Revision, error)
+	RestoreModuleRevision(moduleID, actorID uint, version int) (*models.Module, *models.Revision, error)
+	AuthorizeCourse(userID uint, role string, courseID uint) error
+	AuthorizeModule(userID uint, role string, moduleID uint) error
 }
 
+// ErrModuleLocked is returned when a user tries to access or complete a module they have not unlocked yet.
+var ErrModuleLocked = errors.New("module is locked")
+
+// ErrNotEnrolled is returned when a user tries to track progress in a course they have not purchased.
+var ErrNotEnrolled = errors.New("course must be purchased to track progress")
+
+// moduleAddedBatchSize caps how many enrollees are notified of a new module with a single insert.
+const moduleAddedBatchSize = 500
+
+// ModuleLockStatus describes whether a module is accessible to a user, and what is still required if not.
+type ModuleLockStatus struct {
+	Locked       bool       `json:"locked"`
+	UnlockReason string     `json:"unlock_reason"`
+	AvailableAt  *time.Time `json:"available_at,omitempty"` // Set while the module waits for its drip release
+	Hidden       bool       `json:"-"`                      // Unreleased and configured to stay out of listings
+}
+
+// ModuleReleaseRule schedules when a module becomes available. When both dates apply, the later one wins.
+type ModuleReleaseRule struct {
+	ReleaseAt         *time.Time // Absolute release date
+	ReleaseAfterDays  *int       // Days after the user's Enrollment.PurchasedAt
+	HideUntilReleased bool       // Leave the module out of listings until it is released
+}
+
+// releasedModulesCondition filters out modules that are hidden until released and not yet released for the user.
+const releasedModulesCondition = `NOT (modules.hide_until_released AND (
+	(modules.release_at IS NOT NULL AND modules.release_at > NOW()) OR
+	(modules.release_after_days IS NOT NULL AND NOT EXISTS (
+		SELECT 1 FROM enrollments
+		WHERE enrollments.user_id = ? AND enrollments.course_id = modules.course_id AND enrollments.deleted_at IS NULL
+		AND enrollments.purchased_at + make_interval(days => modules.release_after_days) <= NOW()
+	))
+))`
+
 // ModuleService implements ModuleServicer.
 type ModuleService struct {
-	DB    *gorm.DB
-	Cloud storage.CloudStorage
+	DB        *gorm.DB
+	Cloud     storage.CloudStorage
+	Revisions RevisionServicer
+	Events    events.Publisher
+	Notifier  TxNotifier
+	Mailer    Mailer
 }
 
 // NewModuleService creates a new ModuleService.
-func NewModuleService(db *gorm.DB, cloud storage.CloudStorage) *ModuleService {
-	return &ModuleService{DB: db, Cloud: cloud}
+func NewModuleService(db *gorm.DB, cloud storage.CloudStorage, revisions RevisionServicer, events events.Publisher, notifier TxNotifier, mailer Mailer) *ModuleService {
+	return &ModuleService{DB: db, Cloud: cloud, Revisions: revisions, Events: events, Notifier: notifier, Mailer: mailer}
 }
 
 // CreateModule creates a new module for a given course, handling file uploads.
 func (s *ModuleService) CreateModule(
-	courseID uint, title, description string,
+	courseID, actorID uint, title, description string,
+	release ModuleReleaseRule,
 	pdf *multipart.FileHeader, video *multipart.FileHeader,
 ) (*models.Module, error) {
 	// Check if the course exists
@@ -80,23 +131,84 @@ func (s *ModuleService) CreateModule(
 	}
 
 	module := models.Module{
-		CourseID:    courseID,
-		Title:       title,
-		Description: description,
-		Order:       newOrder,
-		PDFPath:     pdfPath,
-		VideoPath:   videoPath,
+		CourseID:          courseID,
+		Title:             title,
+		Description:       description,
+		Order:             newOrder,
+		PDFPath:           pdfPath,
+		VideoPath:         videoPath,
+		ReleaseAt:         release.ReleaseAt,
+		ReleaseAfterDays:  release.ReleaseAfterDays,
+		HideUntilReleased: release.HideUntilReleased,
+	}
+
+	// Drip-scheduled modules are announced by the module release job once released, and modules hidden
+	// until then are only published to clients then too
+	scheduled := module.ReleaseAt != nil || module.ReleaseAfterDays != nil
+
+	var notified []events.Event
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&module).Error; err != nil {
+			return fmt.Errorf("failed to create module in DB: %w", err)
+		}
+		if _, err := s.Revisions.Record(tx, models.RevisionEntityModule, module.ID, actorID, models.RevisionActionCreate, nil, moduleSnapshot(module), moduleAssets(module)); err != nil {
+			return err
+		}
+		if !scheduled {
+			var err error
+			notified, err = s.notifyModuleAdded(tx, course, module)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if result := s.DB.Create(&module); result.Error != nil {
-		return nil, fmt.Errorf("failed to create module in DB: %w", result.Error)
+	for _, event := range notified {
+		s.Events.Publish(event)
+	}
+	if !scheduled || !module.HideUntilReleased {
+		s.Events.Publish(events.Event{
+			Type:     events.ModuleAdded,
+			CourseID: courseID,
+			ModuleID: module.ID,
+			Data:     map[string]interface{}{"title": module.Title},
+		})
 	}
-
 	return &module, nil
 }
 
-// GetModuleByID retrieves a module by its ID.
-func (s *ModuleService) GetModuleByID(id uint, userID uint) (*models.Module, bool, error) {
+// notifyModuleAdded tells the users enrolled in a course about a new module within the transaction tx,
+// a batch of enrollees at a time. It returns the events to publish once tx has committed.
+func (s *ModuleService) notifyModuleAdded(tx *gorm.DB, course models.Course, module models.Module) ([]events.Event, error) {
+	title := "New module available"
+	body := fmt.Sprintf("%q was added to %q.", module.Title, course.Title)
+
+	var notified []events.Event
+	var enrollments []models.Enrollment
+	err := tx.Select("transaction_id", "user_id").Where("course_id = ?", course.ID).
+		FindInBatches(&enrollments, moduleAddedBatchSize, func(_ *gorm.DB, _ int) error {
+			userIDs := make([]uint, 0, len(enrollments))
+			for _, enrollment := range enrollments {
+				userIDs = append(userIDs, enrollment.UserID)
+			}
+			batchEvents, err := s.Notifier.NotifyAllTx(tx, userIDs, models.NotificationModuleAdded, title, body)
+			if err != nil {
+				return fmt.Errorf("failed to notify enrollees of module %d: %w", module.ID, err)
+			}
+			notified = append(notified, batchEvents...)
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return notified, nil
+}
+
+// GetModuleByID retrieves a module by its ID. Users who can manage the course (canManage) preview
+// locked and unreleased modules.
+func (s *ModuleService) GetModuleByID(id uint, userID uint, canManage bool) (*models.Module, bool, error) {
 	var module models.Module
 	result := s.DB.First(&module, id)
 	if result.Error != nil {
@@ -106,6 +218,25 @@ func (s *ModuleService) GetModuleByID(id uint, userID uint) (*models.Module, boo
 		return nil, false, fmt.Errorf("database error finding module: %w", result.Error)
 	}
 
+	if err := s.checkCourseVisible(module.CourseID, userID, canManage); err != nil {
+		if err.Error() == "course not found" {
+			return nil, false, errors.New("module not found")
+		}
+		return nil, false, err
+	}
+
+	if !canManage {
+		locks, err := s.getModuleLocks(module.CourseID, userID)
+		if err != nil {
+			return nil, false, err
+		}
+		if lock := locks[module.ID]; lock.Hidden {
+			return nil, false, errors.New("module not found")
+		} else if lock.Locked {
+			return nil, false, fmt.Errorf("%w: %s", ErrModuleLocked, lock.UnlockReason)
+		}
+	}
+
 	var progress models.ModuleProgress
 
 	progressResult := s.DB.
@@ -121,11 +252,20 @@ func (s *ModuleService) GetModuleByID(id uint, userID uint) (*models.Module, boo
 }
 
 // GetAllModulesByCourseID retrieves all modules for a specific course with pagination and search.
-func (s *ModuleService) GetAllModulesByCourseID(courseID uint, userID uint, page, limit int64) (*[]models.Module, *map[uint]bool, pagination.Pagination, error) {
+// Users who can manage the course (canManage) see every module unlocked, unreleased ones included.
+func (s *ModuleService) GetAllModulesByCourseID(courseID uint, userID uint, canManage bool, page, limit int64) (*[]models.Module, *map[uint]bool, *map[uint]ModuleLockStatus, pagination.Pagination, error) {
 	var modules []models.Module
 	searchableColumns := []string{"title", "description"}
 
-	dbQuery := s.DB.Where("course_id = ?", courseID).Order("\"order\" ASC") // Order by "order" column
+	if err := s.checkCourseVisible(courseID, userID, canManage); err != nil {
+		return nil, nil, nil, pagination.Pagination{}, err
+	}
+
+	dbQuery := s.DB.Where("course_id = ?", courseID)
+	if !canManage {
+		dbQuery = dbQuery.Where(releasedModulesCondition, userID)
+	}
+	dbQuery = dbQuery.Order("\"order\" ASC") // Order by "order" column
 
 	filteredModules, pagination, err := pagination.Paginate(
 		dbQuery.Model(&models.Module{}),
@@ -135,6 +275,9 @@ func (s *ModuleService) GetAllModulesByCourseID(courseID uint, userID uint, page
 		searchableColumns,
 		"",
 	)
+	if err != nil {
+		return nil, nil, nil, pagination, err
+	}
 
 	assertedModules := filteredModules.(*[]models.Module)
 
@@ -143,22 +286,23 @@ func (s *ModuleService) GetAllModulesByCourseID(courseID uint, userID uint, page
 
 	progressMap := make(map[uint]bool)
 	for _, p := range progress {
-		println(p.ModuleID)
-		println(p.IsCompleted)
 		progressMap[p.ModuleID] = *p.IsCompleted
 	}
 
-	if err != nil {
-		return nil, &progressMap, pagination, err
+	lockMap := make(map[uint]ModuleLockStatus)
+	if !canManage {
+		lockMap, err = s.getModuleLocks(courseID, userID)
+		if err != nil {
+			return nil, &progressMap, nil, pagination, err
+		}
 	}
 
-	var courseTitle string
-	s.DB.Model(&models.Course{}).Select("courses.title").Where("id = ?", courseID).Find(&courseTitle)
-	return assertedModules, &progressMap, pagination, nil
+	return assertedModules, &progressMap, &lockMap, pagination, nil
 }
 
 // UpdateModule updates an existing module, handling partial updates and optional file updates.
-func (s *ModuleService) UpdateModule(id uint, updates map[string]interface{}, pdf *multipart.FileHeader, video *multipart.FileHeader) (*models.Module, error) {
+// Replaced files stay in storage so that older revisions can still be restored.
+func (s *ModuleService) UpdateModule(id, actorID uint, updates map[string]interface{}, pdf *multipart.FileHeader, video *multipart.FileHeader) (*models.Module, error) {
 	var module models.Module
 	result := s.DB.First(&module, id)
 	if result.Error != nil {
@@ -167,54 +311,42 @@ func (s *ModuleService) UpdateModule(id uint, updates map[string]interface{}, pd
 		}
 		return nil, fmt.Errorf("database error finding module: %w", result.Error)
 	}
+	before := moduleSnapshot(module)
 
 	// Handle PDF file update
 	if pdf != nil {
-		newPDFPath, err := s.saveContentFile(pdf, "pdf", module.Title, module.PDFPath)
+		newPDFPath, err := s.saveContentFile(pdf, "pdf", module.Title, "")
 		if err != nil {
 			return nil, err
 		}
 		updates["PDFPath"] = newPDFPath
-		// Optionally, delete old PDF file
-		if module.PDFPath != "" {
-			// if err := os.Remove(module.PDFPath); err != nil {
-			// 	fmt.Printf("Warning: Failed to delete old PDF file %s: %v\n", module.PDFPath, err)
-			// }
-		}
 	} else if _, ok := updates["pdf_content"]; ok && updates["pdf_content"] == nil { // Check if client explicitly sent null to clear
 		updates["PDFPath"] = ""
-		if module.PDFPath != "" {
-			// if err := os.Remove(module.PDFPath); err != nil {
-			// 	fmt.Printf("Warning: Failed to delete old PDF file %s when clearing: %v\n", module.PDFPath, err)
-			// }
-		}
 	}
+	delete(updates, "pdf_content")
 
 	// Handle Video file update
 	if video != nil {
-		newVideoPath, err := s.saveContentFile(video, "video", module.Title, module.VideoPath)
+		newVideoPath, err := s.saveContentFile(video, "video", module.Title, "")
 		if err != nil {
 			return nil, err
 		}
 		updates["VideoPath"] = newVideoPath
-		// Optionally, delete old video file
-		if module.VideoPath != "" {
-			if err := os.Remove(module.VideoPath); err != nil {
-				fmt.Printf("Warning: Failed to delete old video file %s: %v\n", module.VideoPath, err)
-			}
-		}
 	} else if _, ok := updates["video_content"]; ok && updates["video_content"] == nil { // Check if client explicitly sent null to clear
 		updates["VideoPath"] = ""
-		if module.VideoPath != "" {
-			if err := os.Remove(module.VideoPath); err != nil {
-				fmt.Printf("Warning: Failed to delete old video file %s when clearing: %v\n", module.VideoPath, err)
-			}
-		}
 	}
+	delete(updates, "video_content")
 
 	// Apply other updates
-	if err := s.DB.Model(&module).Updates(updates).Error; err != nil {
-		return nil, fmt.Errorf("failed to update module: %w", err)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&module).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update module: %w", err)
+		}
+		_, err := s.Revisions.Record(tx, models.RevisionEntityModule, module.ID, actorID, models.RevisionActionUpdate, before, moduleSnapshot(module), moduleAssets(module))
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &module, nil
@@ -355,7 +487,9 @@ func (s *ModuleService) ReorderModules(courseID uint, moduleOrders []models.Modu
 
 }
 
-func (s *ModuleService) CompleteModuleByID(moduleID uint, userID uint, isCompleted bool) (int64, int64, float64, *time.Time, error) {
+// CompleteModuleByID records a user's progress on a module. Only enrollees and users who can manage the
+// course (canManage) track progress, and only managers complete modules that are still locked.
+func (s *ModuleService) CompleteModuleByID(moduleID uint, userID uint, canManage bool, isCompleted bool) (int64, int64, float64, *time.Time, error) {
 	var module models.Module
 	result := s.DB.First(&module, moduleID)
 	if result.Error != nil {
@@ -365,6 +499,41 @@ func (s *ModuleService) CompleteModuleByID(moduleID uint, userID uint, isComplet
 		return 0, 0, 0, nil, fmt.Errorf("database error finding module: %w", result.Error)
 	}
 
+	if err := s.checkCourseVisible(module.CourseID, userID, canManage); err != nil {
+		if err.Error() == "course not found" {
+			return 0, 0, 0, nil, errors.New("module not found")
+		}
+		return 0, 0, 0, nil, err
+	}
+
+	if !canManage {
+		var enrolled bool
+		if err := s.DB.Model(&models.Enrollment{}).Select("count(*) > 0").Where("user_id = ? AND course_id = ?", userID, module.CourseID).Find(&enrolled).Error; err != nil {
+			return 0, 0, 0, nil, fmt.Errorf("database error finding enrollment: %w", err)
+		}
+		if !enrolled {
+			return 0, 0, 0, nil, ErrNotEnrolled
+		}
+
+		// Un-completing is always allowed, completing requires the module to be unlocked.
+		if isCompleted {
+			locks, err := s.getModuleLocks(module.CourseID, userID)
+			if err != nil {
+				return 0, 0, 0, nil, err
+			}
+			if lock := locks[module.ID]; lock.Hidden {
+				return 0, 0, 0, nil, errors.New("module not found")
+			} else if lock.Locked {
+				return 0, 0, 0, nil, fmt.Errorf("%w: %s", ErrModuleLocked, lock.UnlockReason)
+			}
+		}
+	}
+
+	_, completedBefore, err := countCourseProgress(s.DB, userID, module.CourseID)
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+
 	module_progress := models.ModuleProgress{
 		UserID:   userID,
 		ModuleID: moduleID,
@@ -401,9 +570,378 @@ func (s *ModuleService) CompleteModuleByID(moduleID uint, userID uint, isComplet
 		progressPercentage = float64(completedModules) / float64(totalModules) * 100
 	}
 
+	if isCompleted && totalModules > 0 && completedModules == totalModules && completedBefore < totalModules {
+		var course models.Course
+		if err := s.DB.Select("id", "title").First(&course, module.CourseID).Error; err != nil {
+			log.Printf("failed to find completed course %d: %v", module.CourseID, err)
+		} else {
+			body := fmt.Sprintf("You completed every module of %q. Congratulations!", course.Title)
+			if err := s.Notifier.Notify(userID, models.NotificationCourseCompleted, "Course completed", body); err != nil {
+				log.Printf("failed to notify user %d of course completion: %v", userID, err)
+			}
+			data := map[string]interface{}{"CourseID": course.ID, "CourseTitle": course.Title}
+			if err := s.Mailer.Mail(userID, email.TemplateCourseCompleted, data); err != nil {
+				log.Printf("failed to email user %d of course completion: %v", userID, err)
+			}
+		}
+	}
+
+	s.Events.Publish(events.Event{
+		Type:     events.ProgressChanged,
+		CourseID: module.CourseID,
+		ModuleID: module.ID,
+		UserID:   userID,
+		Data: map[string]interface{}{
+			"is_completed":        isCompleted,
+			"completed_modules":   completedModules,
+			"total_modules":       totalModules,
+			"progress_percentage": progressPercentage,
+		},
+	})
 	return totalModules, completedModules, progressPercentage, latestCompletion, nil
 }
 
+// GetModulePrerequisites returns the IDs of the modules that must be completed before the given module unlocks.
+// Like the module itself, they are only shown to users who can see the course.
+func (s *ModuleService) GetModulePrerequisites(moduleID uint, userID uint, canManage bool) ([]uint, error) {
+	var module models.Module
+	if err := s.DB.First(&module, moduleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("module not found")
+		}
+		return nil, fmt.Errorf("database error finding module: %w", err)
+	}
+
+	if err := s.checkCourseVisible(module.CourseID, userID, canManage); err != nil {
+		if err.Error() == "course not found" {
+			return nil, errors.New("module not found")
+		}
+		return nil, err
+	}
+
+	prerequisiteIDs := []uint{}
+	if err := s.DB.Model(&models.ModulePrerequisite{}).
+		Where("module_id = ?", moduleID).
+		Order("prerequisite_id ASC").
+		Pluck("prerequisite_id", &prerequisiteIDs).Error; err != nil {
+		return nil, fmt.Errorf("database error finding prerequisites: %w", err)
+	}
+
+	return prerequisiteIDs, nil
+}
+
+// SetModulePrerequisites replaces the explicit prerequisites of a module.
+// Prerequisites must belong to the same course and must not form a cycle.
+func (s *ModuleService) SetModulePrerequisites(moduleID uint, prerequisiteIDs []uint) error {
+	var module models.Module
+	if err := s.DB.First(&module, moduleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("module not found")
+		}
+		return fmt.Errorf("database error finding module: %w", err)
+	}
+
+	uniqueIDs := make(map[uint]struct{})
+	for _, id := range prerequisiteIDs {
+		if id == moduleID {
+			return errors.New("module cannot be its own prerequisite")
+		}
+		uniqueIDs[id] = struct{}{}
+	}
+
+	if len(uniqueIDs) > 0 {
+		var count int64
+		if err := s.DB.Model(&models.Module{}).
+			Where("id IN (?) AND course_id = ?", prerequisiteIDs, module.CourseID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("database error verifying prerequisites: %w", err)
+		}
+		if count != int64(len(uniqueIDs)) {
+			return errors.New("some prerequisite IDs do not belong to the same course or are invalid")
+		}
+	}
+
+	// Build the course's prerequisite graph with the new edges and make sure the module can't reach itself.
+	var existing []models.ModulePrerequisite
+	if err := s.DB.Joins("JOIN modules ON modules.id = module_prerequisites.module_id").
+		Where("modules.course_id = ? AND module_prerequisites.module_id <> ?", module.CourseID, moduleID).
+		Find(&existing).Error; err != nil {
+		return fmt.Errorf("database error loading prerequisites: %w", err)
+	}
+	graph := make(map[uint][]uint)
+	for _, p := range existing {
+		graph[p.ModuleID] = append(graph[p.ModuleID], p.PrerequisiteID)
+	}
+	for id := range uniqueIDs {
+		graph[moduleID] = append(graph[moduleID], id)
+	}
+	if hasPrerequisiteCycle(graph, moduleID) {
+		return errors.New("prerequisites would create a cycle")
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("module_id = ?", moduleID).Delete(&models.ModulePrerequisite{}).Error; err != nil {
+			return fmt.Errorf("failed to clear prerequisites: %w", err)
+		}
+		for id := range uniqueIDs {
+			prerequisite := models.ModulePrerequisite{ModuleID: moduleID, PrerequisiteID: id}
+			if err := tx.Create(&prerequisite).Error; err != nil {
+				return fmt.Errorf("failed to save prerequisite %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// checkCourseVisible applies the visibility rule of GetCourseByID to a course's modules: the modules of
+// courses that are not published are only shown to the course's managers and to enrollees.
+func (s *ModuleService) checkCourseVisible(courseID, userID uint, canManage bool) error {
+	var course models.Course
+	if err := s.DB.Select("id", "status").First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("course not found")
+		}
+		return fmt.Errorf("database error finding course: %w", err)
+	}
+	if course.Status == models.CourseStatusPublished || canManage {
+		return nil
+	}
+
+	var enrolled bool
+	if err := s.DB.Model(&models.Enrollment{}).Select("count(*) > 0").Where("user_id = ? AND course_id = ?", userID, courseID).Find(&enrolled).Error; err != nil {
+		return fmt.Errorf("database error finding enrollment: %w", err)
+	}
+	if !enrolled {
+		return errors.New("course not found")
+	}
+	return nil
+}
+
+// getModuleLocks computes the lock status of every module in a course for a user,
+// taking the course's sequential setting and explicit module prerequisites into account.
+func (s *ModuleService) getModuleLocks(courseID uint, userID uint) (map[uint]ModuleLockStatus, error) {
+	var course models.Course
+	if err := s.DB.Select("id", "sequential_modules").First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("course not found")
+		}
+		return nil, fmt.Errorf("database error finding course: %w", err)
+	}
+
+	// Drip release is relative to the purchase date, so look up the user's enrollment if there is one.
+	var enrollment models.Enrollment
+	enrolled := true
+	if err := s.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("database error finding enrollment: %w", err)
+		}
+		enrolled = false
+	}
+	now := time.Now()
+
+	var modules []models.Module
+	if err := s.DB.Select("id", "title", "\"order\"", "release_at", "release_after_days", "hide_until_released").
+		Where("course_id = ?", courseID).
+		Order("\"order\" ASC").
+		Find(&modules).Error; err != nil {
+		return nil, fmt.Errorf("database error finding modules: %w", err)
+	}
+	moduleIDs := s.getModuleIDs(modules)
+	titles := make(map[uint]string, len(modules))
+	for _, m := range modules {
+		titles[m.ID] = m.Title
+	}
+
+	var completedIDs []uint
+	if err := s.DB.Model(&models.ModuleProgress{}).
+		Where("user_id = ? AND module_id IN (?) AND is_completed = ?", userID, moduleIDs, true).
+		Pluck("module_id", &completedIDs).Error; err != nil {
+		return nil, fmt.Errorf("database error finding progress: %w", err)
+	}
+	completed := make(map[uint]bool, len(completedIDs))
+	for _, id := range completedIDs {
+		completed[id] = true
+	}
+
+	var prerequisites []models.ModulePrerequisite
+	if err := s.DB.Where("module_id IN (?)", moduleIDs).Find(&prerequisites).Error; err != nil {
+		return nil, fmt.Errorf("database error finding prerequisites: %w", err)
+	}
+	prerequisiteMap := make(map[uint][]uint)
+	for _, p := range prerequisites {
+		prerequisiteMap[p.ModuleID] = append(prerequisiteMap[p.ModuleID], p.PrerequisiteID)
+	}
+
+	locks := make(map[uint]ModuleLockStatus, len(modules))
+	for i, m := range modules {
+		var reasons []string
+		var availableAt *time.Time
+
+		if m.ReleaseAt != nil && m.ReleaseAt.After(now) {
+			availableAt = m.ReleaseAt
+		}
+		if m.ReleaseAfterDays != nil {
+			if !enrolled {
+				reasons = append(reasons, fmt.Sprintf("available %d days after purchase", *m.ReleaseAfterDays))
+			} else if dripAt := enrollment.PurchasedAt.AddDate(0, 0, *m.ReleaseAfterDays); dripAt.After(now) && (availableAt == nil || dripAt.After(*availableAt)) {
+				availableAt = &dripAt
+			}
+		}
+		if availableAt != nil {
+			reasons = append(reasons, "available on "+availableAt.Format("2006-01-02 15:04"))
+		}
+		unreleased := len(reasons) > 0
+
+		if course.SequentialModules && i > 0 && !completed[modules[i-1].ID] {
+			reasons = append(reasons, fmt.Sprintf("complete %q first", modules[i-1].Title))
+		}
+
+		var unmet []string
+		for _, prerequisiteID := range prerequisiteMap[m.ID] {
+			// prerequisites pointing at deleted modules no longer block anything
+			title, ok := titles[prerequisiteID]
+			if ok && !completed[prerequisiteID] {
+				unmet = append(unmet, fmt.Sprintf("%q", title))
+			}
+		}
+		if len(unmet) > 0 {
+			sort.Strings(unmet)
+			reasons = append(reasons, "complete prerequisite modules "+strings.Join(unmet, ", "))
+		}
+
+		locks[m.ID] = ModuleLockStatus{
+			Locked:       len(reasons) > 0,
+			UnlockReason: strings.Join(reasons, "; "),
+			AvailableAt:  availableAt,
+			Hidden:       unreleased && m.HideUntilReleased,
+		}
+	}
+
+	return locks, nil
+}
+
+// hasPrerequisiteCycle reports whether start can reach itself by following prerequisite edges.
+func hasPrerequisiteCycle(graph map[uint][]uint, start uint) bool {
+	visited := make(map[uint]bool)
+	stack := append([]uint{}, graph[start]...)
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == start {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, graph[current]...)
+	}
+	return false
+}
+
+// AuthorizeCourse checks that the user may manage the modules of a course: admins always, instructors only for courses they teach.
+func (s *ModuleService) AuthorizeCourse(userID uint, role string, courseID uint) error {
+	return authorizeCourseManager(s.DB, userID, role, courseID, false)
+}
+
+// AuthorizeModule checks that the user may manage the course the module belongs to.
+func (s *ModuleService) AuthorizeModule(userID uint, role string, moduleID uint) error {
+	var module models.Module
+	if err := s.DB.Select("id", "course_id").First(&module, moduleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("module not found")
+		}
+		return fmt.Errorf("database error finding module: %w", err)
+	}
+	return authorizeCourseManager(s.DB, userID, role, module.CourseID, false)
+}
+
+// GetModuleRevisions lists the revision history of a module, newest first.
+func (s *ModuleService) GetModuleRevisions(moduleID uint, page, limit int64) (*[]models.Revision, pagination.Pagination, error) {
+	return s.Revisions.GetRevisions(models.RevisionEntityModule, moduleID, page, limit)
+}
+
+// GetModuleRevision retrieves one revision of a module.
+func (s *ModuleService) GetModuleRevision(moduleID uint, version int) (*models.Revision, error) {
+	return s.Revisions.GetRevision(models.RevisionEntityModule, moduleID, version)
+}
+
+// RestoreModuleRevision puts the content of an earlier revision back on the module, recorded as a new revision.
+func (s *ModuleService) RestoreModuleRevision(moduleID, actorID uint, version int) (*models.Module, *models.Revision, error) {
+	var module models.Module
+	if err := s.DB.First(&module, moduleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("module not found")
+		}
+		return nil, nil, fmt.Errorf("database error finding module: %w", err)
+	}
+
+	revision, err := s.Revisions.GetRevision(models.RevisionEntityModule, moduleID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Snapshot keys are the module's JSON field names, so decode them back into a module.
+	var restored models.Module
+	bytes, err := json.Marshal(revision.Snapshot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read revision: %w", err)
+	}
+	if err := json.Unmarshal(bytes, &restored); err != nil {
+		return nil, nil, fmt.Errorf("failed to read revision: %w", err)
+	}
+
+	before := moduleSnapshot(module)
+	updates := map[string]interface{}{
+		"Title":             restored.Title,
+		"Description":       restored.Description,
+		"PDFPath":           restored.PDFPath,
+		"VideoPath":         restored.VideoPath,
+		"ReleaseAt":         restored.ReleaseAt,
+		"ReleaseAfterDays":  restored.ReleaseAfterDays,
+		"HideUntilReleased": restored.HideUntilReleased,
+	}
+
+	var newRevision *models.Revision
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&module).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to restore module: %w", err)
+		}
+		newRevision, err = s.Revisions.Record(tx, models.RevisionEntityModule, module.ID, actorID, models.RevisionActionRestore, before, moduleSnapshot(module), moduleAssets(module))
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &module, newRevision, nil
+}
+
+// moduleSnapshot returns the versioned content of a module. Keys match the module's JSON field names.
+func moduleSnapshot(module models.Module) map[string]interface{} {
+	return map[string]interface{}{
+		"title":               module.Title,
+		"description":         module.Description,
+		"pdf_content":         module.PDFPath,
+		"video_content":       module.VideoPath,
+		"release_at":          module.ReleaseAt,
+		"release_after_days":  module.ReleaseAfterDays,
+		"hide_until_released": module.HideUntilReleased,
+	}
+}
+
+// moduleAssets returns the storage objects a module currently points to.
+func moduleAssets(module models.Module) []string {
+	var assets []string
+	if module.PDFPath != "" {
+		assets = append(assets, module.PDFPath)
+	}
+	if module.VideoPath != "" {
+		assets = append(assets, module.VideoPath)
+	}
+	return assets
+}
+
 func (s *ModuleService) getModuleIDs(modules []models.Module) []uint {
 	var ids []uint
 	for _, module := range modules {
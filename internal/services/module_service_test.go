@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+
+	"grocademy/internal/db/dbtest"
+	"grocademy/internal/db/models"
+	"grocademy/internal/events"
+)
+
+// recordingPublisher keeps the events published to it.
+type recordingPublisher struct {
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(event events.Event) {
+	p.events = append(p.events, event)
+}
+
+func TestCreateModuleNotifiesEnrollees(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.Course{}, &models.Enrollment{}, &models.Module{}, &models.Revision{},
+		&models.Notification{}, &models.NotificationPreference{})
+	publisher := &recordingPublisher{}
+	s := NewModuleService(db, nil, NewRevisionService(db), publisher, NewNotificationService(db, publisher), NewEmailService(db))
+
+	course := models.Course{Title: "Go", Status: models.CourseStatusPublished}
+	if err := db.Create(&course).Error; err != nil {
+		t.Fatal(err)
+	}
+	ada := createTestUser(t, db, models.User{Username: "ada", Email: "ada@example.com", FirstName: "Ada"})
+	bob := createTestUser(t, db, models.User{Username: "bob", Email: "bob@example.com", FirstName: "Bob"})
+	createTestUser(t, db, models.User{Username: "eve", Email: "eve@example.com", FirstName: "Eve"})
+	for _, user := range []models.User{ada, bob} {
+		if err := db.Create(&models.Enrollment{UserID: user.ID, CourseID: course.ID}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&models.NotificationPreference{UserID: bob.ID, Kind: models.NotificationModuleAdded, Enabled: false}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.CreateModule(course.ID, ada.ID, "Basics", "", ModuleReleaseRule{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var notified []uint
+	db.Model(&models.Notification{}).Where("kind = ?", models.NotificationModuleAdded).Pluck("user_id", &notified)
+	if len(notified) != 1 || notified[0] != ada.ID {
+		t.Errorf("notified users %v, want only %d", notified, ada.ID)
+	}
+	var pushed []uint
+	for _, event := range publisher.events {
+		if event.Type == events.NotificationCreated {
+			pushed = append(pushed, event.UserID)
+		}
+	}
+	if len(pushed) != 1 || pushed[0] != ada.ID {
+		t.Errorf("notifications pushed to users %v, want only %d", pushed, ada.ID)
+	}
+}
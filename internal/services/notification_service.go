@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/events"
+	"grocademy/internal/pkg/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationServicer defines the operations on users' notification centers.
+type NotificationServicer interface {
+	Notifier
+	GetNotifications(userID uint, unreadOnly bool, page, limit int64) (*[]models.Notification, pagination.Pagination, error)
+	CountUnread(userID uint) (int64, error)
+	MarkRead(userID, notificationID uint) (*models.Notification, error)
+	MarkAllRead(userID uint) (int64, error)
+	GetPreferences(userID uint) (map[string]bool, error)
+	UpdatePreferences(userID uint, preferences map[string]bool) (map[string]bool, error)
+}
+
+// ErrUnknownNotificationKind is returned when updating the preference of a notification kind that does not exist.
+var ErrUnknownNotificationKind = errors.New("unknown notification kind")
+
+// NotificationService is the Notifier storing notifications in the users' notification centers.
+type NotificationService struct {
+	DB     *gorm.DB
+	Events events.Publisher
+}
+
+func NewNotificationService(db *gorm.DB, events events.Publisher) *NotificationService {
+	return &NotificationService{DB: db, Events: events}
+}
+
+// Notify adds a notification to the user's notification center, unless they turned that kind off,
+// and pushes it to their open event streams.
+func (s *NotificationService) Notify(userID uint, kind, title, body string) error {
+	notified, err := s.NotifyTx(s.DB, userID, kind, title, body)
+	if err != nil {
+		return err
+	}
+	for _, event := range notified {
+		s.Events.Publish(event)
+	}
+	return nil
+}
+
+// NotifyTx is Notify within the transaction tx. Instead of pushing the notification, it returns the
+// event to publish once tx has committed.
+func (s *NotificationService) NotifyTx(tx *gorm.DB, userID uint, kind, title, body string) ([]events.Event, error) {
+	return s.NotifyAllTx(tx, []uint{userID}, kind, title, body)
+}
+
+// NotifyAllTx is NotifyTx for several users at once, with a single insert.
+func (s *NotificationService) NotifyAllTx(tx *gorm.DB, userIDs []uint, kind, title, body string) ([]events.Event, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	var disabled []uint
+	if err := tx.Model(&models.NotificationPreference{}).
+		Where("user_id IN ? AND kind = ? AND NOT enabled", userIDs, kind).
+		Pluck("user_id", &disabled).Error; err != nil {
+		return nil, fmt.Errorf("database error finding notification preferences: %w", err)
+	}
+
+	notifications := make([]models.Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if !slices.Contains(disabled, userID) {
+			notifications = append(notifications, models.Notification{UserID: userID, Kind: kind, Title: title, Body: body})
+		}
+	}
+	if len(notifications) == 0 {
+		return nil, nil
+	}
+	if err := tx.Create(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to create notifications: %w", err)
+	}
+
+	notified := make([]events.Event, 0, len(notifications))
+	for _, notification := range notifications {
+		notified = append(notified, events.Event{
+			Type:   events.NotificationCreated,
+			UserID: notification.UserID,
+			Data:   map[string]interface{}{"id": notification.ID, "kind": kind, "title": title, "body": body},
+		})
+	}
+	return notified, nil
+}
+
+// GetNotifications lists a user's notifications, newest first.
+func (s *NotificationService) GetNotifications(userID uint, unreadOnly bool, page, limit int64) (*[]models.Notification, pagination.Pagination, error) {
+	var notifications []models.Notification
+	dbQuery := s.DB.Model(&models.Notification{}).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC")
+	if unreadOnly {
+		dbQuery = dbQuery.Where("read_at IS NULL")
+	}
+
+	_, pagination, err := pagination.Paginate(dbQuery, &notifications, page, limit, nil, "")
+	if err != nil {
+		return nil, pagination, fmt.Errorf("database error finding notifications: %w", err)
+	}
+	return &notifications, pagination, nil
+}
+
+func (s *NotificationService) CountUnread(userID uint) (int64, error) {
+	var count int64
+	if err := s.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("database error counting notifications: %w", err)
+	}
+	return count, nil
+}
+
+func (s *NotificationService) MarkRead(userID, notificationID uint) (*models.Notification, error) {
+	var notification models.Notification
+	if err := s.DB.Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("notification not found")
+		}
+		return nil, fmt.Errorf("database error finding notification: %w", err)
+	}
+
+	if notification.ReadAt == nil {
+		if err := s.DB.Model(&notification).Update("ReadAt", time.Now()).Error; err != nil {
+			return nil, fmt.Errorf("failed to mark notification as read: %w", err)
+		}
+	}
+	return &notification, nil
+}
+
+// MarkAllRead marks every unread notification of the user as read and returns how many there were.
+func (s *NotificationService) MarkAllRead(userID uint) (int64, error) {
+	result := s.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to mark notifications as read: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetPreferences reports for every notification kind whether the user receives it.
+func (s *NotificationService) GetPreferences(userID uint) (map[string]bool, error) {
+	var stored []models.NotificationPreference
+	if err := s.DB.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("database error finding notification preferences: %w", err)
+	}
+
+	preferences := make(map[string]bool, len(models.NotificationKinds))
+	for _, kind := range models.NotificationKinds {
+		preferences[kind] = true
+	}
+	for _, preference := range stored {
+		preferences[preference.Kind] = preference.Enabled
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences turns the given notification kinds on or off, leaving the others as they are.
+func (s *NotificationService) UpdatePreferences(userID uint, preferences map[string]bool) (map[string]bool, error) {
+	var rows []models.NotificationPreference
+	for kind, enabled := range preferences {
+		if !slices.Contains(models.NotificationKinds, kind) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownNotificationKind, kind)
+		}
+		rows = append(rows, models.NotificationPreference{UserID: userID, Kind: kind, Enabled: enabled})
+	}
+
+	if len(rows) > 0 {
+		if err := s.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "kind"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+		}).Create(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to save notification preferences: %w", err)
+		}
+	}
+
+	return s.GetPreferences(userID)
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"log"
+
+	"grocademy/internal/events"
+
+	"gorm.io/gorm"
+)
+
+// Notifier delivers a short message to a single user.
+type Notifier interface {
+	Notify(userID uint, kind, title, body string) error
+}
+
+// TxNotifier is a Notifier that can store notifications as part of a database transaction, so they are
+// only kept when the rest of the transaction commits. It returns the events announcing the notifications,
+// for the caller to publish once the transaction has committed.
+type TxNotifier interface {
+	Notifier
+	NotifyTx(tx *gorm.DB, userID uint, kind, title, body string) ([]events.Event, error)
+	NotifyAllTx(tx *gorm.DB, userIDs []uint, kind, title, body string) ([]events.Event, error)
+}
+
+// LogNotifier is a Notifier that only writes notifications to the application log.
+type LogNotifier struct{}
+
+// NewLogNotifier creates a new LogNotifier.
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(userID uint, kind, title, body string) error {
+	log.Printf("notification for user %d [%s] %s: %s", userID, kind, title, body)
+	return nil
+}
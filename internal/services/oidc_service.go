@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"grocademy/internal/auth"
+	"grocademy/internal/sso"
+
+	"golang.org/x/oauth2"
+)
+
+// oidcRequestTimeout bounds the requests made to a provider while signing a user in.
+const oidcRequestTimeout = 15 * time.Second
+
+// OIDCServicer defines the operations for signing in with external providers.
+type OIDCServicer interface {
+	GetProviders() []sso.ProviderInfo
+	BeginLogin(provider string) (*OIDCLogin, error)
+	CompleteLogin(provider, code, state, stateToken string) (*LoginResult, error)
+}
+
+// OIDCLogin is a sign in that was started with an external provider. The user is sent to AuthURL, and
+// StateToken is kept by the browser until the provider redirects back to the callback.
+type OIDCLogin struct {
+	AuthURL    string
+	StateToken string
+}
+
+type OIDCService struct {
+	Auth      AuthServicer
+	Providers sso.Providers
+}
+
+// NewOIDCService signs users in with the providers configured in the environment, see sso.LoadProvidersFromEnv.
+func NewOIDCService(authService AuthServicer) *OIDCService {
+	return &OIDCService{Auth: authService, Providers: sso.LoadProvidersFromEnv(appBaseURL())}
+}
+
+func (s *OIDCService) GetProviders() []sso.ProviderInfo {
+	return s.Providers.List()
+}
+
+// BeginLogin starts the authorization code flow with the provider, protected by a random state, nonce and
+// PKCE code verifier.
+func (s *OIDCService) BeginLogin(name string) (*OIDCLogin, error) {
+	provider, err := s.Providers.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+	stateToken, err := auth.GenerateOIDCStateJWT(name, state, nonce, verifier)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+	return &OIDCLogin{AuthURL: authURL, StateToken: stateToken}, nil
+}
+
+// CompleteLogin handles the provider's callback and signs in the user linked to their identity.
+func (s *OIDCService) CompleteLogin(name, code, state, stateToken string) (*LoginResult, error) {
+	claims, err := auth.ValidateOIDCStateJWT(stateToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Provider != name || claims.State != state {
+		return nil, errors.New("invalid or expired sign in state")
+	}
+
+	provider, err := s.Providers.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+
+	identity, err := provider.Exchange(ctx, code, claims.Nonce, claims.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	return s.Auth.LoginWithIdentity(name, identity)
+}
+
+// randomToken returns 32 random bytes encoded for use in a URL.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.New("failed to generate token")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
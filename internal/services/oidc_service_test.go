@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grocademy/internal/auth"
+	"grocademy/internal/db/dbtest"
+	"grocademy/internal/db/models"
+	"grocademy/internal/sso"
+	"grocademy/internal/sso/ssotest"
+
+	"gorm.io/gorm"
+)
+
+// newOIDCTestService signs users in with a mock IdP named "mock", served by an httptest server.
+func newOIDCTestService(t *testing.T) (*OIDCService, *ssotest.Provider, *gorm.DB) {
+	t.Helper()
+
+	if err := auth.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(nil)
+	idp, err := ssotest.NewProvider("http://"+server.Listener.Addr().String(), "grocademy", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Config.Handler = idp
+	server.Start()
+	t.Cleanup(server.Close)
+
+	t.Setenv("OIDC_PROVIDERS", "mock")
+	t.Setenv("OIDC_MOCK_ISSUER_URL", idp.Issuer)
+	t.Setenv("OIDC_MOCK_CLIENT_ID", idp.ClientID)
+	t.Setenv("OIDC_MOCK_CLIENT_SECRET", idp.ClientSecret)
+	t.Setenv("MFA_REQUIRED_ROLES", "none")
+
+	db := dbtest.Open(t, &models.User{}, &models.ExternalIdentity{}, &models.RecoveryCode{}, &models.OutboxEmail{})
+	authService := NewAuthService(db, NewEmailService(db), NewMFAService(db))
+	return &OIDCService{Auth: authService, Providers: sso.LoadProvidersFromEnv("http://grocademy.test")}, idp, db
+}
+
+// signInWithIdP runs a whole sign in: it starts a login, signs in at the IdP and completes the login
+// with the callback the IdP redirected to.
+func signInWithIdP(t *testing.T, s *OIDCService, idp *ssotest.Provider, email string, emailVerified bool) (*LoginResult, error) {
+	t.Helper()
+
+	login, err := s.BeginLogin("mock")
+	if err != nil {
+		t.Fatalf("BeginLogin: %v", err)
+	}
+	callback, err := idp.SignIn(login.AuthURL, email, "Ada Lovelace", emailVerified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.CompleteLogin("mock", callback.Query().Get("code"), callback.Query().Get("state"), login.StateToken)
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, user models.User) models.User {
+	t.Helper()
+
+	if user.Password == "" {
+		hash, err := auth.HashPassword("Str0ng!Passw0rd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.Password = hash
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+func TestOIDCCompleteLoginRejectsMismatchedState(t *testing.T) {
+	s, idp, db := newOIDCTestService(t)
+
+	first, err := s.BeginLogin("mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.BeginLogin("mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback, err := idp.SignIn(first.AuthURL, "ada@example.com", "Ada Lovelace", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := callback.Query().Get("code"), callback.Query().Get("state")
+
+	tests := []struct {
+		name       string
+		provider   string
+		state      string
+		stateToken string
+		wantErr    string
+	}{
+		{"state of another login", "mock", state, second.StateToken, "invalid or expired sign in state"},
+		{"missing state cookie", "mock", state, "", ""},
+		{"state for another provider", "google", state, first.StateToken, "invalid or expired sign in state"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.CompleteLogin(tt.provider, code, tt.state, tt.stateToken)
+			if err == nil {
+				t.Fatal("CompleteLogin succeeded, want an error")
+			}
+			if tt.wantErr != "" && err.Error() != tt.wantErr {
+				t.Errorf("CompleteLogin error = %q, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d users were created, want none", count)
+	}
+}
+
+func TestOIDCCompleteLoginRejectsCodeOfAnotherLogin(t *testing.T) {
+	s, idp, _ := newOIDCTestService(t)
+
+	first, err := s.BeginLogin("mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.BeginLogin("mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback, err := idp.SignIn(first.AuthURL, "ada@example.com", "Ada Lovelace", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondCallback, err := idp.SignIn(second.AuthURL, "ada@example.com", "Ada Lovelace", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The state matches the second login, but the code was issued for the first login's PKCE challenge
+	_, err = s.CompleteLogin("mock", callback.Query().Get("code"), secondCallback.Query().Get("state"), second.StateToken)
+	if err == nil {
+		t.Fatal("CompleteLogin accepted a code issued for another code verifier")
+	}
+}
+
+func TestOIDCCompleteLoginRejectsUnverifiedEmail(t *testing.T) {
+	s, idp, db := newOIDCTestService(t)
+	createTestUser(t, db, models.User{Username: "ada", Email: "ada@example.com", FirstName: "Ada", EmailVerifiedAt: ptr(time.Now())})
+
+	_, err := signInWithIdP(t, s, idp, "ada@example.com", false)
+	if !errors.Is(err, ErrUnverifiedIdentityEmail) {
+		t.Fatalf("CompleteLogin error = %v, want %v", err, ErrUnverifiedIdentityEmail)
+	}
+
+	var count int64
+	db.Model(&models.ExternalIdentity{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d identities were linked, want none", count)
+	}
+}
+
+func TestOIDCCompleteLoginLinksVerifiedEmail(t *testing.T) {
+	s, idp, db := newOIDCTestService(t)
+	user := createTestUser(t, db, models.User{Username: "ada", Email: "Ada@Example.com", FirstName: "Ada", EmailVerifiedAt: ptr(time.Now())})
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		result, err := signInWithIdP(t, s, idp, "ada@example.com", true)
+		if err != nil {
+			t.Fatalf("sign in %d: %v", attempt, err)
+		}
+		claims, err := auth.ValidateJWT(result.Token)
+		if err != nil {
+			t.Fatalf("sign in %d returned an invalid session token: %v", attempt, err)
+		}
+		if claims.ID != user.ID {
+			t.Errorf("sign in %d signed in user %d, want %d", attempt, claims.ID, user.ID)
+		}
+	}
+
+	var identities []models.ExternalIdentity
+	db.Find(&identities)
+	if len(identities) != 1 || identities[0].UserID != user.ID || identities[0].Subject != ssotest.Subject("ada@example.com") {
+		t.Errorf("linked identities = %+v, want one of user %d", identities, user.ID)
+	}
+	var linked models.User
+	db.First(&linked, user.ID)
+	if linked.Password != user.Password {
+		t.Error("linking a verified account changed its password")
+	}
+}
+
+func TestOIDCCompleteLoginClaimsUnverifiedAccount(t *testing.T) {
+	s, idp, db := newOIDCTestService(t)
+	user := createTestUser(t, db, models.User{
+		Username:      "ada",
+		Email:         "ada@example.com",
+		FirstName:     "Ada",
+		TOTPSecret:    "JBSWY3DPEHPK3PXP",
+		TOTPEnabledAt: ptr(time.Now()),
+	})
+	if err := db.Create(&models.RecoveryCode{UserID: user.ID, CodeHash: "hash"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := signInWithIdP(t, s, idp, "ada@example.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claimed models.User
+	db.First(&claimed, user.ID)
+	if claimed.EmailVerifiedAt == nil {
+		t.Error("claimed account is not verified")
+	}
+	if claimed.Password != "" || claimed.TOTPSecret != "" || claimed.TOTPEnabledAt != nil {
+		t.Error("claimed account kept the password or authenticator of whoever registered it")
+	}
+	if claimed.SessionVersion != user.SessionVersion+1 {
+		t.Errorf("session version = %d, want %d so earlier sessions are revoked", claimed.SessionVersion, user.SessionVersion+1)
+	}
+	var codes int64
+	db.Model(&models.RecoveryCode{}).Where("user_id = ?", user.ID).Count(&codes)
+	if codes != 0 {
+		t.Errorf("claimed account kept %d recovery codes", codes)
+	}
+
+	// The session of whoever claimed the account must outlive the revocation
+	claims, err := auth.ValidateJWT(result.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Auth.(*AuthService).ValidateSession(claims.ID, claims.SessionVersion); err != nil {
+		t.Errorf("session of the claimed account was rejected: %v", err)
+	}
+}
+
+func TestOIDCCompleteLoginCreatesAccount(t *testing.T) {
+	s, idp, db := newOIDCTestService(t)
+	createTestUser(t, db, models.User{Username: "ada", Email: "someone@example.com", FirstName: "Someone"})
+
+	result, err := signInWithIdP(t, s, idp, "ada@example.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", "ada@example.com").First(&user).Error; err != nil {
+		t.Fatalf("account was not created: %v", err)
+	}
+	if user.Username == "ada" || result.Username != user.Username {
+		t.Errorf("username = %q, want an unused one returned with the session", user.Username)
+	}
+	if user.FirstName != "Ada" || user.LastName != "Lovelace" {
+		t.Errorf("name = %q %q, want the name from the IdP", user.FirstName, user.LastName)
+	}
+	if user.Role != models.UserRoleStudent || user.EmailVerifiedAt == nil || user.Password != "" {
+		t.Errorf("created account = %+v, want a verified student without a password", user)
+	}
+
+	var identity models.ExternalIdentity
+	if err := db.Where("provider = ? AND subject = ?", "mock", ssotest.Subject("ada@example.com")).First(&identity).Error; err != nil || identity.UserID != user.ID {
+		t.Errorf("identity was not linked to the created account")
+	}
+	var welcome int64
+	db.Model(&models.OutboxEmail{}).Where("recipient = ? AND template = ?", user.Email, "welcome").Count(&welcome)
+	if welcome != 1 {
+		t.Errorf("%d welcome emails were queued, want 1", welcome)
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"grocademy/internal/db/models"
+
+	"gorm.io/gorm"
+)
+
+// PayoutServicer defines the operations on the instructor payouts ledger.
+type PayoutServicer interface {
+	GetStatementSummaries(instructorID uint) ([]PayoutStatementSummary, error)
+	GetStatement(instructorID uint, period string) (*PayoutStatement, error)
+	SettlePayouts(instructorID uint, period, reference string) (*PayoutStatementSummary, error)
+}
+
+// defaultRevenueShare is the instructor's percentage of a sale when neither the course nor the instructor sets one.
+const defaultRevenueShare = 70.0
+
+// payoutPeriodLayout is the time layout of a payout period, one calendar month.
+const payoutPeriodLayout = "2006-01"
+
+// PayoutStatementSummary totals an instructor's earnings over one month.
+type PayoutStatementSummary struct {
+	InstructorID    uint    `json:"instructor_id"`
+	Period          string  `json:"period"`
+	Sales           int64   `json:"sales"`
+	SalesAmount     float64 `json:"sales_amount"`
+	Earnings        float64 `json:"earnings"`
+	SettledAmount   float64 `json:"settled_amount"`
+	UnsettledAmount float64 `json:"unsettled_amount"`
+}
+
+// PayoutStatementEntry is one sale on a monthly statement.
+type PayoutStatementEntry struct {
+	ID              uint       `json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	TransactionID   uint       `json:"transaction_id"`
+	CourseID        uint       `json:"course_id"`
+	CourseTitle     string     `json:"course_title"`
+	SaleAmount      float64    `json:"sale_amount"`
+	SharePercentage float64    `json:"share_percentage"`
+	Amount          float64    `json:"amount"`
+	SettledAt       *time.Time `json:"settled_at"`
+	SettlementRef   string     `json:"settlement_ref"`
+}
+
+// PayoutStatement is an instructor's monthly statement.
+type PayoutStatement struct {
+	PayoutStatementSummary
+	Entries []PayoutStatementEntry `json:"entries"`
+}
+
+type PayoutService struct {
+	DB *gorm.DB
+}
+
+func NewPayoutService(db *gorm.DB) *PayoutService {
+	return &PayoutService{DB: db}
+}
+
+// GetStatementSummaries totals an instructor's earnings for every month they made a sale, newest first.
+func (s *PayoutService) GetStatementSummaries(instructorID uint) ([]PayoutStatementSummary, error) {
+	summaries := []PayoutStatementSummary{}
+	if err := s.summaryQuery(instructorID).
+		Group("period").
+		Order("period DESC").
+		Scan(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("database error summarizing payouts: %w", err)
+	}
+	return summaries, nil
+}
+
+func (s *PayoutService) GetStatement(instructorID uint, period string) (*PayoutStatement, error) {
+	if _, err := time.Parse(payoutPeriodLayout, period); err != nil {
+		return nil, errors.New("invalid period, expected YYYY-MM")
+	}
+
+	summary, err := s.getSummary(instructorID, period)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []PayoutStatementEntry{}
+	if err := s.DB.Model(&models.PayoutEntry{}).
+		Select("payout_entries.id, payout_entries.created_at, payout_entries.transaction_id, payout_entries.course_id, courses.title AS course_title, payout_entries.sale_amount, payout_entries.share_percentage, payout_entries.amount, payout_entries.settled_at, payout_entries.settlement_ref").
+		Joins("JOIN courses ON courses.id = payout_entries.course_id").
+		Where("payout_entries.instructor_id = ? AND payout_entries.period = ?", instructorID, period).
+		Order("payout_entries.created_at ASC").
+		Scan(&entries).Error; err != nil {
+		return nil, fmt.Errorf("database error finding payouts: %w", err)
+	}
+
+	return &PayoutStatement{PayoutStatementSummary: *summary, Entries: entries}, nil
+}
+
+// SettlePayouts marks every unsettled entry of the instructor in the period as paid out.
+func (s *PayoutService) SettlePayouts(instructorID uint, period, reference string) (*PayoutStatementSummary, error) {
+	if _, err := time.Parse(payoutPeriodLayout, period); err != nil {
+		return nil, errors.New("invalid period, expected YYYY-MM")
+	}
+
+	result := s.DB.Model(&models.PayoutEntry{}).
+		Where("instructor_id = ? AND period = ? AND settled_at IS NULL", instructorID, period).
+		Updates(map[string]interface{}{"SettledAt": time.Now(), "SettlementRef": reference})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to settle payouts: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("no unsettled payouts for this period")
+	}
+
+	return s.getSummary(instructorID, period)
+}
+
+func (s *PayoutService) getSummary(instructorID uint, period string) (*PayoutStatementSummary, error) {
+	summary := PayoutStatementSummary{InstructorID: instructorID, Period: period}
+	if err := s.summaryQuery(instructorID).
+		Where("period = ?", period).
+		Group("period").
+		Scan(&summary).Error; err != nil {
+		return nil, fmt.Errorf("database error summarizing payouts: %w", err)
+	}
+	return &summary, nil
+}
+
+func (s *PayoutService) summaryQuery(instructorID uint) *gorm.DB {
+	return s.DB.Model(&models.PayoutEntry{}).
+		Select(`instructor_id, period, count(*) AS sales,
+			COALESCE(sum(sale_amount), 0) AS sales_amount,
+			COALESCE(sum(amount), 0) AS earnings,
+			COALESCE(sum(amount) FILTER (WHERE settled_at IS NOT NULL), 0) AS settled_amount,
+			COALESCE(sum(amount) FILTER (WHERE settled_at IS NULL), 0) AS unsettled_amount`).
+		Where("instructor_id = ?", instructorID).
+		Group("instructor_id")
+}
+
+// recordPayout credits the course owner with their share of a sale. Courses without an owner
+// leave the whole sale to the platform. It is meant to run inside the purchase transaction.
+func recordPayout(tx *gorm.DB, course models.Course, enrollment models.Enrollment) error {
+	var owner models.User
+	err := tx.Joins("JOIN course_instructors ON course_instructors.user_id = users.id").
+		Where("course_instructors.course_id = ? AND course_instructors.role = ?", course.ID, models.CourseInstructorOwner).
+		First(&owner).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("database error finding course owner: %w", err)
+	}
+
+	share := instanceRevenueShare()
+	if course.RevenueShare != nil {
+		share = *course.RevenueShare
+	} else if owner.RevenueShare != nil {
+		share = *owner.RevenueShare
+	}
+
+	soldAt := enrollment.PurchasedAt
+	if soldAt.IsZero() {
+		soldAt = time.Now()
+	}
+
+	entry := models.PayoutEntry{
+		InstructorID:    owner.ID,
+		CourseID:        course.ID,
+		TransactionID:   enrollment.TransactionID,
+		Period:          soldAt.Format(payoutPeriodLayout),
+		SaleAmount:      course.Price,
+		SharePercentage: share,
+		Amount:          math.Round(course.Price*share) / 100,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to record payout: %w", err)
+	}
+	return nil
+}
+
+// instanceRevenueShare reads the default instructor share from INSTRUCTOR_REVENUE_SHARE.
+func instanceRevenueShare() float64 {
+	value := os.Getenv("INSTRUCTOR_REVENUE_SHARE")
+	if value == "" {
+		return defaultRevenueShare
+	}
+	share, err := strconv.ParseFloat(value, 64)
+	if err != nil || share < 0 || share > 100 {
+		log.Printf("WARNING: invalid INSTRUCTOR_REVENUE_SHARE %q, using %.0f", value, defaultRevenueShare)
+		return defaultRevenueShare
+	}
+	return share
+}
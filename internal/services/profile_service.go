@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"grocademy/internal/auth"
+	"grocademy/internal/db/models"
+	"grocademy/internal/email"
+	"grocademy/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+// maxAvatarSize caps the size of uploaded avatar images.
+const maxAvatarSize = 2 << 20
+
+// ProfileServicer defines the operations users can do on their own account.
+type ProfileServicer interface {
+	GetProfile(userID uint) (*models.User, error)
+	UpdateProfile(userID uint, update ProfileUpdate) (*models.User, error)
+	ChangePassword(userID uint, currentPassword, newPassword string) (string, error)
+	UpdateAvatar(userID uint, avatar *multipart.FileHeader) (*models.User, error)
+}
+
+// ProfileUpdate holds the profile fields to change. Nil fields are left as they are.
+type ProfileUpdate struct {
+	Username  *string
+	Email     *string
+	FirstName *string
+	LastName  *string
+	Locale    *string
+}
+
+// ErrInvalidAvatar is returned for avatar uploads that are not images or are too large.
+var ErrInvalidAvatar = errors.New("avatar must be an image of at most 2 MB")
+
+type ProfileService struct {
+	DB     *gorm.DB
+	Cloud  storage.CloudStorage
+	Mailer Mailer
+}
+
+func NewProfileService(db *gorm.DB, cloud storage.CloudStorage, mailer Mailer) *ProfileService {
+	return &ProfileService{DB: db, Cloud: cloud, Mailer: mailer}
+}
+
+func (s *ProfileService) GetProfile(userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("database error finding user: %w", err)
+	}
+	return &user, nil
+}
+
+// UpdateProfile changes the user's profile. A new email address is only stored as pending, and replaces
+// the current one once the user opens the confirmation link sent to it. The current address is told
+// about the change, so a stolen session cannot quietly move the account to another address.
+func (s *ProfileService) UpdateProfile(userID uint, update ProfileUpdate) (*models.User, error) {
+	user, err := s.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if update.FirstName != nil {
+		updates["FirstName"] = *update.FirstName
+	}
+	if update.LastName != nil {
+		updates["LastName"] = *update.LastName
+	}
+	if update.Locale != nil {
+		updates["Locale"] = *update.Locale
+	}
+
+	if update.Username != nil && *update.Username != user.Username {
+		if *update.Username == "admin" {
+			return nil, errors.New("username is reserved")
+		}
+		var taken int64
+		if err := s.DB.Model(&models.User{}).Where("username = ?", *update.Username).Count(&taken).Error; err != nil {
+			return nil, fmt.Errorf("database error checking existing user: %w", err)
+		}
+		if taken > 0 {
+			return nil, errors.New("username already taken")
+		}
+		updates["Username"] = *update.Username
+	}
+
+	emailChanged := update.Email != nil && !strings.EqualFold(*update.Email, user.Email)
+	if emailChanged {
+		if *update.Email == "admin@example.com" {
+			return nil, errors.New("email is reserved")
+		}
+		var taken int64
+		if err := s.DB.Model(&models.User{}).Where("email = ?", *update.Email).Count(&taken).Error; err != nil {
+			return nil, fmt.Errorf("database error checking existing user: %w", err)
+		}
+		if taken > 0 {
+			return nil, errors.New("email already registered")
+		}
+		updates["PendingEmail"] = *update.Email
+	} else if update.Email != nil && user.PendingEmail != "" {
+		// Setting the current address again cancels a pending change
+		updates["PendingEmail"] = ""
+	}
+
+	if len(updates) > 0 {
+		if err := s.DB.Model(user).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update profile: %w", err)
+		}
+	}
+
+	if emailChanged {
+		if err := sendEmailChangeConfirmation(s.DB, s.Mailer, user.ID, user.PendingEmail); err != nil {
+			log.Printf("failed to email user %d a confirmation link: %v", user.ID, err)
+		}
+		if err := s.Mailer.Mail(user.ID, email.TemplateEmailChange, map[string]interface{}{"NewEmail": user.PendingEmail}); err != nil {
+			log.Printf("failed to tell user %d about their email change: %v", user.ID, err)
+		}
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the user's password after checking the current one. Every other session
+// is signed out; the returned token keeps the caller signed in.
+func (s *ProfileService) ChangePassword(userID uint, currentPassword, newPassword string) (string, error) {
+	user, err := s.GetProfile(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if !auth.CheckPasswordHash(currentPassword, user.Password) {
+		return "", errors.New("current password is incorrect")
+	}
+	if !auth.IsStrongPassword(newPassword) {
+		return "", errors.New("password is weak")
+	}
+
+	hashedPassword, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return "", errors.New("failed to hash password")
+	}
+	if err := s.DB.Model(user).Updates(map[string]interface{}{
+		"Password":       hashedPassword,
+		"SessionVersion": revokeSessions,
+	}).Error; err != nil {
+		return "", fmt.Errorf("failed to change password: %w", err)
+	}
+	// The new token carries the new session version, so it is not revoked with the old ones
+	if err := s.DB.Select("session_version").First(user).Error; err != nil {
+		return "", fmt.Errorf("database error finding user: %w", err)
+	}
+
+	token, err := auth.GenerateJWT(user.ID, user.Username, user.Email, user.Role, user.SessionVersion)
+	if err != nil {
+		return "", errors.New("failed to generate token")
+	}
+	return token, nil
+}
+
+// avatarExtensions are the image types accepted as avatars, by their sniffed content type.
+var avatarExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UpdateAvatar uploads a new avatar image for the user, replacing the previous one in storage. The image
+// type is sniffed from the content, the Content-Type and file name sent by the client are not trusted.
+func (s *ProfileService) UpdateAvatar(userID uint, avatar *multipart.FileHeader) (*models.User, error) {
+	if avatar.Size > maxAvatarSize {
+		return nil, ErrInvalidAvatar
+	}
+
+	src, err := avatar.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrInvalidAvatar
+	}
+	head = head[:n]
+	extension, ok := avatarExtensions[http.DetectContentType(head)]
+	if !ok {
+		return nil, ErrInvalidAvatar
+	}
+
+	user, err := s.GetProfile(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	savePath := filepath.Join("user", "avatar", fmt.Sprintf("%d%s", user.ID, extension))
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	dst, err := os.Create(savePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination file: %w", err)
+	}
+	_, err = io.Copy(dst, io.MultiReader(bytes.NewReader(head), src))
+	dst.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save file: %w", err)
+	}
+
+	URL, err := s.Cloud.UploadFile(avatar, savePath, user.AvatarURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload to cloud: %w", err)
+	}
+
+	if err := s.DB.Model(user).Update("AvatarURL", URL).Error; err != nil {
+		return nil, fmt.Errorf("failed to update avatar: %w", err)
+	}
+	return user, nil
+}
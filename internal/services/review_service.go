@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReviewServicer defines the operations on course reviews and their moderation.
+type ReviewServicer interface {
+	GetCourseReviews(courseID uint, page, limit int64) (*[]CourseReview, pagination.Pagination, error)
+	GetMyReview(userID, courseID uint) (*models.Review, error)
+	SaveReview(userID, courseID uint, rating int, body string) (*models.Review, error)
+	DeleteReview(userID, courseID uint) error
+	GetReviews(hidden *bool, page, limit int64, query string) (*[]CourseReview, pagination.Pagination, error)
+	HideReview(reviewID, adminID uint, reason string) (*models.Review, error)
+	UnhideReview(reviewID uint) (*models.Review, error)
+}
+
+// ErrReviewNotAllowed is returned when a user may not review a course yet.
+var ErrReviewNotAllowed = errors.New("not allowed to review this course")
+
+// defaultReviewCompletionThreshold is the percentage of a course's modules a student must complete before
+// reviewing it, unless REVIEW_COMPLETION_THRESHOLD sets another.
+const defaultReviewCompletionThreshold = 50.0
+
+// CourseReview is a review along with its author and course.
+type CourseReview struct {
+	models.Review
+	Username    string `json:"username"`
+	CourseTitle string `json:"course_title"`
+}
+
+type ReviewService struct {
+	DB *gorm.DB
+}
+
+func NewReviewService(db *gorm.DB) *ReviewService {
+	return &ReviewService{DB: db}
+}
+
+// GetCourseReviews lists the visible reviews of a course, newest first.
+func (s *ReviewService) GetCourseReviews(courseID uint, page, limit int64) (*[]CourseReview, pagination.Pagination, error) {
+	var reviews []CourseReview
+	dbQuery := s.reviewQuery().
+		Where("reviews.course_id = ? AND reviews.hidden_at IS NULL", courseID).
+		Order("reviews.created_at DESC, reviews.id DESC")
+
+	_, pagination, err := pagination.Paginate(dbQuery, &reviews, page, limit, nil, "")
+	if err != nil {
+		return nil, pagination, fmt.Errorf("database error finding reviews: %w", err)
+	}
+	return &reviews, pagination, nil
+}
+
+func (s *ReviewService) GetMyReview(userID, courseID uint) (*models.Review, error) {
+	var review models.Review
+	if err := s.DB.Where("user_id = ? AND course_id = ?", userID, courseID).First(&review).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("review not found")
+		}
+		return nil, fmt.Errorf("database error finding review: %w", err)
+	}
+	return &review, nil
+}
+
+// SaveReview creates the user's review of a course, or edits it when they already wrote one. Only enrolled
+// users who completed enough of the course may review it. Editing a hidden review keeps it hidden.
+func (s *ReviewService) SaveReview(userID, courseID uint, rating int, body string) (*models.Review, error) {
+	if rating < 1 || rating > 5 {
+		return nil, errors.New("rating must be between 1 and 5")
+	}
+
+	var review models.Review
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockCourseRating(tx, courseID); err != nil {
+			return err
+		}
+
+		var enrolled bool
+		if err := tx.Model(&models.Enrollment{}).Select("count(*) > 0").
+			Where("user_id = ? AND course_id = ?", userID, courseID).Find(&enrolled).Error; err != nil {
+			return fmt.Errorf("database error finding enrollment: %w", err)
+		}
+		if !enrolled {
+			return fmt.Errorf("%w: you are not enrolled in this course", ErrReviewNotAllowed)
+		}
+
+		totalModules, completedModules, err := countCourseProgress(tx, userID, courseID)
+		if err != nil {
+			return err
+		}
+		threshold := reviewCompletionThreshold()
+		if totalModules > 0 && float64(completedModules)/float64(totalModules)*100 < threshold {
+			return fmt.Errorf("%w: complete at least %.0f%% of the course first", ErrReviewNotAllowed, threshold)
+		}
+
+		err = tx.Where("user_id = ? AND course_id = ?", userID, courseID).First(&review).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			review = models.Review{CourseID: courseID, UserID: userID, Rating: rating, Body: body}
+			if err := tx.Create(&review).Error; err != nil {
+				return fmt.Errorf("failed to create review: %w", err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("database error finding review: %w", err)
+		} else if err := tx.Model(&review).Updates(map[string]interface{}{"Rating": rating, "Body": body}).Error; err != nil {
+			return fmt.Errorf("failed to update review: %w", err)
+		}
+
+		return refreshCourseRating(tx, courseID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (s *ReviewService) DeleteReview(userID, courseID uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockCourseRating(tx, courseID); err != nil {
+			return err
+		}
+		result := tx.Where("user_id = ? AND course_id = ?", userID, courseID).Delete(&models.Review{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete review: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("review not found")
+		}
+		return refreshCourseRating(tx, courseID)
+	})
+}
+
+// GetReviews lists reviews across all courses for moderation, newest first. hidden narrows the list
+// to hidden or visible reviews when set.
+func (s *ReviewService) GetReviews(hidden *bool, page, limit int64, query string) (*[]CourseReview, pagination.Pagination, error) {
+	var reviews []CourseReview
+	searchableColumns := []string{"reviews.body", "users.username", "courses.title"}
+
+	dbQuery := s.reviewQuery().Order("reviews.created_at DESC, reviews.id DESC")
+	if hidden != nil {
+		if *hidden {
+			dbQuery = dbQuery.Where("reviews.hidden_at IS NOT NULL")
+		} else {
+			dbQuery = dbQuery.Where("reviews.hidden_at IS NULL")
+		}
+	}
+
+	_, pagination, err := pagination.Paginate(dbQuery, &reviews, page, limit, searchableColumns, query)
+	if err != nil {
+		return nil, pagination, fmt.Errorf("database error finding reviews: %w", err)
+	}
+	return &reviews, pagination, nil
+}
+
+// HideReview takes an abusive review out of the course's rating and review list.
+func (s *ReviewService) HideReview(reviewID, adminID uint, reason string) (*models.Review, error) {
+	return s.moderate(reviewID, map[string]interface{}{"HiddenAt": time.Now(), "HiddenByID": adminID, "HiddenReason": reason})
+}
+
+func (s *ReviewService) UnhideReview(reviewID uint) (*models.Review, error) {
+	return s.moderate(reviewID, map[string]interface{}{"HiddenAt": nil, "HiddenByID": nil, "HiddenReason": ""})
+}
+
+func (s *ReviewService) moderate(reviewID uint, updates map[string]interface{}) (*models.Review, error) {
+	var review models.Review
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&review, reviewID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("review not found")
+			}
+			return fmt.Errorf("database error finding review: %w", err)
+		}
+		if err := lockCourseRating(tx, review.CourseID); err != nil {
+			return err
+		}
+		if err := tx.Model(&review).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to moderate review: %w", err)
+		}
+		return refreshCourseRating(tx, review.CourseID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (s *ReviewService) reviewQuery() *gorm.DB {
+	return s.DB.Model(&models.Review{}).
+		Select("reviews.*, users.username, courses.title AS course_title").
+		Joins("JOIN users ON users.id = reviews.user_id").
+		Joins("JOIN courses ON courses.id = reviews.course_id")
+}
+
+// lockCourseRating locks the course row until the transaction ends. Transactions changing reviews take
+// it before the change, otherwise the refreshCourseRating of one misses the review of another running
+// concurrently and overwrites the rating with stale counts.
+func lockCourseRating(tx *gorm.DB, courseID uint) error {
+	var course models.Course
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&course, courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("course not found")
+		}
+		return fmt.Errorf("database error locking course: %w", err)
+	}
+	return nil
+}
+
+// refreshCourseRating recomputes the denormalized rating of a course from its visible reviews.
+// It is meant to run inside the transaction changing the reviews, after lockCourseRating.
+func refreshCourseRating(tx *gorm.DB, courseID uint) error {
+	err := tx.Exec(`UPDATE courses SET (rating_count, rating_average, rating_histogram) = (
+		SELECT count(*), COALESCE(round(avg(rating), 2), 0), ARRAY[
+			count(*) FILTER (WHERE rating = 1),
+			count(*) FILTER (WHERE rating = 2),
+			count(*) FILTER (WHERE rating = 3),
+			count(*) FILTER (WHERE rating = 4),
+			count(*) FILTER (WHERE rating = 5)
+		]
+		FROM reviews
+		WHERE reviews.course_id = courses.id AND reviews.hidden_at IS NULL AND reviews.deleted_at IS NULL
+	) WHERE id = ?`, courseID).Error
+	if err != nil {
+		return fmt.Errorf("failed to update course rating: %w", err)
+	}
+	return nil
+}
+
+// reviewCompletionThreshold reads the completion percentage required to review from REVIEW_COMPLETION_THRESHOLD.
+func reviewCompletionThreshold() float64 {
+	value := os.Getenv("REVIEW_COMPLETION_THRESHOLD")
+	if value == "" {
+		return defaultReviewCompletionThreshold
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold < 0 || threshold > 100 {
+		log.Printf("WARNING: invalid REVIEW_COMPLETION_THRESHOLD %q, using %.0f", value, defaultReviewCompletionThreshold)
+		return defaultReviewCompletionThreshold
+	}
+	return threshold
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/json_map"
+	"grocademy/internal/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+// RevisionServicer defines the interface for recording and reading course and module revisions.
+type RevisionServicer interface {
+	Record(tx *gorm.DB, entityType string, entityID, actorID uint, action string, before, after map[string]interface{}, assets []string) (*models.Revision, error)
+	GetRevisions(entityType string, entityID uint, page, limit int64) (*[]models.Revision, pagination.Pagination, error)
+	GetRevision(entityType string, entityID uint, version int) (*models.Revision, error)
+}
+
+// RevisionService implements RevisionServicer.
+type RevisionService struct {
+	DB *gorm.DB
+}
+
+// NewRevisionService creates a new RevisionService.
+func NewRevisionService(db *gorm.DB) *RevisionService {
+	return &RevisionService{DB: db}
+}
+
+// Record stores the next revision of an entity using tx, so it commits together with the change itself.
+// before is nil for newly created entities.
+func (s *RevisionService) Record(
+	tx *gorm.DB,
+	entityType string, entityID, actorID uint,
+	action string,
+	before, after map[string]interface{},
+	assets []string,
+) (*models.Revision, error) {
+	snapshot, err := normalizeSnapshot(after)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := normalizeSnapshot(before)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastVersion int
+	if err := tx.Model(&models.Revision{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&lastVersion).Error; err != nil {
+		return nil, fmt.Errorf("database error finding last revision: %w", err)
+	}
+
+	revision := models.Revision{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Version:    lastVersion + 1,
+		Action:     action,
+		ActorID:    actorID,
+		Snapshot:   snapshot,
+		Diff:       diffSnapshots(previous, snapshot),
+		Assets:     assets,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, fmt.Errorf("failed to record revision: %w", err)
+	}
+
+	return &revision, nil
+}
+
+// GetRevisions lists the revisions of an entity, newest first.
+func (s *RevisionService) GetRevisions(entityType string, entityID uint, page, limit int64) (*[]models.Revision, pagination.Pagination, error) {
+	var revisions []models.Revision
+
+	dbQuery := s.DB.Model(&models.Revision{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Order("version DESC")
+
+	result, pagination, err := pagination.Paginate(dbQuery, &revisions, page, limit, nil, "")
+	if err != nil {
+		return nil, pagination, err
+	}
+
+	return result.(*[]models.Revision), pagination, nil
+}
+
+// GetRevision retrieves a single revision of an entity by version number.
+func (s *RevisionService) GetRevision(entityType string, entityID uint, version int) (*models.Revision, error) {
+	var revision models.Revision
+	result := s.DB.Where("entity_type = ? AND entity_id = ? AND version = ?", entityType, entityID, version).First(&revision)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("revision not found")
+		}
+		return nil, fmt.Errorf("database error finding revision: %w", result.Error)
+	}
+	return &revision, nil
+}
+
+// normalizeSnapshot round-trips a snapshot through JSON so values compare the same way they are stored.
+func normalizeSnapshot(snapshot map[string]interface{}) (json_map.JSONMap, error) {
+	if snapshot == nil {
+		return json_map.JSONMap{}, nil
+	}
+	bytes, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	var normalized json_map.JSONMap
+	if err := json.Unmarshal(bytes, &normalized); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return normalized, nil
+}
+
+// diffSnapshots returns {"field": {"from": old, "to": new}} for every field that differs.
+func diffSnapshots(before, after json_map.JSONMap) json_map.JSONMap {
+	diff := json_map.JSONMap{}
+	keys := make(map[string]struct{})
+	for key := range before {
+		keys[key] = struct{}{}
+	}
+	for key := range after {
+		keys[key] = struct{}{}
+	}
+
+	for key := range keys {
+		from, _ := json.Marshal(before[key])
+		to, _ := json.Marshal(after[key])
+		if string(from) != string(to) {
+			diff[key] = map[string]interface{}{"from": before[key], "to": after[key]}
+		}
+	}
+	return diff
+}
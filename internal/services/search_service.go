@@ -0,0 +1,130 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/pagination"
+
+	"gorm.io/gorm"
+)
+
+// SearchServicer defines full-text search across courses and modules.
+type SearchServicer interface {
+	Search(userID uint, query, kind string, page, limit int64, includeUnpublished bool) (*[]SearchResult, pagination.Pagination, error)
+}
+
+// Kinds of search results.
+const (
+	SearchKindCourse = "course"
+	SearchKindModule = "module"
+)
+
+// SearchResult is a course or module matching a search, with highlighted snippets.
+// Snippets are HTML-escaped with matches wrapped in <mark>.
+type SearchResult struct {
+	Kind           string  `json:"kind"`
+	ID             uint    `json:"id"`
+	CourseID       uint    `json:"course_id"`
+	Title          string  `json:"title"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+	ThumbnailImage string  `json:"thumbnail_image"`
+	SearchRank     float64 `json:"search_rank"`
+}
+
+// The search_vector columns and their triggers are maintained by migration 000013. Text is stemmed
+// as English, and titles also match by trigram word similarity to tolerate typos.
+const (
+	searchTSQuery = "websearch_to_tsquery('english', @q)"
+
+	// courseSearchCondition matches courses by their own text, their modules' text, or a close title.
+	courseSearchCondition = `(courses.search_vector @@ ` + searchTSQuery + ` OR @q <% courses.title OR EXISTS (
+		SELECT 1 FROM modules
+		WHERE modules.course_id = courses.id AND modules.deleted_at IS NULL AND modules.search_vector @@ ` + searchTSQuery + `
+	))`
+
+	// courseSearchRank ranks matches on the course itself above matches on its modules.
+	courseSearchRank = `(ts_rank_cd(courses.search_vector, ` + searchTSQuery + `) + word_similarity(@q, courses.title) + 0.5 * COALESCE((
+		SELECT max(ts_rank_cd(modules.search_vector, ` + searchTSQuery + `)) FROM modules
+		WHERE modules.course_id = courses.id AND modules.deleted_at IS NULL
+	), 0))`
+
+	moduleSearchCondition = `(modules.search_vector @@ ` + searchTSQuery + ` OR @q <% modules.title)`
+
+	moduleSearchRank = `(ts_rank_cd(modules.search_vector, ` + searchTSQuery + `) + word_similarity(@q, modules.title))`
+)
+
+// courseSearchColumns are the rank and highlight columns added to a course query when searching.
+var courseSearchColumns = courseSearchRank + " AS search_rank, " +
+	searchHeadline("courses.title", "HighlightAll=true") + " AS title_highlight, " +
+	searchHeadline("courses.description", "MaxWords=35, MinWords=15, MaxFragments=2") + " AS description_highlight"
+
+// searchHeadline returns an HTML-safe ts_headline expression over column. The text is escaped before
+// highlighting so that only the <mark> tags added by Postgres are markup.
+func searchHeadline(column, options string) string {
+	escaped := fmt.Sprintf("replace(replace(replace(COALESCE(%s, ''), '&', '&amp;'), '<', '&lt;'), '>', '&gt;')", column)
+	return fmt.Sprintf("ts_headline('english', %s, %s, 'StartSel=<mark>, StopSel=</mark>, %s')", escaped, searchTSQuery, options)
+}
+
+type SearchService struct {
+	DB *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{DB: db}
+}
+
+// Search ranks courses and modules matching the query. kind narrows the results to one kind when set.
+// Modules of unpublished courses and modules hidden until released are left out unless includeUnpublished is set.
+func (s *SearchService) Search(userID uint, query, kind string, page, limit int64, includeUnpublished bool) (*[]SearchResult, pagination.Pagination, error) {
+	q := sql.Named("q", query)
+
+	courseQuery := s.DB.Model(&models.Course{}).
+		Select(`'`+SearchKindCourse+`' AS kind, courses.id, courses.id AS course_id, courses.title, `+
+			searchHeadline("courses.title", "HighlightAll=true")+` AS title_highlight, `+
+			searchHeadline("courses.description", "MaxWords=35, MinWords=15, MaxFragments=2")+` AS snippet, `+
+			`courses.thumbnail_image, `+courseSearchRank+` AS search_rank`, q).
+		Where(courseSearchCondition, q)
+
+	moduleQuery := s.DB.Model(&models.Module{}).
+		Select(`'`+SearchKindModule+`' AS kind, modules.id, modules.course_id, modules.title, `+
+			searchHeadline("modules.title", "HighlightAll=true")+` AS title_highlight, `+
+			searchHeadline("modules.description", "MaxWords=35, MinWords=15, MaxFragments=2")+` AS snippet, `+
+			`courses.thumbnail_image, `+moduleSearchRank+` AS search_rank`, q).
+		Joins("JOIN courses ON courses.id = modules.course_id AND courses.deleted_at IS NULL").
+		Where(moduleSearchCondition, q)
+
+	if !includeUnpublished {
+		courseQuery = courseQuery.Where("courses.status = ?", models.CourseStatusPublished)
+		moduleQuery = moduleQuery.Where("courses.status = ?", models.CourseStatusPublished).
+			Where(releasedModulesCondition, userID)
+	}
+
+	var union *gorm.DB
+	switch kind {
+	case SearchKindCourse:
+		union = s.DB.Raw("?", courseQuery)
+	case SearchKindModule:
+		union = s.DB.Raw("?", moduleQuery)
+	default:
+		union = s.DB.Raw("(?) UNION ALL (?)", courseQuery, moduleQuery)
+	}
+
+	var totalItems int64
+	if err := s.DB.Raw("SELECT count(*) FROM (?) AS results", union).Scan(&totalItems).Error; err != nil {
+		return nil, pagination.Pagination{}, fmt.Errorf("database error counting search results: %w", err)
+	}
+
+	pagination := pagination.New(page, limit, totalItems)
+	offset := (pagination.CurrentPage - 1) * limit
+
+	results := []SearchResult{}
+	if err := s.DB.Raw("SELECT * FROM (?) AS results ORDER BY search_rank DESC, kind ASC, id ASC LIMIT ? OFFSET ?", union, limit, offset).
+		Scan(&results).Error; err != nil {
+		return nil, pagination, fmt.Errorf("database error searching: %w", err)
+	}
+
+	return &results, pagination, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"grocademy/internal/db/models"
+	"grocademy/internal/pkg/string_array"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxPersonalAccessTokens caps the active tokens of a user.
+	maxPersonalAccessTokens = 20
+	// tokenLastUsedResolution limits how often the last-used time of a token is written.
+	tokenLastUsedResolution = time.Minute
+)
+
+// roleScopes are the scopes each role can grant its tokens.
+var roleScopes = map[string][]string{
+	models.UserRoleStudent:    {models.ScopeCoursesRead},
+	models.UserRoleInstructor: {models.ScopeCoursesRead, models.ScopeCoursesWrite},
+	models.UserRoleAdmin:      {models.ScopeCoursesRead, models.ScopeCoursesWrite, models.ScopeUsersRead, models.ScopeUsersWrite},
+}
+
+var (
+	// ErrInvalidScope is returned when creating a token with a scope that does not exist or is not available to the user's role.
+	ErrInvalidScope = errors.New("invalid scope for your role")
+	// ErrInvalidAccessToken is returned for personal access tokens that do not exist, were revoked or expired.
+	ErrInvalidAccessToken = errors.New("invalid, revoked or expired access token")
+	// ErrTooManyTokens is returned when creating a token for a user who has the most active tokens allowed.
+	ErrTooManyTokens = fmt.Errorf("a user can have at most %d active tokens", maxPersonalAccessTokens)
+)
+
+// PersonalAccessTokenServicer defines the operations on personal access tokens.
+type PersonalAccessTokenServicer interface {
+	CreateToken(userID uint, name string, scopes []string, expiresAt *time.Time) (*models.PersonalAccessToken, string, error)
+	GetTokens(userID uint) ([]models.PersonalAccessToken, error)
+	RevokeToken(userID, tokenID uint) error
+	AuthenticateToken(token string) (*models.User, []string, error)
+}
+
+type PersonalAccessTokenService struct {
+	DB *gorm.DB
+}
+
+func NewPersonalAccessTokenService(db *gorm.DB) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{DB: db}
+}
+
+// CreateToken issues a personal access token for the user. The plain token is returned once; only its hash is stored.
+func (s *PersonalAccessTokenService) CreateToken(userID uint, name string, scopes []string, expiresAt *time.Time) (*models.PersonalAccessToken, string, error) {
+	var user models.User
+	if err := s.DB.Select("id", "role").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", errors.New("user not found")
+		}
+		return nil, "", fmt.Errorf("database error finding user: %w", err)
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(roleScopes[user.Role], scope) {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", errors.New("expiry must be in the future")
+	}
+
+	var active int64
+	if err := s.DB.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Count(&active).Error; err != nil {
+		return nil, "", fmt.Errorf("database error counting tokens: %w", err)
+	}
+	if active >= maxPersonalAccessTokens {
+		return nil, "", ErrTooManyTokens
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := models.PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	accessToken := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashUserToken(token),
+		Hint:      token[:len(models.PersonalAccessTokenPrefix)+6],
+		Scopes:    string_array.StringArray(scopes),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.DB.Create(accessToken).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create token: %w", err)
+	}
+	return accessToken, token, nil
+}
+
+// GetTokens lists the user's tokens that were not revoked, newest first.
+func (s *PersonalAccessTokenService) GetTokens(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	if err := s.DB.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeToken stops one of the user's tokens from working.
+func (s *PersonalAccessTokenService) RevokeToken(userID, tokenID uint) error {
+	result := s.DB.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("RevokedAt", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("token not found")
+	}
+	return nil
+}
+
+// AuthenticateToken returns the user a personal access token belongs to and the token's scopes, and records its use.
+func (s *PersonalAccessTokenService) AuthenticateToken(token string) (*models.User, []string, error) {
+	var accessToken models.PersonalAccessToken
+	if err := s.DB.Preload("User").
+		Where("token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", hashUserToken(token), time.Now()).
+		First(&accessToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidAccessToken
+		}
+		return nil, nil, fmt.Errorf("database error finding token: %w", err)
+	}
+	// Tokens of deleted users are not preloaded
+	if accessToken.User.ID == 0 {
+		return nil, nil, ErrInvalidAccessToken
+	}
+
+	now := time.Now()
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) >= tokenLastUsedResolution {
+		if err := s.DB.Model(&accessToken).Update("LastUsedAt", now).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to record token use: %w", err)
+		}
+	}
+	return &accessToken.User, accessToken.Scopes, nil
+}
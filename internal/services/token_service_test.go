@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"grocademy/internal/db/dbtest"
+	"grocademy/internal/db/models"
+
+	"gorm.io/gorm"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.PersonalAccessToken{})
+	s := NewPersonalAccessTokenService(db)
+	user := createTestUser(t, db, models.User{Username: "ada", Email: "ada@example.com", FirstName: "Ada", Role: models.UserRoleInstructor})
+
+	tests := []struct {
+		name    string
+		expires *time.Time
+		change  func(t *testing.T, token *models.PersonalAccessToken)
+		wantErr error
+	}{
+		{name: "without expiry"},
+		{name: "before expiry", expires: ptr(time.Now().Add(time.Hour))},
+		{
+			name: "revoked",
+			change: func(t *testing.T, token *models.PersonalAccessToken) {
+				if err := s.RevokeToken(user.ID, token.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrInvalidAccessToken,
+		},
+		{
+			name:    "expired",
+			expires: ptr(time.Now().Add(time.Hour)),
+			change: func(t *testing.T, token *models.PersonalAccessToken) {
+				if err := db.Model(token).Update("ExpiresAt", time.Now().Add(-time.Second)).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrInvalidAccessToken,
+		},
+		{
+			name: "of a deleted user",
+			change: func(t *testing.T, token *models.PersonalAccessToken) {
+				if err := db.Delete(&models.User{}, user.ID).Error; err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() {
+					db.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Update("DeletedAt", gorm.DeletedAt{})
+				})
+			},
+			wantErr: ErrInvalidAccessToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, plain, err := s.CreateToken(user.ID, tt.name, []string{models.ScopeCoursesWrite, models.ScopeCoursesRead}, tt.expires)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.change != nil {
+				tt.change(t, token)
+			}
+
+			authenticated, scopes, err := s.AuthenticateToken(plain)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthenticateToken error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if authenticated.ID != user.ID || !slices.Equal(scopes, []string{models.ScopeCoursesRead, models.ScopeCoursesWrite}) {
+				t.Errorf("AuthenticateToken = user %d with %v, want user %d with the token's scopes", authenticated.ID, scopes, user.ID)
+			}
+		})
+	}
+
+	if _, _, err := s.AuthenticateToken(models.PersonalAccessTokenPrefix + "unknown"); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Errorf("AuthenticateToken of an unknown token error = %v, want %v", err, ErrInvalidAccessToken)
+	}
+}
+
+func TestCreateTokenLimitsScopesToRole(t *testing.T) {
+	db := dbtest.Open(t, &models.User{}, &models.PersonalAccessToken{})
+	s := NewPersonalAccessTokenService(db)
+	student := createTestUser(t, db, models.User{Username: "student", Email: "student@example.com", FirstName: "Stu", Role: models.UserRoleStudent})
+	admin := createTestUser(t, db, models.User{Username: "admin", Email: "admin@example.com", FirstName: "Ad", Role: models.UserRoleAdmin})
+
+	tests := []struct {
+		name    string
+		userID  uint
+		scopes  []string
+		wantErr error
+	}{
+		{"student reads courses", student.ID, []string{models.ScopeCoursesRead}, nil},
+		{"student writes courses", student.ID, []string{models.ScopeCoursesWrite}, ErrInvalidScope},
+		{"student without scopes", student.ID, nil, ErrInvalidScope},
+		{"admin manages users", admin.ID, []string{models.ScopeUsersRead, models.ScopeUsersWrite}, nil},
+		{"unknown scope", admin.ID, []string{"everything"}, ErrInvalidScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := s.CreateToken(tt.userID, tt.name, tt.scopes, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CreateToken error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -6,26 +6,35 @@ import (
 	"grocademy/internal/auth"
 	"grocademy/internal/db/models"
 	"grocademy/internal/pkg/pagination"
+	"log"
+	"strconv"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserService struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Notifier Notifier
+	Audit    AuditServicer
 }
 
 type UserServicer interface {
 	CreateUser(user *models.User) error
 	GetUserByID(id uint) (*models.User, error)
 	GetUsers() ([]models.User, error)
-	GetAllUsersPaginated(page, limit int64, query string) (*[]models.User, pagination.Pagination, error)
+	GetAllUsersPaginated(page, limit int64, query string, verified *bool) (*[]models.User, pagination.Pagination, error)
+	GetUsersByCursor(cursor string, limit int64, query string, verified *bool, withCount bool) (*[]models.User, pagination.CursorPagination, error)
 	UpdateUser(id uint, updates map[string]interface{}) (*models.User, error)
-	IncrementUserBalance(id uint, increment float64) (*models.User, error)
+	VerifyUserEmail(id uint) (*models.User, error)
+	UnlockUser(id uint) error
+	IncrementUserBalance(id uint, increment float64, audit AuditEntry) (*models.User, error)
 	DeleteUser(id uint) error
 }
 
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{DB: db}
+func NewUserService(db *gorm.DB, notifier Notifier, audit AuditServicer) *UserService {
+	return &UserService{DB: db, Notifier: notifier, Audit: audit}
 }
 
 func (s *UserService) CreateUser(user *models.User) error {
@@ -54,12 +63,23 @@ func (s *UserService) GetUsers() ([]models.User, error) {
 	return users, nil
 }
 
-func (s *UserService) GetAllUsersPaginated(page, limit int64, query string) (*[]models.User, pagination.Pagination, error) {
+// filterUsersByVerification keeps only users whose email address is verified, or only unverified ones.
+func filterUsersByVerification(db *gorm.DB, verified *bool) *gorm.DB {
+	if verified == nil {
+		return db
+	}
+	if *verified {
+		return db.Where("users.email_verified_at IS NOT NULL")
+	}
+	return db.Where("users.email_verified_at IS NULL")
+}
+
+func (s *UserService) GetAllUsersPaginated(page, limit int64, query string, verified *bool) (*[]models.User, pagination.Pagination, error) {
 	var users []models.User
 	searchableColumns := []string{"username", "email", "first_name", "last_name"}
 
 	filteredUser, pagination, err := pagination.Paginate(
-		s.DB.Model(&models.User{}),
+		filterUsersByVerification(s.DB.Model(&models.User{}), verified),
 		&users,
 		page,
 		limit,
@@ -75,6 +95,29 @@ func (s *UserService) GetAllUsersPaginated(page, limit int64, query string) (*[]
 	return assertedUser, pagination, nil
 }
 
+// userKeys orders users for keyset pagination, newest accounts first.
+var userKeys = []pagination.Key{
+	{Column: "users.created_at", Desc: true},
+	{Column: "users.id", Desc: true},
+}
+
+// GetUsersByCursor lists the users on the keyset page the cursor points at. It stays fast deep into the
+// users table, where GetAllUsersPaginated has to skip every row before the page.
+func (s *UserService) GetUsersByCursor(cursor string, limit int64, query string, verified *bool, withCount bool) (*[]models.User, pagination.CursorPagination, error) {
+	var users []models.User
+	searchableColumns := []string{"username", "email", "first_name", "last_name"}
+
+	return pagination.KeysetPaginate(
+		pagination.Search(filterUsersByVerification(s.DB.Model(&models.User{}), verified), searchableColumns, query),
+		&users,
+		userKeys,
+		func(user models.User) []any { return []any{user.CreatedAt, user.ID} },
+		cursor,
+		limit,
+		withCount,
+	)
+}
+
 func (s *UserService) UpdateUser(id uint, updates map[string]interface{}) (*models.User, error) {
 	var user models.User
 	result := s.DB.First(&user, id)
@@ -89,13 +132,18 @@ func (s *UserService) UpdateUser(id uint, updates map[string]interface{}) (*mode
 		return nil, errors.New("update on admin user is prohibited")
 	}
 
-	if updates["Password"] != "" {
-		hashedPassword, err := auth.HashPassword(fmt.Sprint(updates["Password"]))
+	if password, ok := updates["Password"]; ok {
+		hashedPassword, err := auth.HashPassword(fmt.Sprint(password))
 		if err != nil {
 			return nil, errors.New("failed to hash password")
 		}
 		updates["Password"] = hashedPassword
 	}
+	// Session tokens carry the role and were issued with the old password, so they have to be signed in again
+	_, passwordChanged := updates["Password"]
+	if role, ok := updates["Role"]; passwordChanged || (ok && role != user.Role) {
+		updates["SessionVersion"] = revokeSessions
+	}
 
 	if err := s.DB.Model(&user).Updates(updates).Error; err != nil {
 		return nil, fmt.Errorf("failed to update user: %w", err)
@@ -104,7 +152,8 @@ func (s *UserService) UpdateUser(id uint, updates map[string]interface{}) (*mode
 	return &user, nil
 }
 
-func (s *UserService) IncrementUserBalance(id uint, increment float64) (*models.User, error) {
+// VerifyUserEmail marks a user's email address as verified without a verification link.
+func (s *UserService) VerifyUserEmail(id uint) (*models.User, error) {
 	var user models.User
 	result := s.DB.First(&user, id)
 	if result.Error != nil {
@@ -114,8 +163,60 @@ func (s *UserService) IncrementUserBalance(id uint, increment float64) (*models.
 		return nil, fmt.Errorf("database error finding user: %w", result.Error)
 	}
 
-	if err := s.DB.Model(&user).Update("balance", user.Balance+increment).Error; err != nil {
-		return nil, fmt.Errorf("failed to update user: %w", err)
+	if user.EmailVerifiedAt == nil {
+		if err := s.DB.Model(&user).Update("EmailVerifiedAt", time.Now()).Error; err != nil {
+			return nil, fmt.Errorf("failed to verify email: %w", err)
+		}
+	}
+	return &user, nil
+}
+
+// UnlockUser forgets a user's failed logins, lifting a lockout before it expires.
+func (s *UserService) UnlockUser(id uint) error {
+	var user models.User
+	if err := s.DB.Select("id", "username", "email").First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return fmt.Errorf("database error finding user: %w", err)
+	}
+
+	return clearLoginThrottle(s.DB, accountLoginThrottleKeys(&user)...)
+}
+
+// IncrementUserBalance adds to a user's balance and records the change in the audit log in the same
+// transaction, so the balance never changes unrecorded.
+func (s *UserService) IncrementUserBalance(id uint, increment float64, audit AuditEntry) (*models.User, error) {
+	var user models.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("user not found")
+			}
+			return fmt.Errorf("database error finding user: %w", err)
+		}
+		before := user
+
+		if err := tx.Model(&user).Update("balance", user.Balance+increment).Error; err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		audit.Action = models.AuditUserBalance
+		audit.TargetType = models.AuditTargetUser
+		audit.TargetID = strconv.FormatUint(uint64(user.ID), 10)
+		audit.Before = before
+		audit.After = user
+		return s.Audit.RecordTx(tx, audit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if increment > 0 {
+		body := fmt.Sprintf("%.2f was added to your balance, which is now %.2f.", increment, user.Balance)
+		if err := s.Notifier.Notify(user.ID, models.NotificationBalanceTopUp, "Balance topped up", body); err != nil {
+			log.Printf("failed to notify user %d of balance top-up: %v", user.ID, err)
+		}
 	}
 
 	return &user, nil
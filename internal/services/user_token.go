@@ -0,0 +1,73 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"grocademy/internal/db/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidUserToken is returned for emailed tokens that do not exist, were already used or expired.
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// issueUserToken creates a single-use token for the user, replacing their unused tokens of the same purpose.
+// The plain token is returned to be emailed; only its hash is stored.
+func issueUserToken(db *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Delete(&models.UserToken{}).Error; err != nil {
+			return fmt.Errorf("failed to revoke previous tokens: %w", err)
+		}
+		userToken := models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashUserToken(token),
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		if err := tx.Create(&userToken).Error; err != nil {
+			return fmt.Errorf("failed to create token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken marks a valid token as used inside the transaction and returns it.
+func consumeUserToken(tx *gorm.DB, token, purpose string) (*models.UserToken, error) {
+	var userToken models.UserToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", hashUserToken(token), purpose, time.Now()).
+		First(&userToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidUserToken
+		}
+		return nil, fmt.Errorf("database error finding token: %w", err)
+	}
+
+	if err := tx.Model(&userToken).Update("UsedAt", time.Now()).Error; err != nil {
+		return nil, fmt.Errorf("failed to use token: %w", err)
+	}
+	return &userToken, nil
+}
+
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
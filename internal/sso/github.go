@@ -0,0 +1,110 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+// githubAPIURL is the base URL of the GitHub REST API, used when OIDC_GITHUB_API_URL is not set.
+const githubAPIURL = "https://api.github.com"
+
+// githubProvider signs users in with a GitHub OAuth2 app. GitHub does not issue ID tokens, so the user's
+// account and verified email addresses are read from the REST API.
+type githubProvider struct {
+	name        string
+	displayName string
+	apiURL      string
+	config      oauth2.Config
+}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func newGitHubProvider(name, displayName string, config oauth2.Config, apiURL string) *githubProvider {
+	if displayName == "" {
+		displayName = defaultDisplayName(name)
+	}
+	if apiURL == "" {
+		apiURL = githubAPIURL
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"read:user", "user:email"}
+	}
+	config.Endpoint = github.Endpoint
+	return &githubProvider{name: name, displayName: displayName, apiURL: strings.TrimRight(apiURL, "/"), config: config}
+}
+
+func (p *githubProvider) Name() string        { return p.name }
+func (p *githubProvider) DisplayName() string { return p.displayName }
+
+func (p *githubProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem authorization code: %w", err)
+	}
+	client := p.config.Client(ctx, token)
+
+	var user githubUser
+	if err := p.get(client, "/user", &user); err != nil {
+		return nil, err
+	}
+	var emails []githubEmail
+	if err := p.get(client, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{Subject: strconv.FormatInt(user.ID, 10), Username: user.Login}
+	identity.FirstName, identity.LastName = splitName(user.Name)
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+			break
+		}
+	}
+	return identity, nil
+}
+
+// get reads a JSON resource from the GitHub API.
+func (p *githubProvider) get(client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch GitHub %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch GitHub %s: %s", path, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return errors.New("invalid response from GitHub " + path)
+	}
+	return nil
+}
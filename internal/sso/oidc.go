@@ -0,0 +1,148 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcProvider signs users in with an OpenID Connect provider. The provider's endpoints are discovered
+// on first use, so the application starts even when the provider is unreachable.
+type oidcProvider struct {
+	name        string
+	displayName string
+	issuer      string
+	config      oauth2.Config
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// oidcClaims are the ID token and userinfo claims used to identify the user.
+type oidcClaims struct {
+	Subject           string      `json:"sub"`
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"` // Some providers send the boolean as a string
+	PreferredUsername string      `json:"preferred_username"`
+	Name              string      `json:"name"`
+	GivenName         string      `json:"given_name"`
+	FamilyName        string      `json:"family_name"`
+	Locale            string      `json:"locale"`
+	Nonce             string      `json:"nonce"`
+}
+
+func newOIDCProvider(name, displayName, issuer string, config oauth2.Config) *oidcProvider {
+	if displayName == "" {
+		displayName = defaultDisplayName(name)
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return &oidcProvider{name: name, displayName: displayName, issuer: issuer, config: config}
+}
+
+func (p *oidcProvider) Name() string        { return p.name }
+func (p *oidcProvider) DisplayName() string { return p.displayName }
+
+// discover fetches the provider's configuration once it succeeds.
+func (p *oidcProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		provider, err := oidc.NewProvider(ctx, p.issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover %s: %w", p.name, err)
+		}
+		p.provider = provider
+	}
+	return p.provider, nil
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	config := p.config
+	config.Endpoint = provider.Endpoint()
+	return config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config := p.config
+	config.Endpoint = provider.Endpoint()
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("provider did not return an ID token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("invalid ID token nonce")
+	}
+
+	// Providers may leave the profile out of the ID token
+	if claims.Email == "" && provider.UserInfoEndpoint() != "" {
+		userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch user info: %w", err)
+		}
+		var info oidcClaims
+		if err := userInfo.Claims(&info); err != nil {
+			return nil, fmt.Errorf("invalid user info: %w", err)
+		}
+		if info.Subject != idToken.Subject {
+			return nil, errors.New("user info does not match the ID token")
+		}
+		info.Nonce = claims.Nonce
+		claims = info
+	}
+
+	identity := &Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		Username:      claims.PreferredUsername,
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+		Locale:        claims.Locale,
+	}
+	if identity.FirstName == "" {
+		identity.FirstName, identity.LastName = splitName(claims.Name)
+	}
+	return identity, nil
+}
+
+// isTrue reads a boolean claim sent either as a boolean or as a string.
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Package sso signs users in with external OpenID Connect and OAuth2 providers using the
+// authorization code flow with PKCE.
+package sso
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrUnknownProvider is returned for providers that are not configured.
+var ErrUnknownProvider = errors.New("unknown sign in provider")
+
+// googleIssuer is the issuer of Google accounts, used when OIDC_GOOGLE_ISSUER_URL is not set.
+const googleIssuer = "https://accounts.google.com"
+
+// Identity is the account of a user at an external provider.
+type Identity struct {
+	Subject       string // The user's ID at the provider, stable across sign ins
+	Email         string
+	EmailVerified bool
+	Username      string // Preferred username, may be empty
+	FirstName     string
+	LastName      string
+	Locale        string
+}
+
+// Provider is an external provider users can sign in with.
+type Provider interface {
+	Name() string
+	DisplayName() string
+	// AuthCodeURL returns the URL of the provider's sign in page. The nonce is only used by OpenID Connect providers.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange redeems the authorization code from the callback and returns the signed in identity.
+	Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error)
+}
+
+// ProviderInfo describes a provider for the login page.
+type ProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// Providers holds the configured providers by name.
+type Providers map[string]Provider
+
+// Get returns the provider with the given name.
+func (p Providers) Get(name string) (Provider, error) {
+	provider, ok := p[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// List describes the configured providers, sorted by name.
+func (p Providers) List() []ProviderInfo {
+	infos := make([]ProviderInfo, 0, len(p))
+	for _, provider := range p {
+		infos = append(infos, ProviderInfo{Name: provider.Name(), DisplayName: provider.DisplayName()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// LoadProvidersFromEnv configures the providers listed in OIDC_PROVIDERS, a comma-separated list of names.
+// Each provider NAME is configured with OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and, except for
+// google and github, OIDC_<NAME>_ISSUER_URL. OIDC_<NAME>_DISPLAY_NAME and OIDC_<NAME>_SCOPES are optional.
+// The provider named github uses GitHub's OAuth2 apps, every other provider uses OpenID Connect discovery.
+// Callbacks are sent to <baseURL>/api/auth/oidc/<name>/callback.
+func LoadProvidersFromEnv(baseURL string) Providers {
+	providers := Providers{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := oauth2.Config{
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  strings.TrimRight(baseURL, "/") + "/api/auth/oidc/" + name + "/callback",
+		}
+		if config.ClientID == "" {
+			log.Printf("WARNING: %sCLIENT_ID not set, sign in with %s is disabled", prefix, name)
+			continue
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			config.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		displayName := os.Getenv(prefix + "DISPLAY_NAME")
+
+		if name == "github" {
+			providers[name] = newGitHubProvider(name, displayName, config, os.Getenv(prefix+"API_URL"))
+			continue
+		}
+
+		issuer := os.Getenv(prefix + "ISSUER_URL")
+		if issuer == "" && name == "google" {
+			issuer = googleIssuer
+		}
+		if issuer == "" {
+			log.Printf("WARNING: %sISSUER_URL not set, sign in with %s is disabled", prefix, name)
+			continue
+		}
+		providers[name] = newOIDCProvider(name, displayName, issuer, config)
+	}
+	return providers
+}
+
+// defaultDisplayName capitalizes a provider name for the login page.
+func defaultDisplayName(name string) string {
+	switch name {
+	case "github":
+		return "GitHub"
+	case "":
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// splitName splits a full name into a first and last name.
+func splitName(name string) (string, string) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return fields[0], strings.Join(fields[1:], " ")
+}
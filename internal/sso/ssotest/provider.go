@@ -0,0 +1,334 @@
+// Package ssotest provides an OpenID Connect provider for trying out and testing "sign in with". It signs
+// in whoever fills in its form, so it must never be exposed publicly.
+package ssotest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	keyID    = "mockidp"
+	codeTTL  = time.Minute
+	tokenTTL = time.Hour
+)
+
+// grant is an issued authorization code or access token.
+type grant struct {
+	ClientID      string
+	RedirectURI   string
+	CodeChallenge string
+	Nonce         string
+	Email         string
+	EmailVerified bool
+	Name          string
+	ExpiresAt     time.Time
+}
+
+// Provider is a mock OpenID Connect provider. Its authorization endpoint shows a form asking for the email
+// address and name to sign in with, and whether the email address is verified.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+
+	key     *rsa.PrivateKey
+	handler http.Handler
+
+	mu     sync.Mutex
+	codes  map[string]*grant
+	tokens map[string]*grant
+}
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Mock IdP</title></head>
+<body>
+    <h1>Mock IdP sign in</h1>
+    <form method="post" action="/authorize">
+        {{ range $name, $value := .Params }}<input type="hidden" name="{{ $name }}" value="{{ index $value 0 }}">
+        {{ end }}
+        <p><label>Email <input type="email" name="email" value="student@example.com" required></label></p>
+        <p><label>Name <input type="text" name="name" value="Mock Student"></label></p>
+        <p><label><input type="checkbox" name="email_verified" value="true" checked> Email verified</label></p>
+        <button type="submit">Sign in</button>
+    </form>
+</body>
+</html>`))
+
+// NewProvider creates a provider for one client, signing ID tokens with a new RSA key. The issuer is the
+// URL the provider is served at.
+func NewProvider(issuer, clientID, clientSecret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	p := &Provider{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		codes:        map[string]*grant{},
+		tokens:       map[string]*grant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("GET /authorize", p.authorizeForm)
+	mux.HandleFunc("POST /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /userinfo", p.userInfo)
+	p.handler = mux
+	return p, nil
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.handler.ServeHTTP(w, r)
+}
+
+// SignIn fills in the sign in form of the authorization URL a client sent the user to, and returns the
+// callback URL the provider redirects the user back to.
+func (p *Provider) SignIn(authURL, email, name string, emailVerified bool) (*url.URL, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return nil, err
+	}
+	form := u.Query()
+	form.Set("email", email)
+	form.Set("name", name)
+	if emailVerified {
+		form.Set("email_verified", "true")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/authorize", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		return nil, fmt.Errorf("sign in failed with status %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+	return url.Parse(rec.Header().Get("Location"))
+}
+
+// Subject is the user ID the provider reports for an email address.
+func Subject(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"userinfo_endpoint":                     p.Issuer + "/userinfo",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   encode(p.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorizeForm checks the authorization request and shows the sign in form.
+func (p *Provider) authorizeForm(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if msg := p.checkAuthorizeRequest(params); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	authorizeTemplate.Execute(w, map[string]any{"Params": params})
+}
+
+// authorize signs in the user from the form and redirects back to the client with a code.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	params := r.PostForm
+	if msg := p.checkAuthorizeRequest(params); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	code, err := randomString()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = &grant{
+		ClientID:      params.Get("client_id"),
+		RedirectURI:   params.Get("redirect_uri"),
+		CodeChallenge: params.Get("code_challenge"),
+		Nonce:         params.Get("nonce"),
+		Email:         params.Get("email"),
+		EmailVerified: params.Get("email_verified") == "true",
+		Name:          params.Get("name"),
+		ExpiresAt:     time.Now().Add(codeTTL),
+	}
+	p.mu.Unlock()
+
+	redirect, _ := url.Parse(params.Get("redirect_uri"))
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("state", params.Get("state"))
+	redirect.RawQuery = query.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// checkAuthorizeRequest returns why an authorization request is invalid, or an empty string.
+func (p *Provider) checkAuthorizeRequest(params url.Values) string {
+	switch {
+	case params.Get("client_id") != p.ClientID:
+		return "unknown client_id"
+	case params.Get("response_type") != "code":
+		return "response_type must be code"
+	case params.Get("code_challenge") == "" || params.Get("code_challenge_method") != "S256":
+		return "PKCE with S256 is required"
+	}
+	if redirect, err := url.Parse(params.Get("redirect_uri")); err != nil || !redirect.IsAbs() {
+		return "invalid redirect_uri"
+	}
+	return ""
+}
+
+// token redeems an authorization code for an ID token and an access token.
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	p.mu.Lock()
+	code := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if code == nil || time.Now().After(code.ExpiresAt) || code.ClientID != clientID ||
+		code.RedirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != code.CodeChallenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.Issuer,
+		"sub":            Subject(code.Email),
+		"aud":            clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(tokenTTL).Unix(),
+		"nonce":          code.Nonce,
+		"email":          code.Email,
+		"email_verified": code.EmailVerified,
+		"name":           code.Name,
+	})
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	accessToken, err := randomString()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	code.ExpiresAt = now.Add(tokenTTL)
+	p.mu.Lock()
+	p.tokens[accessToken] = code
+	p.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenTTL.Seconds()),
+		"id_token":     signed,
+	})
+}
+
+func (p *Provider) userInfo(w http.ResponseWriter, r *http.Request) {
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	p.mu.Lock()
+	user := p.tokens[accessToken]
+	p.mu.Unlock()
+	if !ok || user == nil || time.Now().After(user.ExpiresAt) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sub":            Subject(user.Email),
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.Name,
+	})
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random string: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
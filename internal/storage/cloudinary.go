@@ -2,8 +2,8 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"mime/multipart"
 	"net/url"
 	"os"
@@ -44,6 +44,18 @@ func (c *CloudinaryStorage) UploadFile(file *multipart.FileHeader, path string,
 		}
 	}()
 
+	resp, err := c.Cloudinary.Upload.Upload(c.Context, path, uploader.UploadParams{
+		UseFilename:    api.Bool(true),
+		UniqueFilename: api.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Error.Message != "" {
+		return "", errors.New(resp.Error.Message)
+	}
+
+	// The old file is only deleted once its replacement is uploaded
 	if oldURL != "" {
 		oldID, err := extractPublicID(oldURL)
 		println("Parsed publicID: " + oldID)
@@ -66,16 +78,6 @@ func (c *CloudinaryStorage) UploadFile(file *multipart.FileHeader, path string,
 		}
 	}
 
-	resp, err := c.Cloudinary.Upload.Upload(c.Context, path, uploader.UploadParams{
-		UseFilename:    api.Bool(true),
-		UniqueFilename: api.Bool(true),
-	})
-
-	if err != nil {
-		log.Fatal(err)
-		return "", err
-	}
-
 	return resp.SecureURL, nil
 }
 
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS module_prerequisites;
+ALTER TABLE courses DROP COLUMN IF EXISTS sequential_modules;
@@ -0,0 +1,12 @@
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS sequential_modules BOOLEAN DEFAULT FALSE NOT NULL;
+
+CREATE TABLE IF NOT EXISTS module_prerequisites (
+    id SERIAL PRIMARY KEY,
+    module_id INT NOT NULL,
+    prerequisite_id INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_module_prerequisites_module FOREIGN KEY (module_id) REFERENCES modules(id) ON DELETE CASCADE,
+    CONSTRAINT fk_module_prerequisites_prerequisite FOREIGN KEY (prerequisite_id) REFERENCES modules(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_module_prerequisite ON module_prerequisites (module_id, prerequisite_id);
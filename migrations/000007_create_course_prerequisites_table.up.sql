@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS course_prerequisites (
+    id SERIAL PRIMARY KEY,
+    course_id INT NOT NULL,
+    prerequisite_id INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_course_prerequisites_course FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+    CONSTRAINT fk_course_prerequisites_prerequisite FOREIGN KEY (prerequisite_id) REFERENCES courses(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_course_prerequisite ON course_prerequisites (course_id, prerequisite_id);
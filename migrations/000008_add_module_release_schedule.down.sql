@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS module_release_notices;
+ALTER TABLE modules DROP COLUMN IF EXISTS hide_until_released;
+ALTER TABLE modules DROP COLUMN IF EXISTS release_after_days;
+ALTER TABLE modules DROP COLUMN IF EXISTS release_at;
@@ -0,0 +1,14 @@
+ALTER TABLE modules ADD COLUMN IF NOT EXISTS release_at TIMESTAMPTZ;
+ALTER TABLE modules ADD COLUMN IF NOT EXISTS release_after_days INT;
+ALTER TABLE modules ADD COLUMN IF NOT EXISTS hide_until_released BOOLEAN DEFAULT FALSE NOT NULL;
+
+CREATE TABLE IF NOT EXISTS module_release_notices (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    module_id INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_module_release_notices_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_module_release_notices_module FOREIGN KEY (module_id) REFERENCES modules(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_module_release ON module_release_notices (user_id, module_id);
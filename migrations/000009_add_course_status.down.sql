@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_courses_status;
+ALTER TABLE courses DROP COLUMN IF EXISTS published_at;
+ALTER TABLE courses DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE courses DROP COLUMN IF EXISTS status;
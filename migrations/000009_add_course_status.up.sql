@@ -0,0 +1,9 @@
+-- courses created before the publication workflow were already live
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS status VARCHAR(20) DEFAULT 'published' NOT NULL;
+ALTER TABLE courses ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+
+UPDATE courses SET published_at = created_at WHERE status = 'published' AND published_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_courses_status ON courses (status);
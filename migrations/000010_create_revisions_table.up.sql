@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS revisions (
+    id SERIAL PRIMARY KEY,
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id INT NOT NULL,
+    version INT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    actor_id INT NOT NULL,
+    snapshot JSONB NOT NULL,
+    diff JSONB NOT NULL,
+    assets TEXT[],
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_revisions_actor FOREIGN KEY (actor_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_entity_revision ON revisions (entity_type, entity_id, version);
@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'student';
+CREATE INDEX IF NOT EXISTS idx_users_role ON users (role);
+UPDATE users SET role = 'admin' WHERE username = 'admin';
+
+CREATE TABLE IF NOT EXISTS course_instructors (
+    id SERIAL PRIMARY KEY,
+    course_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_course_instructors_course FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+    CONSTRAINT fk_course_instructors_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_course_instructor ON course_instructors (course_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_course_instructors_user_id ON course_instructors (user_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS payout_entries;
+
+ALTER TABLE users DROP COLUMN IF EXISTS revenue_share;
+ALTER TABLE courses DROP COLUMN IF EXISTS revenue_share;
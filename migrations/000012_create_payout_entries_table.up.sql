@@ -0,0 +1,24 @@
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS revenue_share NUMERIC;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS revenue_share NUMERIC;
+
+CREATE TABLE IF NOT EXISTS payout_entries (
+    id SERIAL PRIMARY KEY,
+    instructor_id INT NOT NULL,
+    course_id INT NOT NULL,
+    transaction_id INT NOT NULL,
+    period CHAR(7) NOT NULL,
+    sale_amount NUMERIC NOT NULL,
+    share_percentage NUMERIC NOT NULL,
+    amount NUMERIC NOT NULL,
+    settled_at TIMESTAMPTZ,
+    settlement_ref VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_payout_entries_instructor FOREIGN KEY (instructor_id) REFERENCES users(id),
+    CONSTRAINT fk_payout_entries_course FOREIGN KEY (course_id) REFERENCES courses(id),
+    CONSTRAINT fk_payout_entries_enrollment FOREIGN KEY (transaction_id) REFERENCES enrollments(transaction_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payout_entries_transaction_id ON payout_entries (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_payout_instructor_period ON payout_entries (instructor_id, period);
+CREATE INDEX IF NOT EXISTS idx_payout_entries_course_id ON payout_entries (course_id);
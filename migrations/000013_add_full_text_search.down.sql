@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_modules_title_trgm;
+DROP INDEX IF EXISTS idx_courses_title_trgm;
+DROP INDEX IF EXISTS idx_modules_search_vector;
+DROP INDEX IF EXISTS idx_courses_search_vector;
+
+DROP TRIGGER IF EXISTS modules_search_vector_trigger ON modules;
+DROP TRIGGER IF EXISTS courses_search_vector_trigger ON courses;
+DROP FUNCTION IF EXISTS modules_search_vector_update();
+DROP FUNCTION IF EXISTS courses_search_vector_update();
+
+ALTER TABLE modules DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE courses DROP COLUMN IF EXISTS search_vector;
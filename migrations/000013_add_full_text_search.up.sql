@@ -0,0 +1,43 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+ALTER TABLE modules ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+CREATE OR REPLACE FUNCTION courses_search_vector_update() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('english', COALESCE(NEW.title, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(NEW.instructor, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(array_to_string(NEW.topics, ' '), '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(NEW.description, '')), 'C');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION modules_search_vector_update() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('english', COALESCE(NEW.title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(NEW.description, '')), 'B');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS courses_search_vector_trigger ON courses;
+CREATE TRIGGER courses_search_vector_trigger
+    BEFORE INSERT OR UPDATE OF title, instructor, topics, description ON courses
+    FOR EACH ROW EXECUTE FUNCTION courses_search_vector_update();
+
+DROP TRIGGER IF EXISTS modules_search_vector_trigger ON modules;
+CREATE TRIGGER modules_search_vector_trigger
+    BEFORE INSERT OR UPDATE OF title, description ON modules
+    FOR EACH ROW EXECUTE FUNCTION modules_search_vector_update();
+
+-- Fill the vectors of existing rows through the triggers
+UPDATE courses SET title = title;
+UPDATE modules SET title = title;
+
+CREATE INDEX IF NOT EXISTS idx_courses_search_vector ON courses USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_modules_search_vector ON modules USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_courses_title_trgm ON courses USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_modules_title_trgm ON modules USING GIN (title gin_trgm_ops);
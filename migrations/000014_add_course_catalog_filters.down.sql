@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_courses_rating;
+DROP INDEX IF EXISTS idx_courses_price;
+DROP INDEX IF EXISTS idx_courses_topics;
+
+ALTER TABLE courses
+    DROP COLUMN IF EXISTS rating_count,
+    DROP COLUMN IF EXISTS rating_average;
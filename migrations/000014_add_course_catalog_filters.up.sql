@@ -0,0 +1,7 @@
+ALTER TABLE courses
+    ADD COLUMN IF NOT EXISTS rating_average NUMERIC NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rating_count BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_courses_topics ON courses USING GIN (topics);
+CREATE INDEX IF NOT EXISTS idx_courses_price ON courses (price);
+CREATE INDEX IF NOT EXISTS idx_courses_rating ON courses (rating_average DESC, rating_count DESC);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reviews;
+
+ALTER TABLE courses DROP COLUMN IF EXISTS rating_histogram;
@@ -0,0 +1,23 @@
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS rating_histogram BIGINT[] NOT NULL DEFAULT '{0,0,0,0,0}';
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,
+    course_id INT NOT NULL,
+    user_id INT NOT NULL,
+    rating INT NOT NULL,
+    body TEXT,
+    hidden_at TIMESTAMPTZ,
+    hidden_by_id INT,
+    hidden_reason TEXT,
+    CONSTRAINT chk_reviews_rating CHECK (rating BETWEEN 1 AND 5),
+    CONSTRAINT fk_reviews_course FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+    CONSTRAINT fk_reviews_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_reviews_hidden_by FOREIGN KEY (hidden_by_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_review_course_user ON reviews (course_id, user_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_reviews_user_id ON reviews (user_id);
+CREATE INDEX IF NOT EXISTS idx_reviews_deleted_at ON reviews (deleted_at);
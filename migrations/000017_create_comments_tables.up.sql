@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,
+    module_id INT NOT NULL,
+    user_id INT NOT NULL,
+    thread_id INT,
+    parent_id INT,
+    kind VARCHAR(20) NOT NULL,
+    body TEXT NOT NULL,
+    score BIGINT NOT NULL DEFAULT 0,
+    accepted_answer_id INT,
+    pinned_at TIMESTAMPTZ,
+    CONSTRAINT fk_comments_module FOREIGN KEY (module_id) REFERENCES modules(id) ON DELETE CASCADE,
+    CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_comments_thread FOREIGN KEY (thread_id) REFERENCES comments(id) ON DELETE CASCADE,
+    CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE SET NULL,
+    CONSTRAINT fk_comments_accepted_answer FOREIGN KEY (accepted_answer_id) REFERENCES comments(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_module_id ON comments (module_id);
+CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments (user_id);
+CREATE INDEX IF NOT EXISTS idx_comments_thread_id ON comments (thread_id);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
+
+CREATE TABLE IF NOT EXISTS comment_votes (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    comment_id INT NOT NULL,
+    user_id INT NOT NULL,
+    CONSTRAINT fk_comment_votes_comment FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    CONSTRAINT fk_comment_votes_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_comment_vote ON comment_votes (comment_id, user_id);
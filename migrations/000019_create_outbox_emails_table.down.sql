@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS outbox_emails;
+
+ALTER TABLE users DROP COLUMN IF EXISTS locale;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS external_identities (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    user_id INT NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL,
+    last_login_at TIMESTAMPTZ,
+    CONSTRAINT fk_external_identities_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_external_identities_provider_subject ON external_identities (provider, subject);
+CREATE INDEX IF NOT EXISTS idx_external_identities_user_id ON external_identities (user_id);
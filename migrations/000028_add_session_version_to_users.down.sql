@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMPTZ;
+
+UPDATE users SET sessions_revoked_at = NOW() WHERE session_version > 0;
+
+ALTER TABLE users DROP COLUMN IF EXISTS session_version;
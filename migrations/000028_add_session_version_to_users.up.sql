@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS session_version INT NOT NULL DEFAULT 0;
+
+-- Tokens issued before this migration carry no version, revoke them for users who revoked their sessions
+UPDATE users SET session_version = 1 WHERE sessions_revoked_at IS NOT NULL;
+
+ALTER TABLE users DROP COLUMN IF EXISTS sessions_revoked_at;
@@ -38,6 +38,11 @@ async function handleAuthForm(event, endpoint, messageId) {
         return;
       }
 
+      if (result.data && result.data.mfa_required) {
+        await startMFAChallenge(result.data, messageEl);
+        return;
+      }
+
       messageEl.textContent = result.message || "Success!";
       messageEl.classList.add("success");
 
@@ -57,9 +62,205 @@ async function handleAuthForm(event, endpoint, messageId) {
     }
   }
 
+  async function startMFAChallenge(login, messageEl) {
+    const loginForm = document.querySelector("#login-form");
+    const mfaForm = document.querySelector("#mfa-form");
+    mfaForm.dataset.challengeToken = login.challenge_token;
+
+    if (login.mfa_setup_required) {
+      const res = await fetch("/api/auth/login/2fa/setup", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ challenge_token: login.challenge_token }),
+      });
+      const result = await res.json();
+      if (!res.ok) {
+        messageEl.textContent = result.message || "Something went wrong.";
+        messageEl.classList.add("error");
+        return;
+      }
+      document.getElementById("mfa-qr-code").src = result.data.qr_code;
+      document.getElementById("mfa-secret").textContent = result.data.secret;
+      document.getElementById("mfa-setup").hidden = false;
+    }
+
+    loginForm.hidden = true;
+    document.querySelector("#oidc-providers").hidden = true;
+    mfaForm.hidden = false;
+    messageEl.textContent = "Enter the code from your authenticator app.";
+  }
+
+  async function handleMFAForm(event, messageId) {
+    event.preventDefault();
+
+    const form = event.target;
+    const messageEl = document.getElementById(messageId);
+    messageEl.textContent = "";
+    messageEl.className = "form-message"; // reset
+
+    const code = new FormData(form).get("code");
+
+    try {
+      const res = await fetch("/api/auth/login/2fa", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ challenge_token: form.dataset.challengeToken, code }),
+      });
+      const result = await res.json();
+
+      if (!res.ok) {
+        messageEl.textContent = result.message || "Something went wrong.";
+        messageEl.classList.add("error");
+        return;
+      }
+
+      const recoveryCodes = result.data.recovery_codes || [];
+      if (recoveryCodes.length > 0) {
+        form.hidden = true;
+        document.getElementById("mfa-recovery-code-list").textContent = recoveryCodes.join("\n");
+        document.getElementById("mfa-recovery-codes").hidden = false;
+        return;
+      }
+
+      messageEl.textContent = result.message || "Success!";
+      messageEl.classList.add("success");
+      setTimeout(() => {
+        window.location.href = "/dashboard";
+      }, 1200);
+    } catch (err) {
+      console.error("Request failed:", err);
+      messageEl.textContent = "Network error.";
+      messageEl.classList.add("error");
+    }
+  }
+
+  async function loadOIDCProviders(container) {
+    try {
+      const res = await fetch("/api/auth/oidc/providers");
+      const result = await res.json();
+      if (!res.ok || !result.data || result.data.length === 0) {
+        return;
+      }
+
+      for (const provider of result.data) {
+        const link = document.createElement("a");
+        link.className = "oidc-button";
+        link.href = `/api/auth/oidc/${encodeURIComponent(provider.name)}/login`;
+        link.textContent = `Sign in with ${provider.display_name}`;
+        container.appendChild(link);
+      }
+      container.hidden = document.querySelector("#login-form").hidden;
+    } catch (err) {
+      console.error("Failed to load sign in providers", err);
+    }
+  }
+
+  // Sign ins with external providers redirect back to the login page with an error or a 2FA challenge
+  async function handleOIDCRedirect(messageId) {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    const messageEl = document.getElementById(messageId);
+    history.replaceState(null, "", window.location.pathname + window.location.search);
+
+    if (params.get("error")) {
+      messageEl.textContent = params.get("error");
+      messageEl.classList.add("error");
+      return;
+    }
+    if (params.get("challenge_token")) {
+      await startMFAChallenge({
+        challenge_token: params.get("challenge_token"),
+        mfa_setup_required: params.get("mfa_setup") === "1",
+      }, messageEl);
+    }
+  }
+
+  async function handlePasswordForm(event, endpoint, messageId, redirect) {
+    event.preventDefault();
+
+    const form = event.target;
+    const messageEl = document.getElementById(messageId);
+    messageEl.textContent = "";
+    messageEl.className = "form-message"; // reset
+
+    const data = Object.fromEntries(new FormData(form).entries());
+
+    if (data.confirm_password !== undefined) {
+      if (!isStrongPassword(data.password)) {
+        messageEl.textContent = "Password must be at least 8 characters containing number, lowercase, and uppercase letters.";
+        messageEl.classList.add("error");
+        return;
+      }
+      if (data.password !== data.confirm_password) {
+        messageEl.textContent = "Confirm password doesn't match.";
+        messageEl.classList.add("error");
+        return;
+      }
+      delete data.confirm_password;
+      data.token = new URLSearchParams(window.location.search).get("token") || "";
+    }
+
+    try {
+      const res = await fetch(endpoint, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify(data),
+      });
+      const result = await res.json();
+
+      if (!res.ok) {
+        messageEl.textContent = result.message || "Something went wrong.";
+        messageEl.classList.add("error");
+        return;
+      }
+
+      messageEl.textContent = result.message || "Success!";
+      messageEl.classList.add("success");
+      form.reset();
+
+      if (redirect) {
+        setTimeout(() => {
+          window.location.href = redirect;
+        }, 1200);
+      }
+    } catch (err) {
+      console.error("Request failed:", err);
+      messageEl.textContent = "Network error.";
+      messageEl.classList.add("error");
+    }
+  }
+
+  async function verifyEmail(messageId) {
+    const messageEl = document.getElementById(messageId);
+    const params = new URLSearchParams(window.location.search);
+    const token = params.get("token") || "";
+    // Links confirming a change of address carry change=1
+    const endpoint = params.has("change") ? "/api/auth/email/change/confirm" : "/api/auth/email/verify";
+
+    try {
+      const res = await fetch(endpoint, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ token }),
+      });
+      const result = await res.json();
+
+      messageEl.textContent = result.message || (res.ok ? "Email verified." : "Something went wrong.");
+      messageEl.classList.add(res.ok ? "success" : "error");
+    } catch (err) {
+      console.error("Request failed:", err);
+      messageEl.textContent = "Network error.";
+      messageEl.classList.add("error");
+    }
+  }
+
   document.addEventListener("DOMContentLoaded", () => {
     const loginForm = document.querySelector("#login-form");
     const registerForm = document.querySelector("#register-form");
+    const forgotPasswordForm = document.querySelector("#forgot-password-form");
+    const resetPasswordForm = document.querySelector("#reset-password-form");
+    const verifyEmailMessage = document.querySelector("#verify-email-message");
+    const mfaForm = document.querySelector("#mfa-form");
+    const mfaContinue = document.querySelector("#mfa-continue");
 
     if (loginForm) {
       loginForm.addEventListener("submit", (e) =>
@@ -71,6 +272,34 @@ async function handleAuthForm(event, endpoint, messageId) {
         handleAuthForm(e, "/api/auth/register", "register-message")
       );
     }
+    if (forgotPasswordForm) {
+      forgotPasswordForm.addEventListener("submit", (e) =>
+        handlePasswordForm(e, "/api/auth/password/forgot", "forgot-password-message")
+      );
+    }
+    if (resetPasswordForm) {
+      resetPasswordForm.addEventListener("submit", (e) =>
+        handlePasswordForm(e, "/api/auth/password/reset", "reset-password-message", "/login")
+      );
+    }
+    if (mfaForm) {
+      mfaForm.addEventListener("submit", (e) => handleMFAForm(e, "login-message"));
+    }
+    const oidcProviders = document.querySelector("#oidc-providers");
+    if (oidcProviders) {
+      loadOIDCProviders(oidcProviders);
+    }
+    if (loginForm && window.location.hash) {
+      handleOIDCRedirect("login-message");
+    }
+    if (mfaContinue) {
+      mfaContinue.addEventListener("click", () => {
+        window.location.href = "/dashboard";
+      });
+    }
+    if (verifyEmailMessage) {
+      verifyEmail("verify-email-message");
+    }
     const menu_toggle = document.querySelector('.menu-toggle');
     const sidebar = document.querySelector('.sidebar');
     if (menu_toggle) {
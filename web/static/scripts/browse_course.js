@@ -19,8 +19,13 @@ async function queryCourse() {
             cardDetail.className = "card-detail";
             card.appendChild(cardDetail)
 
+            // highlights are HTML-escaped by the server, only the <mark> tags are markup
             const title = document.createElement("h3");
-            title.textContent = course.title;
+            if (course.highlights) {
+                title.innerHTML = course.highlights.title;
+            } else {
+                title.textContent = course.title;
+            }
             cardDetail.appendChild(title);
 
             const instructor = document.createElement("p");
@@ -28,7 +33,11 @@ async function queryCourse() {
             cardDetail.appendChild(instructor);
 
             const desc = document.createElement("p");
-            desc.textContent = course.description;
+            if (course.highlights) {
+                desc.innerHTML = course.highlights.description;
+            } else {
+                desc.textContent = course.description;
+            }
             cardDetail.appendChild(desc);
 
             const topicContainer = document.createElement("div");
@@ -58,6 +67,10 @@ async function queryCourse() {
             container.appendChild(card);
         });
 
+        if (data.facets) {
+            renderFilters(data.facets);
+        }
+
         // update pagination
         totalPages = data.pagination.total_pages;
         document.getElementById("pageInfo").textContent = `Page ${data.pagination.current_page} of ${totalPages}`;
@@ -69,26 +82,104 @@ async function queryCourse() {
 
 }
 
-// function startLongPolling() {
-//     fetch('/api/updates') // Endpoint for long polling on the server
-//         .then(response => response.json())
-//         .then(data => {
-//         // Process the received data and update the UI
-//         console.log('New data received:', data);
-//         // Immediately initiate a new long polling request
-//         startLongPolling();
-//         })
-//         .catch(error => {
-//         console.error('Long polling error:', error);
-//         // Implement retry logic or backoff strategies if needed
-//         setTimeout(startLongPolling, 5000); // Retry after 5 seconds
-//         });
-//     }
-
-// document.addEventListener('DOMContentLoaded', startLongPolling);
+let selectedSort = "";
+let selectedTopics = [];
+let selectedPrice = "";
+
+const priceRanges = {
+    "free": "&free=true",
+    "0-50": "&max_price=50",
+    "50-100": "&min_price=50&max_price=100",
+    "100-200": "&min_price=100&max_price=200",
+    "200+": "&min_price=200",
+};
+
+function renderFilters(facets) {
+    const container = document.getElementById("filtersContainer");
+    container.innerHTML = "";
+
+    const sort = document.createElement("select");
+    [["", "Sort: default"], ["newest", "Newest"], ["price_asc", "Price: low to high"], ["price_desc", "Price: high to low"],
+        ["popularity", "Most popular"], ["rating", "Top rated"], ["title", "Title"]].forEach(([value, label]) => {
+        const option = document.createElement("option");
+        option.value = value;
+        option.textContent = label;
+        option.selected = value === selectedSort;
+        sort.appendChild(option);
+    });
+    sort.onchange = () => {
+        selectedSort = sort.value;
+        applyFilters();
+    };
+    container.appendChild(sort);
+
+    const prices = document.createElement("div");
+    prices.className = "filter-group";
+    facets.price.forEach(bucket => {
+        const chip = document.createElement("button");
+        chip.className = bucket.value === selectedPrice ? "filter-chip active" : "filter-chip";
+        chip.textContent = `${bucket.value === "free" ? "Free" : "$" + bucket.value} (${bucket.count})`;
+        chip.onclick = () => {
+            selectedPrice = bucket.value === selectedPrice ? "" : bucket.value;
+            applyFilters();
+        };
+        prices.appendChild(chip);
+    });
+    container.appendChild(prices);
+
+    const topics = document.createElement("div");
+    topics.className = "filter-group";
+    facets.topics.forEach(bucket => {
+        const chip = document.createElement("button");
+        chip.className = selectedTopics.includes(bucket.value) ? "filter-chip active" : "filter-chip";
+        chip.textContent = `${bucket.value} (${bucket.count})`;
+        chip.onclick = () => {
+            if (selectedTopics.includes(bucket.value)) {
+                selectedTopics = selectedTopics.filter(topic => topic !== bucket.value);
+            } else {
+                selectedTopics.push(bucket.value);
+            }
+            applyFilters();
+        };
+        topics.appendChild(chip);
+    });
+    container.appendChild(topics);
+}
+
+function applyFilters() {
+    currentFilters = "";
+    if (selectedSort) {
+        currentFilters += `&sort=${selectedSort}`;
+    }
+    if (selectedTopics.length > 0) {
+        currentFilters += `&topics=${encodeURIComponent(selectedTopics.join(","))}`;
+    }
+    if (selectedPrice) {
+        currentFilters += priceRanges[selectedPrice];
+    }
+    currentPage = 1;
+    queryCourse();
+}
+
+// Refresh the list whenever a course changes instead of polling. The browser reconnects on its own,
+// and "ready" fires again after a reconnection to catch up on what was missed.
+function listenForCourseUpdates() {
+    const source = new EventSource("/api/events");
+    let connected = false;
+    source.addEventListener("ready", () => {
+        if (connected) {
+            queryCourse();
+        }
+        connected = true;
+    });
+    ["course.created", "course.updated", "course.deleted", "course.purchased", "module.added"].forEach(type => {
+        source.addEventListener(type, () => queryCourse());
+    });
+}
+
 document.addEventListener('DOMContentLoaded', () => {
     queryCourse();
-    setInterval(queryCourse, 30000);
+    listenForCourseUpdates();
 });
 
 function handleSearchInput(e){
@@ -47,6 +47,18 @@ async function queryModule() {
         title.className = "module-title"
         card.appendChild(title)
 
+        if (mod.locked) {
+            card.classList.add("locked")
+
+            const lockNotice = document.createElement("p");
+            lockNotice.textContent = `Locked: ${mod.unlock_reason}`;
+            lockNotice.className = "module-lock-reason"
+            card.appendChild(lockNotice)
+
+            container.appendChild(card);
+            return
+        }
+
 
         if (mod.video_content) {
             // const video = document.createElement("a");
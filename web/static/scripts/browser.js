@@ -2,9 +2,10 @@ let currentPage = 1;
 let totalPages = 1;
 let currentQuery = "";
 let currentLimit = 15;
+let currentFilters = "";
 
 async function query(route) {
-    const res = await fetch(`/${encodeURIComponent(route)}?q=${encodeURIComponent(currentQuery)}&page=${currentPage}&limit=${currentLimit}`);
+    const res = await fetch(`/${encodeURIComponent(route)}?q=${encodeURIComponent(currentQuery)}&page=${currentPage}&limit=${currentLimit}${currentFilters}`);
     const data = await res.json();
 
     return data
@@ -17,6 +17,9 @@ document.addEventListener("DOMContentLoaded", async () => {
       document.getElementById("description").textContent = course.description;
       document.getElementById("instructor").textContent = course.instructor;
       document.getElementById("price").textContent = course.price;
+      document.getElementById("rating").textContent = course.rating_count > 0
+        ? `${course.rating_average.toFixed(1)} / 5 (${course.rating_count} reviews)`
+        : "No reviews yet";
 
       const topicContainer = document.getElementById("topic-container");
       course.topics.forEach((topic) => {
@@ -25,6 +28,32 @@ document.addEventListener("DOMContentLoaded", async () => {
           topicTag.className = "topic-tag"
           topicContainer.appendChild(topicTag);
       });
+      if (course.prerequisites && course.prerequisites.length > 0) {
+        const prerequisiteLabel = document.getElementById("prerequisite-label");
+        const prerequisiteList = document.getElementById("prerequisite-list");
+        prerequisiteLabel.hidden = false;
+        prerequisiteList.hidden = false;
+
+        course.prerequisites.forEach((prerequisite) => {
+          const item = document.createElement("li");
+          item.className = prerequisite.completed ? "prerequisite completed" : "prerequisite";
+          item.style.marginLeft = `${(prerequisite.depth - 1) * 16}px`;
+
+          const link = document.createElement("a");
+          link.href = `/courses/${prerequisite.course_id}`;
+          link.textContent = prerequisite.title;
+          item.appendChild(link);
+
+          const status = document.createElement("span");
+          status.textContent = prerequisite.completed
+            ? " (completed)"
+            : ` (${Math.round(prerequisite.progress_percentage)}% complete)`;
+          item.appendChild(status);
+
+          prerequisiteList.appendChild(item);
+        });
+      }
+
       const actionButton = document.getElementById("actionButton");
       const messageEl = document.getElementById("message");
 